@@ -0,0 +1,125 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Application owns the HTTP server lifecycle: listen, serve until told to stop, drain, then release resources
+type Application struct {
+	serverConfig ServerConfig
+	server       *echo.Echo
+	logger       *slog.Logger
+	listener     net.Listener
+	drainHooks   []func()
+	cleanups     []func()
+}
+
+// !NewApplication applies the server timeouts to an already configured echo instance
+func NewApplication(serverConfig ServerConfig, server *echo.Echo, logger *slog.Logger) *Application {
+	server.Server.ReadTimeout = serverConfig.ReadTimeout
+	server.Server.ReadHeaderTimeout = serverConfig.ReadHeaderTimeout
+	server.Server.WriteTimeout = serverConfig.WriteTimeout
+	server.Server.IdleTimeout = serverConfig.IdleTimeout
+	return &Application{
+		serverConfig: serverConfig,
+		server:       server,
+		logger:       logger,
+	}
+}
+
+// !OnShutdown registers cleanup to run once the server has drained, cleanups run in reverse registration order
+func (application *Application) OnShutdown(cleanup func()) {
+	application.cleanups = append(application.cleanups, cleanup)
+}
+
+// !OnDraining registers hook to run as soon as shutdown starts, the drain delay before the listener closes
+func (application *Application) OnDraining(hook func()) {
+	application.drainHooks = append(application.drainHooks, hook)
+}
+
+// !Listen binds the configured address so startup failures surface before serving begins
+func (application *Application) Listen() error {
+	listener, err := net.Listen("tcp", application.serverConfig.Address)
+	if err != nil {
+		return fmt.Errorf("Unable to listen on %s: %w", application.serverConfig.Address, err)
+	}
+	application.listener = listener
+	return nil
+}
+
+// !Addr is the bound address, useful when listening on port 0
+func (application *Application) Addr() net.Addr {
+	if application.listener == nil {
+		return nil
+	}
+	return application.listener.Addr()
+}
+
+// !Serve handles requests until ctx is done. It then runs the draining hooks and keeps serving for the drain delay,
+// so load balancers see readiness go down before connections are refused, stops accepting connections and drains
+// in-flight requests for at most the shutdown timeout. Cleanups always run, whether serving stopped cleanly or not.
+func (application *Application) Serve(ctx context.Context) error {
+	defer application.runCleanups()
+	if application.listener == nil {
+		return errors.New("Serve called before Listen")
+	}
+
+	application.server.Listener = application.listener
+	application.logger.Info("Server listening", "address", application.listener.Addr().String())
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- application.server.Start(application.serverConfig.Address)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("Server stopped unexpectedly: %w", err)
+	case <-ctx.Done():
+	}
+
+	for _, hook := range application.drainHooks {
+		hook()
+	}
+	if application.serverConfig.DrainDelay > 0 {
+		application.logger.Info("Draining, waiting before closing the listener", "delay", application.serverConfig.DrainDelay.String())
+		time.Sleep(application.serverConfig.DrainDelay)
+	}
+	application.logger.Info("Shutting down, draining in-flight requests", "timeout", application.serverConfig.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), application.serverConfig.ShutdownTimeout)
+	defer cancel()
+
+	shutdownErr := application.server.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		application.server.Close()
+		shutdownErr = fmt.Errorf("Requests did not drain in time: %w", shutdownErr)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return errors.Join(shutdownErr, err)
+	}
+	return shutdownErr
+}
+
+// !Run listens and serves, it is what main calls
+func (application *Application) Run(ctx context.Context) error {
+	if err := application.Listen(); err != nil {
+		application.runCleanups()
+		return err
+	}
+	return application.Serve(ctx)
+}
+
+// *runCleanups
+func (application *Application) runCleanups() {
+	for index := len(application.cleanups) - 1; index >= 0; index-- {
+		application.cleanups[index]()
+	}
+	application.cleanups = nil
+}
@@ -1,26 +1,378 @@
 package app
 
-import "product-app/common/postgresql"
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"product-app/common/logging"
+	"product-app/common/postgresql"
+	"product-app/common/tracing"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to every environment variable the application reads
+const EnvPrefix = "PRODUCT_APP_"
+
+type ServerConfig struct {
+	Address           string        `yaml:"address"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout"`
+	DrainDelay        time.Duration `yaml:"drainDelay"`
+	HealthTimeout     time.Duration `yaml:"healthTimeout"`
+	StartDegraded     bool          `yaml:"startDegraded"`
+}
 
 type ConfigurationManager struct {
-	PostgreSqlConfig postgresql.Config
+	ServerConfig     ServerConfig      `yaml:"server"`
+	PostgreSqlConfig postgresql.Config `yaml:"postgres"`
+	LogConfig        logging.Config    `yaml:"log"`
+	TraceConfig      tracing.Config    `yaml:"trace"`
+}
+
+// *setting is a single configuration value reachable through an environment variable and a command-line flag
+type setting struct {
+	flagName string
+	usage    string
+	apply    func(configurationManager *ConfigurationManager, value string) error
+	isBool   bool
+}
+
+var settings = []setting{
+	{"server-address", "host:port the HTTP server listens on", stringSetting(func(c *ConfigurationManager) *string { return &c.ServerConfig.Address }), false},
+	{"server-read-timeout", "maximum duration for reading a whole request", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.ServerConfig.ReadTimeout }), false},
+	{"server-read-header-timeout", "maximum duration for reading request headers", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.ServerConfig.ReadHeaderTimeout }), false},
+	{"server-write-timeout", "maximum duration before timing out writes of the response", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.ServerConfig.WriteTimeout }), false},
+	{"server-idle-timeout", "maximum time to wait for the next request on keep-alive connections", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.ServerConfig.IdleTimeout }), false},
+	{"server-shutdown-timeout", "how long in-flight requests may drain after SIGINT or SIGTERM", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.ServerConfig.ShutdownTimeout }), false},
+	{"server-drain-delay", "how long readiness reports draining before the listener closes, so load balancers stop routing first", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.ServerConfig.DrainDelay }), false},
+	{"server-health-timeout", "how long each readiness check may take", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.ServerConfig.HealthTimeout }), false},
+	{"server-start-degraded", "start serving even when the database is unreachable, readiness stays down until it is", boolSetting(func(c *ConfigurationManager) *bool { return &c.ServerConfig.StartDegraded }), true},
+	{"log-format", "log output format, one of " + strings.Join(logging.Formats, ", "), stringSetting(func(c *ConfigurationManager) *string { return &c.LogConfig.Format }), false},
+	{"log-level", "minimum log level, one of debug, info, warn, error", stringSetting(func(c *ConfigurationManager) *string { return &c.LogConfig.Level }), false},
+	{"trace-exporter", "where spans go, one of " + strings.Join(tracing.Exporters, ", "), stringSetting(func(c *ConfigurationManager) *string { return &c.TraceConfig.Exporter }), false},
+	{"trace-otlp-endpoint", "OTLP/HTTP collector base URL, spans are posted to <url>/v1/traces", stringSetting(func(c *ConfigurationManager) *string { return &c.TraceConfig.OtlpEndpoint }), false},
+	{"trace-service-name", "service.name reported with every span", stringSetting(func(c *ConfigurationManager) *string { return &c.TraceConfig.ServiceName }), false},
+	{"trace-flush-interval", "how often batched spans are sent to the collector", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.TraceConfig.FlushInterval }), false},
+	{"db-host", "database host", stringSetting(func(c *ConfigurationManager) *string { return &c.PostgreSqlConfig.Host }), false},
+	{"db-port", "database port", intSetting(func(c *ConfigurationManager) *int { return &c.PostgreSqlConfig.Port }), false},
+	{"db-name", "database name", stringSetting(func(c *ConfigurationManager) *string { return &c.PostgreSqlConfig.DbName }), false},
+	{"db-user", "database user", stringSetting(func(c *ConfigurationManager) *string { return &c.PostgreSqlConfig.UserName }), false},
+	{"db-password", "database password, prefer db-password-file", stringSetting(func(c *ConfigurationManager) *string { return &c.PostgreSqlConfig.Password }), false},
+	{"db-password-file", "file holding the database password", stringSetting(func(c *ConfigurationManager) *string { return &c.PostgreSqlConfig.PasswordFile }), false},
+	{"db-sslmode", "database sslmode, one of " + strings.Join(postgresql.SslModes, ", "), stringSetting(func(c *ConfigurationManager) *string { return &c.PostgreSqlConfig.SslMode }), false},
+	{"db-connect-timeout", "database connect timeout, 0 waits forever", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.PostgreSqlConfig.ConnectTimeout }), false},
+	{"db-connect-retry-budget", "how long to keep retrying the first database connection, 0 tries once", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.PostgreSqlConfig.ConnectRetryBudget }), false},
+	{"db-max-connections", "maximum size of the connection pool", intSetting(func(c *ConfigurationManager) *int { return &c.PostgreSqlConfig.MaxConnections }), false},
+	{"db-max-connection-idle-time", "duration after which an idle connection is closed", durationSetting(func(c *ConfigurationManager) *time.Duration { return &c.PostgreSqlConfig.MaxConnectionIdleTime }), false},
+}
+
+// !NewConfigurationManager reads the configuration from the process arguments and environment
+func NewConfigurationManager(args []string) (*ConfigurationManager, []string, error) {
+	return LoadConfiguration(args, os.LookupEnv)
+}
+
+// !LoadConfiguration layers defaults, the config file, environment variables and flags, later layers winning.
+// The config file is YAML (JSON works too) and is chosen with --config or PRODUCT_APP_CONFIG. Every environment
+// variable X may also be given as X_FILE naming a file that holds the value, the way Docker and Kubernetes mount
+// secrets. The arguments left after the flags are returned for subcommands.
+func LoadConfiguration(args []string, lookupEnv func(string) (string, bool)) (*ConfigurationManager, []string, error) {
+	flagSet := flag.NewFlagSet("product-app", flag.ContinueOnError)
+	flagSet.SetOutput(os.Stderr)
+	configFile := flagSet.String("config", "", "YAML or JSON configuration file")
+	flagValues := map[string]*string{}
+	for _, setting := range settings {
+		if setting.isBool {
+			value := &boolFlagValue{}
+			flagSet.Var(value, setting.flagName, setting.usage)
+			flagValues[setting.flagName] = &value.value
+			continue
+		}
+		flagValues[setting.flagName] = flagSet.String(setting.flagName, "", setting.usage)
+	}
+	if err := flagSet.Parse(args); err != nil {
+		return nil, nil, fmt.Errorf("Invalid command-line arguments: %w", err)
+	}
+
+	configurationManager := defaultConfiguration()
+
+	if len(*configFile) == 0 {
+		*configFile, _ = lookupEnv(EnvPrefix + "CONFIG")
+	}
+	if len(*configFile) > 0 {
+		if err := readConfigFile(*configFile, configurationManager); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var errs []error
+	for _, setting := range settings {
+		envName := EnvPrefix + strings.ToUpper(strings.ReplaceAll(setting.flagName, "-", "_"))
+		value, found, err := lookupEnvOrFile(lookupEnv, envName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if found {
+			if err := setting.apply(configurationManager, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", envName, err))
+			}
+		}
+	}
+	flagSet.Visit(func(visited *flag.Flag) {
+		for _, setting := range settings {
+			if setting.flagName == visited.Name {
+				if err := setting.apply(configurationManager, *flagValues[setting.flagName]); err != nil {
+					errs = append(errs, fmt.Errorf("--%s: %w", setting.flagName, err))
+				}
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+
+	if err := configurationManager.resolveSecrets(); err != nil {
+		return nil, nil, err
+	}
+	if err := configurationManager.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return configurationManager, flagSet.Args(), nil
 }
 
-func NewConfigurationManager() *ConfigurationManager {
-	postgreSqlConfig := getPostgreSqlConfig()
+// !Validate reports every invalid value at once
+func (configurationManager *ConfigurationManager) Validate() error {
+	var errs []error
+	invalid := func(name string, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s %s", name, fmt.Sprintf(format, args...)))
+	}
+
+	serverConfig := configurationManager.ServerConfig
+	if _, port, err := net.SplitHostPort(serverConfig.Address); err != nil || !isValidPort(port) {
+		invalid("server address", "must be host:port, got %q", serverConfig.Address)
+	}
+	timeouts := []struct {
+		name    string
+		timeout time.Duration
+	}{
+		{"server read timeout", serverConfig.ReadTimeout},
+		{"server read header timeout", serverConfig.ReadHeaderTimeout},
+		{"server write timeout", serverConfig.WriteTimeout},
+		{"server idle timeout", serverConfig.IdleTimeout},
+		{"server drain delay", serverConfig.DrainDelay},
+	}
+	for _, timeout := range timeouts {
+		if timeout.timeout < 0 {
+			invalid(timeout.name, "can not be negative")
+		}
+	}
+	if serverConfig.ShutdownTimeout <= 0 {
+		invalid("server shutdown timeout", "must be positive, got %s", serverConfig.ShutdownTimeout)
+	}
+	if serverConfig.HealthTimeout <= 0 {
+		invalid("server health timeout", "must be positive, got %s", serverConfig.HealthTimeout)
+	}
+
+	postgreSqlConfig := configurationManager.PostgreSqlConfig
+	if len(postgreSqlConfig.Host) == 0 {
+		invalid("database host", "is required")
+	}
+	if !isValidPort(strconv.Itoa(postgreSqlConfig.Port)) {
+		invalid("database port", "must be between 1 and 65535, got %d", postgreSqlConfig.Port)
+	}
+	if len(postgreSqlConfig.DbName) == 0 {
+		invalid("database name", "is required")
+	}
+	if len(postgreSqlConfig.UserName) == 0 {
+		invalid("database user", "is required")
+	}
+	if !slices.Contains(postgresql.SslModes, postgreSqlConfig.SslMode) {
+		invalid("database sslmode", "must be one of %s, got %q", strings.Join(postgresql.SslModes, ", "), postgreSqlConfig.SslMode)
+	}
+	if postgreSqlConfig.ConnectTimeout != 0 && postgreSqlConfig.ConnectTimeout < time.Second {
+		invalid("database connect timeout", "must be 0 or at least 1s, got %s", postgreSqlConfig.ConnectTimeout)
+	}
+	if postgreSqlConfig.ConnectRetryBudget < 0 {
+		invalid("database connect retry budget", "can not be negative")
+	}
+	if postgreSqlConfig.MaxConnections < 1 {
+		invalid("database max connections", "must be at least 1, got %d", postgreSqlConfig.MaxConnections)
+	}
+	if postgreSqlConfig.MaxConnectionIdleTime <= 0 {
+		invalid("database max connection idle time", "must be positive, got %s", postgreSqlConfig.MaxConnectionIdleTime)
+	}
+
+	if err := configurationManager.LogConfig.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := configurationManager.TraceConfig.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// ?defaultConfiguration matches the local docker setup from test/scripts, the password has no default
+func defaultConfiguration() *ConfigurationManager {
 	return &ConfigurationManager{
-		PostgreSqlConfig: postgreSqlConfig,
+		ServerConfig: ServerConfig{
+			Address:           "localhost:8080",
+			ReadTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 10 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   15 * time.Second,
+			DrainDelay:        5 * time.Second,
+			HealthTimeout:     2 * time.Second,
+		},
+		PostgreSqlConfig: postgresql.Config{
+			Host:                  "localhost",
+			Port:                  6432,
+			DbName:                "productapp",
+			UserName:              "postgres",
+			SslMode:               "disable",
+			ConnectTimeout:        10 * time.Second,
+			ConnectRetryBudget:    30 * time.Second,
+			MaxConnections:        10,
+			MaxConnectionIdleTime: 30 * time.Second,
+		},
+		LogConfig: logging.Config{
+			Format: "text",
+			Level:  "info",
+		},
+		TraceConfig: tracing.Config{
+			Exporter:      "none",
+			ServiceName:   "product-app",
+			FlushInterval: 5 * time.Second,
+		},
+	}
+}
+
+// ?readConfigFile decodes over the current values so keys missing from the file keep their defaults
+func readConfigFile(path string, configurationManager *ConfigurationManager) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Unable to read config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(configurationManager); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("Invalid config file %s: %w", path, err)
 	}
+	return nil
 }
 
-func getPostgreSqlConfig() postgresql.Config {
-	return postgresql.Config{
-		Host:                  "localhost",
-		Port:                  "6432",
-		DbName:                "productapp",
-		UserName:              "postgres",
-		Password:              "123321",
-		MaxConnections:        "10",
-		MaxConnectionIdleTime: "30s",
+// ?lookupEnvOrFile reads name directly, or from the file named by name_FILE
+func lookupEnvOrFile(lookupEnv func(string) (string, bool), name string) (string, bool, error) {
+	value, found := lookupEnv(name)
+	filePath, fileFound := lookupEnv(name + "_FILE")
+	if found && fileFound {
+		return "", false, fmt.Errorf("Only one of %s and %s_FILE may be set", name, name)
+	}
+	if !fileFound {
+		return value, found, nil
+	}
+	secret, err := readSecretFile(filePath)
+	if err != nil {
+		return "", false, fmt.Errorf("%s_FILE: %w", name, err)
+	}
+	return secret, true, nil
+}
+
+// *resolveSecrets replaces the password with the content of the password file when one is configured
+func (configurationManager *ConfigurationManager) resolveSecrets() error {
+	passwordFile := configurationManager.PostgreSqlConfig.PasswordFile
+	if len(passwordFile) == 0 {
+		return nil
+	}
+	password, err := readSecretFile(passwordFile)
+	if err != nil {
+		return fmt.Errorf("database password file: %w", err)
+	}
+	configurationManager.PostgreSqlConfig.Password = password
+	return nil
+}
+
+// ?readSecretFile drops the trailing newline most editors and `echo` leave behind
+func readSecretFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// ?isValidPort
+func isValidPort(port string) bool {
+	number, err := strconv.Atoi(port)
+	return err == nil && number >= 1 && number <= 65535
+}
+
+// ?stringSetting
+func stringSetting(field func(c *ConfigurationManager) *string) func(*ConfigurationManager, string) error {
+	return func(configurationManager *ConfigurationManager, value string) error {
+		*field(configurationManager) = value
+		return nil
+	}
+}
+
+// ?intSetting
+func intSetting(field func(c *ConfigurationManager) *int) func(*ConfigurationManager, string) error {
+	return func(configurationManager *ConfigurationManager, value string) error {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", value)
+		}
+		*field(configurationManager) = number
+		return nil
+	}
+}
+
+// ?boolSetting
+func boolSetting(field func(c *ConfigurationManager) *bool) func(*ConfigurationManager, string) error {
+	return func(configurationManager *ConfigurationManager, value string) error {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be true or false, got %q", value)
+		}
+		*field(configurationManager) = enabled
+		return nil
+	}
+}
+
+// *boolFlagValue lets boolean settings be passed as a bare --flag
+type boolFlagValue struct {
+	value string
+}
+
+func (boolFlag *boolFlagValue) String() string { return boolFlag.value }
+
+func (boolFlag *boolFlagValue) Set(value string) error {
+	boolFlag.value = value
+	return nil
+}
+
+func (boolFlag *boolFlagValue) IsBoolFlag() bool { return true }
+
+// ?durationSetting
+func durationSetting(field func(c *ConfigurationManager) *time.Duration) func(*ConfigurationManager, string) error {
+	return func(configurationManager *ConfigurationManager, value string) error {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 30s, got %q", value)
+		}
+		*field(configurationManager) = duration
+		return nil
 	}
 }
@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// ErrShuttingDown is reported by readiness once the application started draining
+var ErrShuttingDown = errors.New("shutting down")
+
+// Check reports a component as healthy by returning nil, it must honor ctx cancellation
+type Check func(ctx context.Context) error
+
+type ComponentStatus struct {
+	Name    string
+	Status  string
+	Latency time.Duration
+	Error   string
+}
+
+type Report struct {
+	Status     string
+	Components []ComponentStatus
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Registry holds the readiness checks and a flag that graceful shutdown turns off
+type Registry struct {
+	timeout  time.Duration
+	mutex    sync.RWMutex
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// !NewRegistry each check gets at most timeout to answer
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// !Register adds a readiness check, components are reported in registration order
+func (registry *Registry) Register(name string, check Check) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.checks = append(registry.checks, namedCheck{name: name, check: check})
+}
+
+// !MarkDraining makes readiness fail from now on so load balancers stop routing new traffic here
+func (registry *Registry) MarkDraining() {
+	registry.draining.Store(true)
+}
+
+// !Readiness runs every check concurrently, the report is up only when all of them are
+func (registry *Registry) Readiness(ctx context.Context) Report {
+	registry.mutex.RLock()
+	checks := append([]namedCheck(nil), registry.checks...)
+	registry.mutex.RUnlock()
+
+	components := make([]ComponentStatus, len(checks))
+	var waitGroup sync.WaitGroup
+	for index, check := range checks {
+		waitGroup.Add(1)
+		go func(index int, check namedCheck) {
+			defer waitGroup.Done()
+			components[index] = registry.run(ctx, check)
+		}(index, check)
+	}
+	waitGroup.Wait()
+
+	report := Report{Status: StatusUp, Components: components}
+	if registry.draining.Load() {
+		report.Status = StatusDown
+		report.Components = append([]ComponentStatus{{Name: "lifecycle", Status: StatusDown, Error: ErrShuttingDown.Error()}}, components...)
+	}
+	for _, component := range components {
+		if component.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// *run gives up on checks that ignore their context once the timeout passes
+func (registry *Registry) run(ctx context.Context, check namedCheck) ComponentStatus {
+	checkCtx, cancel := context.WithTimeout(ctx, registry.timeout)
+	defer cancel()
+
+	startedAt := time.Now()
+	checkErr := make(chan error, 1)
+	go func() {
+		checkErr <- check.check(checkCtx)
+	}()
+	var err error
+	select {
+	case err = <-checkErr:
+	case <-checkCtx.Done():
+		err = checkCtx.Err()
+	}
+	componentStatus := ComponentStatus{Name: check.name, Status: StatusUp, Latency: time.Since(startedAt)}
+	if err != nil {
+		componentStatus.Status = StatusDown
+		componentStatus.Error = err.Error()
+	}
+	return componentStatus
+}
@@ -0,0 +1,111 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"product-app/common/tracing"
+	"slices"
+	"strings"
+)
+
+// Formats are the supported log output formats
+var Formats = []string{"json", "text"}
+
+type Config struct {
+	Format string `yaml:"format"`
+	Level  string `yaml:"level"`
+}
+
+// !Validate
+func (config Config) Validate() error {
+	if !slices.Contains(Formats, config.Format) {
+		return fmt.Errorf("log format must be one of %s, got %q", strings.Join(Formats, ", "), config.Format)
+	}
+	if _, err := parseLevel(config.Level); err != nil {
+		return fmt.Errorf("log level must be one of debug, info, warn, error, got %q", config.Level)
+	}
+	return nil
+}
+
+// !NewLogger builds a logger whose lines carry the request id of the context they are logged with
+func NewLogger(config Config, writer io.Writer) (*slog.Logger, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	level, _ := parseLevel(config.Level)
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if config.Format == "json" {
+		handler = slog.NewJSONHandler(writer, options)
+	} else {
+		handler = slog.NewTextHandler(writer, options)
+	}
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// !Discard is a logger for tests and tools that do not want any output
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+type requestIdKey struct{}
+
+// !WithRequestId
+func WithRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+// !RequestId returns an empty string outside of a request
+func RequestId(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
+
+type actorKey struct{}
+
+// !WithActor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// !Actor returns an empty string when nobody was named as the actor
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// *contextHandler adds the request id, actor and trace id found in the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (handler *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestId := RequestId(ctx); len(requestId) > 0 {
+		record.AddAttrs(slog.String("request_id", requestId))
+	}
+	if actor := Actor(ctx); len(actor) > 0 {
+		record.AddAttrs(slog.String("actor", actor))
+	}
+	if span := tracing.SpanFromContext(ctx); span != nil {
+		record.AddAttrs(slog.String("trace_id", span.SpanContext().TraceId.String()))
+	}
+	return handler.Handler.Handle(ctx, record)
+}
+
+func (handler *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: handler.Handler.WithAttrs(attrs)}
+}
+
+func (handler *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: handler.Handler.WithGroup(name)}
+}
+
+// ?parseLevel
+func parseLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	err := parsed.UnmarshalText([]byte(level))
+	return parsed, err
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// HTTPMetrics are recorded once per request by the controller middleware
+type HTTPMetrics struct {
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+// !NewHTTPMetrics
+func NewHTTPMetrics(registry *Registry) *HTTPMetrics {
+	return &HTTPMetrics{
+		requests: registry.NewCounterVec("http_requests_total", "HTTP requests by route and status class.", "method", "route", "status_class"),
+		duration: registry.NewHistogramVec("http_request_duration_seconds", "HTTP request latency by route.", DefaultDurationBuckets, "method", "route"),
+	}
+}
+
+// !Observe route must be the route pattern, never the raw path, to keep the number of series bounded
+func (httpMetrics *HTTPMetrics) Observe(method string, route string, status int, duration time.Duration) {
+	httpMetrics.requests.Inc(method, route, statusClass(status))
+	httpMetrics.duration.Observe(duration.Seconds(), method, route)
+}
+
+// QueryMetrics are recorded per repository method
+type QueryMetrics struct {
+	duration *HistogramVec
+	errors   *CounterVec
+}
+
+// !NewQueryMetrics
+func NewQueryMetrics(registry *Registry) *QueryMetrics {
+	return &QueryMetrics{
+		duration: registry.NewHistogramVec("db_query_duration_seconds", "Repository call latency by method.", DefaultDurationBuckets, "method"),
+		errors:   registry.NewCounterVec("db_query_errors_total", "Failed repository calls by method.", "method"),
+	}
+}
+
+// !Observe
+func (queryMetrics *QueryMetrics) Observe(method string, duration time.Duration, failed bool) {
+	queryMetrics.duration.Observe(duration.Seconds(), method)
+	if failed {
+		queryMetrics.errors.Inc(method)
+	}
+}
+
+// ?statusClass
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return strconv.Itoa(status)
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
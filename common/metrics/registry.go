@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the Prometheus text exposition format version this package writes
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultDurationBuckets are upper bounds in seconds suited to HTTP requests and database queries
+var DefaultDurationBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// *family is one metric name with its HELP and TYPE lines
+type family interface {
+	write(writer *bufio.Writer)
+}
+
+// Registry holds every metric family and renders them in registration order
+type Registry struct {
+	mutex    sync.Mutex
+	names    map[string]bool
+	families []family
+}
+
+// !NewRegistry
+func NewRegistry() *Registry {
+	return &Registry{names: map[string]bool{}}
+}
+
+// !Write renders all families in the Prometheus text format
+func (registry *Registry) Write(writer io.Writer) error {
+	registry.mutex.Lock()
+	families := append([]family(nil), registry.families...)
+	registry.mutex.Unlock()
+
+	bufferedWriter := bufio.NewWriter(writer)
+	for _, family := range families {
+		family.write(bufferedWriter)
+	}
+	return bufferedWriter.Flush()
+}
+
+// *register panics on duplicate names since that is always a wiring mistake
+func (registry *Registry) register(name string, family family) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if registry.names[name] {
+		panic(fmt.Sprintf("metric %s registered twice", name))
+	}
+	registry.names[name] = true
+	registry.families = append(registry.families, family)
+}
+
+// CounterVec is a monotonically increasing value per label combination
+type CounterVec struct {
+	name       string
+	help       string
+	labelNames []string
+	mutex      sync.Mutex
+	values     map[string]float64
+}
+
+// !NewCounterVec
+func (registry *Registry) NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	counterVec := &CounterVec{name: name, help: help, labelNames: labelNames, values: map[string]float64{}}
+	registry.register(name, counterVec)
+	return counterVec
+}
+
+// !Inc adds one to the series identified by labelValues, given in the order of the label names
+func (counterVec *CounterVec) Inc(labelValues ...string) {
+	counterVec.Add(1, labelValues...)
+}
+
+// !Add
+func (counterVec *CounterVec) Add(value float64, labelValues ...string) {
+	key := seriesKey(counterVec.labelNames, labelValues)
+	counterVec.mutex.Lock()
+	defer counterVec.mutex.Unlock()
+	counterVec.values[key] += value
+}
+
+func (counterVec *CounterVec) write(writer *bufio.Writer) {
+	writeHeader(writer, counterVec.name, counterVec.help, "counter")
+	counterVec.mutex.Lock()
+	defer counterVec.mutex.Unlock()
+	for _, key := range sortedKeys(counterVec.values) {
+		writeSample(writer, counterVec.name, key, counterVec.values[key])
+	}
+}
+
+// HistogramVec counts observations into cumulative buckets per label combination
+type HistogramVec struct {
+	name       string
+	help       string
+	labelNames []string
+	buckets    []float64
+	mutex      sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	bucketCounts []uint64
+	count        uint64
+	sum          float64
+}
+
+// !NewHistogramVec buckets are upper bounds in increasing order, +Inf is implied
+func (registry *Registry) NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Sprintf("buckets of %s must be sorted", name))
+	}
+	histogramVec := &HistogramVec{name: name, help: help, labelNames: labelNames, buckets: buckets, series: map[string]*histogramSeries{}}
+	registry.register(name, histogramVec)
+	return histogramVec
+}
+
+// !Observe
+func (histogramVec *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := seriesKey(histogramVec.labelNames, labelValues)
+	histogramVec.mutex.Lock()
+	defer histogramVec.mutex.Unlock()
+
+	series, ok := histogramVec.series[key]
+	if !ok {
+		series = &histogramSeries{bucketCounts: make([]uint64, len(histogramVec.buckets))}
+		histogramVec.series[key] = series
+	}
+	for index, upperBound := range histogramVec.buckets {
+		if value <= upperBound {
+			series.bucketCounts[index]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (histogramVec *HistogramVec) write(writer *bufio.Writer) {
+	writeHeader(writer, histogramVec.name, histogramVec.help, "histogram")
+	histogramVec.mutex.Lock()
+	defer histogramVec.mutex.Unlock()
+
+	keys := make([]string, 0, len(histogramVec.series))
+	for key := range histogramVec.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		series := histogramVec.series[key]
+		for index, upperBound := range histogramVec.buckets {
+			writeSample(writer, histogramVec.name+"_bucket", withLabel(key, "le", formatValue(upperBound)), float64(series.bucketCounts[index]))
+		}
+		writeSample(writer, histogramVec.name+"_bucket", withLabel(key, "le", "+Inf"), float64(series.count))
+		writeSample(writer, histogramVec.name+"_sum", key, series.sum)
+		writeSample(writer, histogramVec.name+"_count", key, float64(series.count))
+	}
+}
+
+// funcMetric reads its value at scrape time, for numbers owned by someone else such as pool statistics
+type funcMetric struct {
+	name       string
+	help       string
+	metricType string
+	value      func() float64
+}
+
+// !NewGaugeFunc
+func (registry *Registry) NewGaugeFunc(name string, help string, value func() float64) {
+	registry.register(name, &funcMetric{name: name, help: help, metricType: "gauge", value: value})
+}
+
+// !NewCounterFunc value must never decrease
+func (registry *Registry) NewCounterFunc(name string, help string, value func() float64) {
+	registry.register(name, &funcMetric{name: name, help: help, metricType: "counter", value: value})
+}
+
+func (metric *funcMetric) write(writer *bufio.Writer) {
+	writeHeader(writer, metric.name, metric.help, metric.metricType)
+	writeSample(writer, metric.name, "", metric.value())
+}
+
+// ?seriesKey renders the label set once so it doubles as map key and output
+func seriesKey(labelNames []string, labelValues []string) string {
+	if len(labelNames) != len(labelValues) {
+		panic(fmt.Sprintf("expected %d label values, got %d", len(labelNames), len(labelValues)))
+	}
+	pairs := make([]string, len(labelNames))
+	for index, labelName := range labelNames {
+		pairs[index] = labelName + `="` + escapeLabelValue(labelValues[index]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ?withLabel
+func withLabel(key string, labelName string, labelValue string) string {
+	pair := labelName + `="` + labelValue + `"`
+	if len(key) == 0 {
+		return pair
+	}
+	return key + "," + pair
+}
+
+// ?writeHeader
+func writeHeader(writer *bufio.Writer, name string, help string, metricType string) {
+	fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help), name, metricType)
+}
+
+// ?writeSample
+func writeSample(writer *bufio.Writer, name string, key string, value float64) {
+	if len(key) > 0 {
+		fmt.Fprintf(writer, "%s{%s} %s\n", name, key, formatValue(value))
+		return
+	}
+	fmt.Fprintf(writer, "%s %s\n", name, formatValue(value))
+}
+
+// ?formatValue
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// ?escapeLabelValue
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// ?sortedKeys
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,11 +1,48 @@
 package postgresql
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type Config struct {
-	Host                  string
-	Port                  string
-	UserName              string
-	Password              string
-	DbName                string
-	MaxConnections        string
-	MaxConnectionIdleTime string
+	Host                  string        `yaml:"host"`
+	Port                  int           `yaml:"port"`
+	UserName              string        `yaml:"userName"`
+	Password              string        `yaml:"password"`
+	PasswordFile          string        `yaml:"passwordFile"`
+	DbName                string        `yaml:"dbName"`
+	SslMode               string        `yaml:"sslMode"`
+	ConnectTimeout        time.Duration `yaml:"connectTimeout"`
+	ConnectRetryBudget    time.Duration `yaml:"connectRetryBudget"`
+	MaxConnections        int           `yaml:"maxConnections"`
+	MaxConnectionIdleTime time.Duration `yaml:"maxConnectionIdleTime"`
+}
+
+// SslModes are the sslmode values libpq understands
+var SslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// ConnectionString renders the config as a keyword/value DSN, quoting every value so passwords may contain spaces or quotes
+func (config Config) ConnectionString() string {
+	options := []string{
+		"host=" + quoteDsnValue(config.Host),
+		fmt.Sprintf("port=%d", config.Port),
+		"user=" + quoteDsnValue(config.UserName),
+		"password=" + quoteDsnValue(config.Password),
+		"dbname=" + quoteDsnValue(config.DbName),
+		"sslmode=" + quoteDsnValue(config.SslMode),
+		"statement_cache_mode=describe",
+		fmt.Sprintf("pool_max_conns=%d", config.MaxConnections),
+		"pool_max_conn_idle_time=" + config.MaxConnectionIdleTime.String(),
+	}
+	if config.ConnectTimeout > 0 {
+		options = append(options, fmt.Sprintf("connect_timeout=%d", int(config.ConnectTimeout.Round(time.Second).Seconds())))
+	}
+	return strings.Join(options, " ")
+}
+
+// ?quoteDsnValue
+func quoteDsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
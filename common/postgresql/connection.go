@@ -3,31 +3,36 @@ package postgresql
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/labstack/gommon/log"
 )
 
-func GetConnectionPool(context context.Context, config Config) *pgxpool.Pool {
-	connString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable statement_cache_mode=describe pool_max_conns=%s pool_max_conn_idle_time=%s",
-		config.Host,
-		config.Port,
-		config.UserName,
-		config.Password,
-		config.DbName,
-		config.MaxConnections,
-		config.MaxConnectionIdleTime)
-
-	connConfig, parseConfigErr := pgxpool.ParseConfig(connString)
+// NewConnectionPool builds the pool without touching the network, only an invalid config fails here.
+// Connections are opened on first use, call WaitForDatabase to find out whether the database is reachable.
+func NewConnectionPool(config Config) (*pgxpool.Pool, error) {
+	connConfig, parseConfigErr := pgxpool.ParseConfig(config.ConnectionString())
 	if parseConfigErr != nil {
-		panic(parseConfigErr)
+		return nil, fmt.Errorf("Invalid database configuration: %w", parseConfigErr)
 	}
+	connConfig.LazyConnect = true
 
-	conn, err := pgxpool.ConnectConfig(context, connConfig)
+	pool, err := pgxpool.ConnectConfig(context.Background(), connConfig)
 	if err != nil {
-		log.Error("Unable to connect to database: %v\n", err)
-		panic(err)
+		return nil, fmt.Errorf("Unable to create connection pool: %w", err)
 	}
+	return pool, nil
+}
 
-	return conn
+// WaitForDatabase pings until the database answers or the retry budget is spent
+func WaitForDatabase(ctx context.Context, pool *pgxpool.Pool, retryPolicy RetryPolicy, logger *slog.Logger) error {
+	return Retry(ctx, retryPolicy, func(ctx context.Context, attempt int) error {
+		err := pool.Ping(ctx)
+		if err != nil {
+			logger.WarnContext(ctx, "Database connection attempt failed", "attempt", attempt, "error", err)
+		} else {
+			logger.InfoContext(ctx, "Database connection established", "attempts", attempt)
+		}
+		return err
+	})
 }
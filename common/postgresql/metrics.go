@@ -0,0 +1,32 @@
+package postgresql
+
+import (
+	"product-app/common/metrics"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RegisterPoolMetrics exposes pgxpool statistics, read from the pool at scrape time
+func RegisterPoolMetrics(registry *metrics.Registry, pool *pgxpool.Pool) {
+	registry.NewGaugeFunc("db_pool_acquired_connections", "Connections currently checked out of the pool.", func() float64 {
+		return float64(pool.Stat().AcquiredConns())
+	})
+	registry.NewGaugeFunc("db_pool_idle_connections", "Idle connections in the pool.", func() float64 {
+		return float64(pool.Stat().IdleConns())
+	})
+	registry.NewGaugeFunc("db_pool_total_connections", "Open connections, acquired, idle and being established.", func() float64 {
+		return float64(pool.Stat().TotalConns())
+	})
+	registry.NewGaugeFunc("db_pool_max_connections", "Configured maximum size of the pool.", func() float64 {
+		return float64(pool.Stat().MaxConns())
+	})
+	registry.NewCounterFunc("db_pool_acquires_total", "Successful connection acquisitions.", func() float64 {
+		return float64(pool.Stat().AcquireCount())
+	})
+	registry.NewCounterFunc("db_pool_empty_acquires_total", "Acquisitions that had to wait because no idle connection was available.", func() float64 {
+		return float64(pool.Stat().EmptyAcquireCount())
+	})
+	registry.NewCounterFunc("db_pool_acquire_wait_seconds_total", "Total time spent waiting to acquire connections.", func() float64 {
+		return pool.Stat().AcquireDuration().Seconds()
+	})
+}
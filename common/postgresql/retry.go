@@ -0,0 +1,61 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy backs off exponentially between attempts, never sleeping past Budget in total.
+// A zero Budget means a single attempt.
+type RetryPolicy struct {
+	Budget         time.Duration
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// !DefaultRetryPolicy
+func DefaultRetryPolicy(budget time.Duration) RetryPolicy {
+	return RetryPolicy{
+		Budget:         budget,
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+	}
+}
+
+// Retry calls operation until it succeeds, ctx is done or the next sleep would exceed the budget
+func Retry(ctx context.Context, retryPolicy RetryPolicy, operation func(ctx context.Context, attempt int) error) error {
+	startedAt := time.Now()
+	backoff := retryPolicy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := operation(ctx, attempt)
+		if err == nil {
+			return nil
+		}
+
+		sleep := withJitter(backoff)
+		if time.Since(startedAt)+sleep > retryPolicy.Budget {
+			return fmt.Errorf("Giving up after %d attempt(s) in %s: %w", attempt, time.Since(startedAt).Round(time.Millisecond), err)
+		}
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(ctx.Err(), err)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, retryPolicy.MaxBackoff)
+	}
+}
+
+// ?withJitter keeps half of the backoff and randomizes the rest so restarted instances do not retry in lockstep
+func withJitter(backoff time.Duration) time.Duration {
+	half := backoff / 2
+	if half <= 0 {
+		return backoff
+	}
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
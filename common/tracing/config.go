@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Exporters are the supported values of Config.Exporter
+var Exporters = []string{"none", "stdout", "otlp"}
+
+type Config struct {
+	Exporter      string        `yaml:"exporter"`
+	OtlpEndpoint  string        `yaml:"otlpEndpoint"`
+	ServiceName   string        `yaml:"serviceName"`
+	FlushInterval time.Duration `yaml:"flushInterval"`
+}
+
+// !Validate
+func (config Config) Validate() error {
+	var errs []error
+	if !slices.Contains(Exporters, config.Exporter) {
+		errs = append(errs, fmt.Errorf("trace exporter must be one of %s, got %q", strings.Join(Exporters, ", "), config.Exporter))
+	}
+	if config.Exporter == "otlp" {
+		endpoint, err := url.Parse(config.OtlpEndpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || len(endpoint.Host) == 0 {
+			errs = append(errs, fmt.Errorf("trace otlp endpoint must be an http(s) URL, got %q", config.OtlpEndpoint))
+		}
+		if config.FlushInterval <= 0 {
+			errs = append(errs, fmt.Errorf("trace flush interval must be positive, got %s", config.FlushInterval))
+		}
+	}
+	if len(config.ServiceName) == 0 {
+		errs = append(errs, errors.New("trace service name is required"))
+	}
+	return errors.Join(errs...)
+}
+
+// !NewTracerFromConfig returns a nil tracer, which traces nothing, for the none exporter
+func NewTracerFromConfig(config Config, stdout io.Writer, logger *slog.Logger) (*Tracer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	switch config.Exporter {
+	case "stdout":
+		return NewTracer(NewWriterExporter(stdout)), nil
+	case "otlp":
+		return NewTracer(NewOTLPExporter(config.OtlpEndpoint, config.ServiceName, config.FlushInterval, logger)), nil
+	}
+	return nil, nil
+}
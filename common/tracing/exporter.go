@@ -0,0 +1,282 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// *jsonSpan is the line written by the stdout exporter
+type jsonSpan struct {
+	TraceId      string                 `json:"trace_id"`
+	SpanId       string                 `json:"span_id"`
+	ParentSpanId string                 `json:"parent_span_id,omitempty"`
+	Name         string                 `json:"name"`
+	Kind         string                 `json:"kind"`
+	Start        time.Time              `json:"start"`
+	DurationMs   float64                `json:"duration_ms"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+}
+
+// WriterExporter writes one JSON object per span, stdout in practice
+type WriterExporter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// !NewWriterExporter
+func NewWriterExporter(writer io.Writer) *WriterExporter {
+	return &WriterExporter{writer: writer}
+}
+
+func (writerExporter *WriterExporter) Export(span SpanData) {
+	line := jsonSpan{
+		TraceId:    span.SpanContext.TraceId.String(),
+		SpanId:     span.SpanContext.SpanId.String(),
+		Name:       span.Name,
+		Kind:       kindName(span.Kind),
+		Start:      span.StartTime,
+		DurationMs: float64(span.EndTime.Sub(span.StartTime).Microseconds()) / 1000,
+		Attributes: map[string]interface{}{},
+	}
+	if span.ParentSpanId.IsValid() {
+		line.ParentSpanId = span.ParentSpanId.String()
+	}
+	for _, attribute := range span.Attributes {
+		line.Attributes[attribute.Key] = attribute.Value
+	}
+	if span.Failed {
+		line.Error = span.StatusMessage
+	}
+
+	encoded, _ := json.Marshal(line)
+	writerExporter.mutex.Lock()
+	defer writerExporter.mutex.Unlock()
+	writerExporter.writer.Write(append(encoded, '\n'))
+}
+
+func (writerExporter *WriterExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// OTLPExporter batches spans and posts them as OTLP/HTTP JSON to <endpoint>/v1/traces
+type OTLPExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+	logger      *slog.Logger
+	batchSize   int
+	spans       chan SpanData
+	flushNow    chan chan struct{}
+	stopped     chan struct{}
+}
+
+// !NewOTLPExporter starts the background sender, spans are dropped rather than blocking when the queue is full
+func NewOTLPExporter(endpoint string, serviceName string, flushInterval time.Duration, logger *slog.Logger) *OTLPExporter {
+	otlpExporter := &OTLPExporter{
+		endpoint:    strings.TrimRight(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		logger:      logger,
+		batchSize:   512,
+		spans:       make(chan SpanData, 2048),
+		flushNow:    make(chan chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+	go otlpExporter.run(flushInterval)
+	return otlpExporter
+}
+
+func (otlpExporter *OTLPExporter) Export(span SpanData) {
+	select {
+	case otlpExporter.spans <- span:
+	default:
+		otlpExporter.logger.Warn("Trace export queue is full, dropping span", "span", span.Name)
+	}
+}
+
+// !Shutdown sends whatever is queued and stops the sender
+func (otlpExporter *OTLPExporter) Shutdown(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case otlpExporter.flushNow <- flushed:
+	case <-otlpExporter.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// *run sends a batch when it is full or the interval passed, whichever comes first
+func (otlpExporter *OTLPExporter) run(flushInterval time.Duration) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []SpanData
+	send := func() {
+		if len(batch) > 0 {
+			otlpExporter.send(batch)
+			batch = nil
+		}
+	}
+	for {
+		select {
+		case span := <-otlpExporter.spans:
+			batch = append(batch, span)
+			if len(batch) >= otlpExporter.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case flushed := <-otlpExporter.flushNow:
+			for drained := false; !drained; {
+				select {
+				case span := <-otlpExporter.spans:
+					batch = append(batch, span)
+				default:
+					drained = true
+				}
+			}
+			send()
+			close(otlpExporter.stopped)
+			close(flushed)
+			return
+		}
+	}
+}
+
+// *send
+func (otlpExporter *OTLPExporter) send(batch []SpanData) {
+	body, _ := json.Marshal(toOTLPRequest(otlpExporter.serviceName, batch))
+	response, err := otlpExporter.client.Post(otlpExporter.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		otlpExporter.logger.Warn("Unable to export spans", "spans", len(batch), "error", err)
+		return
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode >= http.StatusMultipleChoices {
+		otlpExporter.logger.Warn("Trace collector rejected spans", "spans", len(batch), "status", response.StatusCode)
+	}
+}
+
+// OTLP JSON encoding, see opentelemetry-proto's ExportTraceServiceRequest. Ids are hex and 64 bit integers are strings.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// ?toOTLPRequest
+func toOTLPRequest(serviceName string, batch []SpanData) otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		encoded := otlpSpan{
+			TraceId:           span.SpanContext.TraceId.String(),
+			SpanId:            span.SpanContext.SpanId.String(),
+			Name:              span.Name,
+			Kind:              int(span.Kind),
+			StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			Attributes:        toOTLPAttributes(span.Attributes),
+			Status:            otlpStatus{Code: 1},
+		}
+		if span.ParentSpanId.IsValid() {
+			encoded.ParentSpanId = span.ParentSpanId.String()
+		}
+		if span.Failed {
+			encoded.Status = otlpStatus{Code: 2, Message: span.StatusMessage}
+		}
+		spans = append(spans, encoded)
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: toOTLPAttributes([]Attribute{String("service.name", serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: serviceName}, Spans: spans}},
+	}}}
+}
+
+// ?toOTLPAttributes
+func toOTLPAttributes(attributes []Attribute) []otlpAttribute {
+	var encoded []otlpAttribute
+	for _, attribute := range attributes {
+		var value map[string]interface{}
+		switch typed := attribute.Value.(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": typed}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(typed, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": typed}
+		case bool:
+			value = map[string]interface{}{"boolValue": typed}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(typed)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: attribute.Key, Value: value})
+	}
+	return encoded
+}
+
+// ?kindName
+func kindName(kind SpanKind) string {
+	switch kind {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	}
+	return "internal"
+}
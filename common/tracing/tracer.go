@@ -0,0 +1,241 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TraceId [16]byte
+
+type SpanId [8]byte
+
+func (traceId TraceId) String() string { return hex.EncodeToString(traceId[:]) }
+
+func (traceId TraceId) IsValid() bool { return traceId != TraceId{} }
+
+func (spanId SpanId) String() string { return hex.EncodeToString(spanId[:]) }
+
+func (spanId SpanId) IsValid() bool { return spanId != SpanId{} }
+
+// SpanContext is the part of a span that crosses process boundaries
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Sampled bool
+}
+
+// !ParseTraceparent reads a W3C traceparent header, version 00 only, anything malformed is rejected
+func ParseTraceparent(header string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	var spanContext SpanContext
+	var flags [1]byte
+	if _, err := hex.Decode(spanContext.TraceId[:], []byte(parts[1])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(spanContext.SpanId[:], []byte(parts[2])); err != nil {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}, false
+	}
+	if !spanContext.TraceId.IsValid() || !spanContext.SpanId.IsValid() {
+		return SpanContext{}, false
+	}
+	spanContext.Sampled = flags[0]&1 == 1
+	return spanContext, true
+}
+
+// !Traceparent renders the span context as a W3C traceparent header value
+func (spanContext SpanContext) Traceparent() string {
+	flags := "00"
+	if spanContext.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", spanContext.TraceId, spanContext.SpanId, flags)
+}
+
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Attribute values are strings, int64, float64 or bool, the types OTLP can carry
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key string, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+func Int64(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// SpanData is what exporters receive once a span ended
+type SpanData struct {
+	SpanContext   SpanContext
+	ParentSpanId  SpanId
+	Name          string
+	Kind          SpanKind
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    []Attribute
+	Failed        bool
+	StatusMessage string
+}
+
+// Exporter ships ended spans somewhere, Export must not block the request path for long
+type Exporter interface {
+	Export(span SpanData)
+	Shutdown(ctx context.Context) error
+}
+
+// Span is safe to use when nil, which is what a disabled tracer hands out
+type Span struct {
+	tracer *Tracer
+	mutex  sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// !SetAttributes
+func (span *Span) SetAttributes(attributes ...Attribute) {
+	if span == nil {
+		return
+	}
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.data.Attributes = append(span.data.Attributes, attributes...)
+}
+
+// !RecordError marks the span failed, nil errors are ignored
+func (span *Span) RecordError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.mutex.Lock()
+	defer span.mutex.Unlock()
+	span.data.Failed = true
+	span.data.StatusMessage = err.Error()
+}
+
+// !SpanContext
+func (span *Span) SpanContext() SpanContext {
+	if span == nil {
+		return SpanContext{}
+	}
+	return span.data.SpanContext
+}
+
+// !End exports the span once, later calls do nothing
+func (span *Span) End() {
+	if span == nil {
+		return
+	}
+	span.mutex.Lock()
+	if span.ended {
+		span.mutex.Unlock()
+		return
+	}
+	span.ended = true
+	span.data.EndTime = time.Now()
+	data := span.data
+	span.mutex.Unlock()
+
+	if data.SpanContext.Sampled {
+		span.tracer.exporter.Export(data)
+	}
+}
+
+// Tracer creates spans and hands them to its exporter, a nil Tracer creates nil spans
+type Tracer struct {
+	exporter Exporter
+}
+
+// !NewTracer a nil exporter disables tracing
+func NewTracer(exporter Exporter) *Tracer {
+	if exporter == nil {
+		return nil
+	}
+	return &Tracer{exporter: exporter}
+}
+
+type spanKey struct{}
+
+type remoteParentKey struct{}
+
+// !ContextWithRemoteParent makes the next span started from ctx a child of a span in another process
+func ContextWithRemoteParent(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteParentKey{}, parent)
+}
+
+// !SpanFromContext returns nil outside of a traced operation
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// !Start begins a span that is a child of the span in ctx, or of the remote parent, or a new root
+func (tracer *Tracer) Start(ctx context.Context, name string, kind SpanKind, attributes ...Attribute) (context.Context, *Span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+
+	spanContext := SpanContext{SpanId: newSpanId(), Sampled: true}
+	var parentSpanId SpanId
+	if parent := SpanFromContext(ctx); parent != nil {
+		spanContext.TraceId, spanContext.Sampled, parentSpanId = parent.data.SpanContext.TraceId, parent.data.SpanContext.Sampled, parent.data.SpanContext.SpanId
+	} else if remoteParent, ok := ctx.Value(remoteParentKey{}).(SpanContext); ok {
+		spanContext.TraceId, spanContext.Sampled, parentSpanId = remoteParent.TraceId, remoteParent.Sampled, remoteParent.SpanId
+	} else {
+		spanContext.TraceId = newTraceId()
+	}
+
+	span := &Span{
+		tracer: tracer,
+		data: SpanData{
+			SpanContext:  spanContext,
+			ParentSpanId: parentSpanId,
+			Name:         name,
+			Kind:         kind,
+			StartTime:    time.Now(),
+			Attributes:   attributes,
+		},
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// !Shutdown flushes the exporter
+func (tracer *Tracer) Shutdown(ctx context.Context) error {
+	if tracer == nil {
+		return nil
+	}
+	return tracer.exporter.Shutdown(ctx)
+}
+
+// ?newTraceId
+func newTraceId() TraceId {
+	var traceId TraceId
+	rand.Read(traceId[:])
+	return traceId
+}
+
+// ?newSpanId
+func newSpanId() SpanId {
+	var spanId SpanId
+	rand.Read(spanId[:])
+	return spanId
+}
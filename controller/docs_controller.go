@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"net/http"
+	"product-app/controller/openapi"
+
+	"github.com/labstack/echo/v4"
+)
+
+type DocsController struct{}
+
+func NewDocsController() *DocsController {
+	return &DocsController{}
+}
+
+func (docsController *DocsController) RegisterRoutes(e *echo.Echo) {
+	e.GET("/api/v1/openapi.json", docsController.Spec)
+	e.GET("/api/v1/docs", docsController.Docs)
+}
+
+// Spec serves the embedded document as is, it is written by hand next to the controllers
+func (docsController *DocsController) Spec(c echo.Context) error {
+	return c.Blob(http.StatusOK, echo.MIMEApplicationJSONCharsetUTF8, openapi.Spec)
+}
+
+// Docs serves the page rendering the document, it is resolved relative to /api/v1/
+func (docsController *DocsController) Docs(c echo.Context) error {
+	return c.HTMLBlob(http.StatusOK, openapi.DocsPage)
+}
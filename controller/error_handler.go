@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"product-app/common/tracing"
+	"product-app/controller/request"
+	"product-app/controller/response"
+	"product-app/domain"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// StatusClientClosedRequest is the non standard status nginx logs for requests the client gave up on. Nobody reads
+// the response, it keeps cancelled requests apart from server errors in logs and metrics.
+const StatusClientClosedRequest = 499
+
+const (
+	CodeBadRequest         = "BAD_REQUEST"
+	CodeNotFound           = "NOT_FOUND"
+	CodeValidationFailed   = "VALIDATION_FAILED"
+	CodeConflict           = "CONFLICT"
+	CodePreconditionFailed = "PRECONDITION_FAILED"
+	CodeUnavailable        = "UNAVAILABLE"
+	CodeClientClosed       = "CLIENT_CLOSED_REQUEST"
+	CodeInternalError      = "INTERNAL_ERROR"
+)
+
+// !NewHTTPErrorHandler maps domain errors returned by handlers onto status codes and a structured ErrorResponse.
+// The failed request itself is logged by RequestLogger, together with the status chosen here.
+func NewHTTPErrorHandler(logger *slog.Logger) echo.HTTPErrorHandler {
+	return func(err error, c echo.Context) {
+		if c.Response().Committed {
+			return
+		}
+
+		status, errorResponse := toErrorResponse(err)
+		if status >= http.StatusInternalServerError {
+			tracing.SpanFromContext(c.Request().Context()).RecordError(err)
+		}
+
+		var writeErr error
+		if c.Request().Method == http.MethodHead {
+			writeErr = c.NoContent(status)
+		} else {
+			writeErr = c.JSON(status, errorResponse)
+		}
+		if writeErr != nil {
+			logger.ErrorContext(c.Request().Context(), "Failed to write error response", "error", writeErr)
+		}
+	}
+}
+
+// *toErrorResponse
+func toErrorResponse(err error) (int, response.ErrorResponse) {
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		return httpError.Code, response.ErrorResponse{
+			Code:             codeForStatus(httpError.Code),
+			ErrorDescription: fmt.Sprint(httpError.Message),
+		}
+	}
+
+	switch {
+	case errors.Is(err, request.ErrMalformedRequest):
+		return http.StatusBadRequest, newErrorResponse(CodeBadRequest, err)
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound, newErrorResponse(CodeNotFound, err)
+	case errors.Is(err, domain.ErrValidation):
+		return http.StatusUnprocessableEntity, newErrorResponse(CodeValidationFailed, err)
+	case errors.Is(err, domain.ErrConflict):
+		return http.StatusConflict, newErrorResponse(CodeConflict, err)
+	case errors.Is(err, domain.ErrVersionMismatch):
+		return http.StatusPreconditionFailed, newErrorResponse(CodePreconditionFailed, err)
+	case errors.Is(err, domain.ErrUnavailable), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable, newErrorResponse(CodeUnavailable, err)
+	case errors.Is(err, domain.ErrCanceled), errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest, newErrorResponse(CodeClientClosed, err)
+	}
+	return http.StatusInternalServerError, response.ErrorResponse{
+		Code:             CodeInternalError,
+		ErrorDescription: http.StatusText(http.StatusInternalServerError),
+	}
+}
+
+// ?newErrorResponse
+func newErrorResponse(code string, err error) response.ErrorResponse {
+	return response.ErrorResponse{
+		Code:             code,
+		ErrorDescription: err.Error(),
+		Details:          response.ToFieldErrorResponseList(domain.FieldErrorsOf(err)),
+	}
+}
+
+// ?codeForStatus
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusPreconditionFailed:
+		return CodePreconditionFailed
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case StatusClientClosedRequest:
+		return CodeClientClosed
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternalError
+	}
+	return strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"net/http"
+	"product-app/common/health"
+	"product-app/controller/response"
+
+	"github.com/labstack/echo/v4"
+)
+
+type HealthController struct {
+	registry *health.Registry
+}
+
+func NewHealthController(registry *health.Registry) *HealthController {
+	return &HealthController{
+		registry: registry,
+	}
+}
+
+func (healthController *HealthController) RegisterRoutes(e *echo.Echo) {
+	e.GET("/healthz", healthController.Liveness)
+	e.GET("/readyz", healthController.Readiness)
+}
+
+// Liveness only says the process is serving requests, it never touches dependencies
+func (healthController *HealthController) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, response.HealthResponse{Status: health.StatusUp})
+}
+
+// Readiness answers 503 while any check fails or the server is draining
+func (healthController *HealthController) Readiness(c echo.Context) error {
+	report := healthController.registry.Readiness(c.Request().Context())
+	statusCode := http.StatusOK
+	if report.Status != health.StatusUp {
+		statusCode = http.StatusServiceUnavailable
+	}
+	return c.JSON(statusCode, response.ToHealthResponse(report))
+}
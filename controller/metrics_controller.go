@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"net/http"
+	"product-app/common/metrics"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// unmatchedRoute labels requests no route matched so arbitrary paths can not create new series
+const unmatchedRoute = "unmatched"
+
+type MetricsController struct {
+	registry *metrics.Registry
+}
+
+func NewMetricsController(registry *metrics.Registry) *MetricsController {
+	return &MetricsController{
+		registry: registry,
+	}
+}
+
+func (metricsController *MetricsController) RegisterRoutes(e *echo.Echo) {
+	e.GET("/metrics", metricsController.Metrics)
+}
+
+func (metricsController *MetricsController) Metrics(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, metrics.ContentType)
+	c.Response().WriteHeader(http.StatusOK)
+	return metricsController.registry.Write(c.Response())
+}
+
+// !RequestMetrics records count and latency per route, it must be registered before RequestLogger so the
+// status it sees is the one the error handler wrote
+func RequestMetrics(httpMetrics *metrics.HTTPMetrics) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			startedAt := time.Now()
+			if err := next(c); err != nil {
+				c.Error(err)
+			}
+
+			route := c.Path()
+			if len(route) == 0 || c.Response().Status == http.StatusNotFound && route == "/*" {
+				route = unmatchedRoute
+			}
+			httpMetrics.Observe(c.Request().Method, route, c.Response().Status, time.Since(startedAt))
+			return nil
+		}
+	}
+}
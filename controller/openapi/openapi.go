@@ -0,0 +1,14 @@
+// Package openapi bundles the hand-written OpenAPI document and the docs page rendering it
+package openapi
+
+import _ "embed"
+
+// Spec is the OpenAPI 3.1 document, every route registered by the controllers must be described in it
+//
+//go:embed openapi.json
+var Spec []byte
+
+// DocsPage is a self-contained page that fetches Spec and renders its operations, it loads nothing from a CDN
+//
+//go:embed docs.html
+var DocsPage []byte
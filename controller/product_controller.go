@@ -1,11 +1,18 @@
 package controller
 
 import (
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
 	"product-app/controller/request"
 	"product-app/controller/response"
+	"product-app/domain"
 	"product-app/service"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -24,81 +31,452 @@ func (productController *ProductController) RegisterRoutes(e *echo.Echo) {
 	e.GET("/api/v1/products/:id/", productController.ProductById)
 	e.GET("/api/v1/products/", productController.AllProducts)
 	e.POST("/api/v1/products/", productController.Add)
-	e.PUT("/api/v1/products/:id/", productController.UpdateProductPrice)
+	e.PUT("/api/v1/products/:id/", productController.Update)
+	e.PATCH("/api/v1/products/:id/", productController.Patch)
 	e.DELETE("/api/v1/products/:id/", productController.DeleteById)
+	e.GET("/api/v1/products/trash/", productController.DeletedProducts)
+	e.DELETE("/api/v1/products/trash/", productController.PurgeDeleted)
+	e.POST("/api/v1/products/:id/restore/", productController.Restore)
+	e.GET("/api/v1/products/:id/audit/", productController.ProductAudit)
+	e.GET("/api/v1/audit/", productController.Audit)
+	e.GET("/api/v1/products/:id/prices/", productController.PriceHistory)
+	e.POST("/api/v1/products/import/", productController.Import)
+	e.GET("/api/v1/products/export/", productController.Export)
 }
 
 func (productController *ProductController) ProductById(c echo.Context) error {
-	param := c.Param("id")
-	productId, _ := strconv.Atoi(param)
+	productId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
 
-	product, err := productController.productService.ProductById(int64(productId))
+	product, err := productController.productService.ProductById(c.Request().Context(), productId)
 
 	if err != nil {
-		return c.JSON(http.StatusNotFound, response.ErrorResponse{
-			ErrorDescription: err.Error(),
-		})
+		return err
 	}
-	return c.JSON(http.StatusOK, response.ToResponse(product))
+	return productJSON(c, http.StatusOK, product)
 }
 
 func (productController *ProductController) AllProducts(c echo.Context) error {
-	store := c.QueryParam("store")
-	if len(store) == 0 {
-		allProducts := productController.productService.AllProducts()
-		return c.JSON(http.StatusOK, response.ToResponseList(allProducts))
+	query, parseErr := parseProductQuery(c)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	productPage, err := productController.productService.ProductsPage(c.Request().Context(), query)
+	if err != nil {
+		return err
+	}
+
+	links := paginationLinks(c.Request().URL, query, productPage)
+	if len(links) > 0 {
+		c.Response().Header().Set("Link", strings.Join(links, ", "))
 	}
-	productWithGivenStore := productController.productService.ProductsByStore(store)
-	return c.JSON(http.StatusOK, response.ToResponseList(productWithGivenStore))
+	return c.JSON(http.StatusOK, response.ToProductPageResponse(productPage))
 }
 
 func (productController *ProductController) Add(c echo.Context) error {
 	var addProductRequest request.AddProductRequest
-	bindErr := c.Bind(&addProductRequest)
-	if bindErr != nil {
-		return c.JSON(http.StatusBadRequest, response.ErrorResponse{
-			ErrorDescription: bindErr.Error(),
-		})
+	decodeErr := request.DecodeJSON(c, &addProductRequest)
+	if decodeErr != nil {
+		return decodeErr
 	}
-	err := productController.productService.Add(addProductRequest.ToModel())
+	product, err := productController.productService.Add(c.Request().Context(), addProductRequest.ToModel())
 	if err != nil {
-		return c.JSON(http.StatusUnprocessableEntity, response.ErrorResponse{
-			ErrorDescription: err.Error(),
-		})
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/api/v1/products/%d/", product.Id))
+	return productJSON(c, http.StatusCreated, product)
+}
+
+// Update replaces the whole product from the JSON body, requests still carrying newPrice keep the legacy price update
+func (productController *ProductController) Update(c echo.Context) error {
+	if len(c.QueryParam("newPrice")) > 0 {
+		return productController.UpdateProductPrice(c)
+	}
+
+	productId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+	expectedVersion, versionErr := request.ParseIfMatch(c)
+	if versionErr != nil {
+		return versionErr
+	}
+
+	var productRequest request.AddProductRequest
+	decodeErr := request.DecodeJSON(c, &productRequest)
+	if decodeErr != nil {
+		return decodeErr
+	}
+	product, err := productController.productService.Update(c.Request().Context(), productId, productRequest.ToModel(), expectedVersion)
+	if err != nil {
+		return err
+	}
+	return productJSON(c, http.StatusOK, product)
+}
+
+func (productController *ProductController) Patch(c echo.Context) error {
+	productId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+	expectedVersion, versionErr := request.ParseIfMatch(c)
+	if versionErr != nil {
+		return versionErr
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != "application/merge-patch+json" && mediaType != echo.MIMEApplicationJSON {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Patch body must be application/merge-patch+json")
+	}
+
+	body, readErr := request.ReadBody(c)
+	if readErr != nil {
+		return readErr
+	}
+	productPatch, parseErr := request.ParseProductMergePatch(body)
+	if parseErr != nil {
+		return parseErr
 	}
-	return c.JSON(http.StatusCreated, addProductRequest.ToModel())
+
+	product, err := productController.productService.Patch(c.Request().Context(), productId, productPatch, expectedVersion)
+	if err != nil {
+		return err
+	}
+	return productJSON(c, http.StatusOK, product)
+}
+
+// Import adds products from a CSV or NDJSON body. The report lists every row, an all or nothing import that
+// rejected a row answers 422 with the report since nothing was written.
+func (productController *ProductController) Import(c echo.Context) error {
+	mode := c.QueryParam("mode")
+	if len(mode) == 0 {
+		mode = domain.ImportAllOrNothing
+	}
+	rows, parseErr := request.ParseProductImport(c)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	report, err := productController.productService.Import(c.Request().Context(), rows, mode)
+	if err != nil && report.Committed {
+		// Part of a best effort import is already written, so the caller needs the report to know which rows
+		status, errorResponse := toErrorResponse(err)
+		importReportResponse := response.ToImportReportResponse(report)
+		importReportResponse.Error = &errorResponse
+		return c.JSON(status, importReportResponse)
+	}
+	if err != nil {
+		return err
+	}
+	status := http.StatusOK
+	if !report.Committed && report.Rejected > 0 {
+		status = http.StatusUnprocessableEntity
+	}
+	return c.JSON(status, response.ToImportReportResponse(report))
+}
+
+// Export streams every product matching the filters of the listing as CSV, NDJSON or a JSON array. Rows go out
+// while they are read from the database, so once the response is committed a failure can only cut the body short.
+func (productController *ProductController) Export(c echo.Context) error {
+	query, parseErr := parseProductQuery(c)
+	queryParser := request.NewQueryParser(c)
+	format := queryParser.Enum("format", response.ExportFormats, response.ExportJSON)
+	if parseErr != nil {
+		return parseErr
+	}
+	if formatErr := queryParser.Err(); formatErr != nil {
+		return formatErr
+	}
+
+	exporter := response.NewProductExporter(format, &exportStream{c: c, format: format})
+	err := productController.productService.Export(c.Request().Context(), query, exporter.Write)
+	if err != nil {
+		return err
+	}
+	return exporter.Close()
 }
 
 func (productController *ProductController) UpdateProductPrice(c echo.Context) error {
-	param := c.Param("id")
-	productId, _ := strconv.Atoi(param)
+	productId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+	expectedVersion, versionErr := request.ParseIfMatch(c)
+	if versionErr != nil {
+		return versionErr
+	}
 
-	newPrice := c.QueryParam("newPrice")
-	if len(newPrice) == 0 {
-		return c.JSON(http.StatusBadRequest, response.ErrorResponse{
-			ErrorDescription: "Parameter newPrice is required!",
+	if len(c.QueryParam("newPrice")) == 0 {
+		return request.NewMalformedRequestError("Parameter newPrice is required!", domain.FieldError{
+			Field:   "newPrice",
+			Code:    "required",
+			Message: "Parameter newPrice is required!",
 		})
 	}
 
-	convertedPrice, err := strconv.ParseFloat(newPrice, 32)
+	queryParser := request.NewQueryParser(c)
+	newPrice := queryParser.Decimal("newPrice")
+	if parseErr := queryParser.Err(); parseErr != nil {
+		return parseErr
+	}
+	err := productController.productService.UpdateProductPrice(c.Request().Context(), productId, *newPrice, expectedVersion)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, response.ErrorResponse{
-			ErrorDescription: "NewPrice format disprited",
-		})
+		return err
 	}
-	productController.productService.UpdateProductPrice(int64(productId), float32(convertedPrice))
 	return c.NoContent(http.StatusOK)
 }
 
 func (productController *ProductController) DeleteById(c echo.Context) error {
-	param := c.Param("id")
-	productId, _ := strconv.Atoi(param)
+	productId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+	expectedVersion, versionErr := request.ParseIfMatch(c)
+	if versionErr != nil {
+		return versionErr
+	}
 
-	err := productController.productService.DeleteById(int64(productId))
+	err := productController.productService.DeleteById(c.Request().Context(), productId, expectedVersion)
 	if err != nil {
-		return c.JSON(http.StatusNotFound, response.ErrorResponse{
-			ErrorDescription: err.Error(),
-		})
+		return err
 	}
 	return c.NoContent(http.StatusOK)
 }
+
+func (productController *ProductController) DeletedProducts(c echo.Context) error {
+	products, err := productController.productService.DeletedProducts(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, response.ToDeletedProductsResponse(products))
+}
+
+func (productController *ProductController) Restore(c echo.Context) error {
+	productId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+	expectedVersion, versionErr := request.ParseIfMatch(c)
+	if versionErr != nil {
+		return versionErr
+	}
+
+	product, err := productController.productService.Restore(c.Request().Context(), productId, expectedVersion)
+	if err != nil {
+		return err
+	}
+	return productJSON(c, http.StatusOK, product)
+}
+
+// PurgeDeleted empties the trash of products deleted longer than olderThan ago, the default retention otherwise
+func (productController *ProductController) PurgeDeleted(c echo.Context) error {
+	queryParser := request.NewQueryParser(c)
+	olderThan := queryParser.Duration("olderThan")
+	if parseErr := queryParser.Err(); parseErr != nil {
+		return parseErr
+	}
+	retention := domain.DefaultTrashRetention
+	if olderThan != nil {
+		retention = *olderThan
+	}
+
+	purged, err := productController.productService.PurgeDeleted(c.Request().Context(), retention)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, response.PurgeResponse{Purged: purged})
+}
+
+// ProductAudit lists the changes of one product newest first, it keeps working after the product was purged
+func (productController *ProductController) ProductAudit(c echo.Context) error {
+	productId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+	query, parseErr := parseAuditQuery(c)
+	if parseErr != nil {
+		return parseErr
+	}
+	query.ProductId = productId
+	return productController.auditPage(c, query)
+}
+
+// Audit searches the changes of all products by productId, actor, operation and a from/to time range
+func (productController *ProductController) Audit(c echo.Context) error {
+	query, parseErr := parseAuditQuery(c)
+	if parseErr != nil {
+		return parseErr
+	}
+	return productController.auditPage(c, query)
+}
+
+// PriceHistory returns the price series of a product between from and to, grouped per day or week when bucket is set
+func (productController *ProductController) PriceHistory(c echo.Context) error {
+	productId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+	queryParser := request.NewQueryParser(c)
+	query := domain.PriceHistoryQuery{
+		ProductId: productId,
+		From:      queryParser.Time("from"),
+		To:        queryParser.Time("to"),
+		Bucket:    c.QueryParam("bucket"),
+	}
+	if parseErr := queryParser.Err(); parseErr != nil {
+		return parseErr
+	}
+
+	priceHistory, err := productController.productService.PriceHistory(c.Request().Context(), query)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, response.ToPriceHistoryResponse(priceHistory))
+}
+
+// *auditPage
+func (productController *ProductController) auditPage(c echo.Context, query domain.AuditQuery) error {
+	auditPage, err := productController.productService.AuditRecords(c.Request().Context(), query)
+	if err != nil {
+		return err
+	}
+	if auditPage.HasMore {
+		values := c.Request().URL.Query()
+		values.Set("before_id", strconv.FormatInt(auditPage.NextCursor, 10))
+		nextUrl := url.URL{Path: c.Request().URL.Path, RawQuery: values.Encode()}
+		c.Response().Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, nextUrl.String()))
+	}
+	return c.JSON(http.StatusOK, response.ToAuditPageResponse(auditPage))
+}
+
+// *productJSON writes a single product together with its ETag, so the client can send it back in If-Match
+func productJSON(c echo.Context, status int, product domain.Product) error {
+	c.Response().Header().Set(response.HeaderETag, response.ETag(product))
+	return c.JSON(status, response.ToResponse(product))
+}
+
+// exportBatchTimeout is how long each batch of an export may take to reach the client. The deadline moves forward
+// with every batch, so the server write timeout does not cut off a long export while a stalled client still is.
+const exportBatchTimeout = 30 * time.Second
+
+// *exportStream sets the download headers right before the first bytes go out, so a failure while the export is
+// still buffered is answered with a regular error response. Every write is one buffered batch of the exporter.
+type exportStream struct {
+	c      echo.Context
+	format string
+}
+
+func (stream *exportStream) Write(content []byte) (int, error) {
+	responseController := http.NewResponseController(stream.c.Response().Writer)
+	deadlineErr := responseController.SetWriteDeadline(time.Now().Add(exportBatchTimeout))
+	if deadlineErr != nil && !errors.Is(deadlineErr, http.ErrNotSupported) {
+		return 0, deadlineErr
+	}
+	if !stream.c.Response().Committed {
+		header := stream.c.Response().Header()
+		header.Set(echo.HeaderContentType, response.ExportContentType(stream.format))
+		header.Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="products.%s"`, stream.format))
+		stream.c.Response().WriteHeader(http.StatusOK)
+	}
+	written, writeErr := stream.c.Response().Write(content)
+	if writeErr != nil {
+		return written, writeErr
+	}
+	if flushErr := responseController.Flush(); flushErr != nil && !errors.Is(flushErr, http.ErrNotSupported) {
+		return written, flushErr
+	}
+	return written, nil
+}
+
+// *parseProductQuery
+func parseProductQuery(c echo.Context) (domain.ProductQuery, error) {
+	queryParser := request.NewQueryParser(c)
+	query := domain.ProductQuery{
+		Stores:      c.QueryParams()["store"],
+		Name:        c.QueryParam("name"),
+		Sort:        parseSort(c.QueryParam("sort")),
+		MinPrice:    queryParser.Decimal("minPrice"),
+		MaxPrice:    queryParser.Decimal("maxPrice"),
+		MinDiscount: queryParser.Decimal("minDiscount"),
+		Limit:       queryParser.Int("limit"),
+		Offset:      queryParser.Int("offset"),
+		AfterId:     int64(queryParser.Int("after_id")),
+	}
+	return query, queryParser.Err()
+}
+
+// *parseAuditQuery reads the filters of the global audit, the product audit takes the product from the path instead
+func parseAuditQuery(c echo.Context) (domain.AuditQuery, error) {
+	queryParser := request.NewQueryParser(c)
+	query := domain.AuditQuery{
+		ProductId: int64(queryParser.Int("productId")),
+		Actor:     c.QueryParam("actor"),
+		Operation: c.QueryParam("operation"),
+		From:      queryParser.Time("from"),
+		To:        queryParser.Time("to"),
+		Limit:     queryParser.Int("limit"),
+		BeforeId:  int64(queryParser.Int("before_id")),
+	}
+	return query, queryParser.Err()
+}
+
+// ?parseSort turns "price,-discount" into ascending price then descending discount
+func parseSort(param string) []domain.SortField {
+	var sortFields []domain.SortField
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if len(field) == 0 {
+			continue
+		}
+		sortFields = append(sortFields, domain.SortField{
+			Field:      strings.TrimPrefix(field, "-"),
+			Descending: strings.HasPrefix(field, "-"),
+		})
+	}
+	return sortFields
+}
+
+// *paginationLinks builds RFC 8288 Link values for the page, keeping every other query parameter of the request
+func paginationLinks(requestUrl *url.URL, query domain.ProductQuery, productPage domain.ProductPage) []string {
+	limit := query.Limit
+	if limit == 0 {
+		limit = domain.DefaultPageLimit
+	}
+
+	var links []string
+	addLink := func(rel string, modify func(values url.Values)) {
+		values := requestUrl.Query()
+		values.Del("offset")
+		values.Del("after_id")
+		values.Set("limit", strconv.Itoa(limit))
+		modify(values)
+
+		linkUrl := url.URL{Path: requestUrl.Path, RawQuery: values.Encode()}
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, linkUrl.String(), rel))
+	}
+
+	if productPage.HasMore && productPage.NextCursor > 0 {
+		addLink("next", func(values url.Values) {
+			values.Set("after_id", strconv.FormatInt(productPage.NextCursor, 10))
+		})
+	} else if productPage.HasMore {
+		addLink("next", func(values url.Values) {
+			values.Set("offset", strconv.Itoa(query.Offset+limit))
+		})
+	}
+	if query.Offset > 0 {
+		addLink("prev", func(values url.Values) {
+			previousOffset := query.Offset - limit
+			if previousOffset > 0 {
+				values.Set("offset", strconv.Itoa(previousOffset))
+			}
+		})
+	}
+	if query.Offset > 0 || query.AfterId > 0 {
+		addLink("first", func(values url.Values) {})
+	}
+	return links
+}
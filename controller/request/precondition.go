@@ -0,0 +1,43 @@
+package request
+
+import (
+	"net/http"
+	"product-app/domain"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const HeaderIfMatch = "If-Match"
+
+// ParseIfMatch returns the product version the client expects to overwrite. A missing header or * returns
+// domain.AnyVersion. Weak or foreign entity tags can never match a strong ETag, so they fail the precondition
+// right away as RFC 9110 asks.
+func ParseIfMatch(c echo.Context) (int64, error) {
+	headerValues := c.Request().Header.Values(HeaderIfMatch)
+	if len(headerValues) == 0 {
+		return domain.AnyVersion, nil
+	}
+
+	var entityTags []string
+	for _, headerValue := range headerValues {
+		for _, entityTag := range strings.Split(headerValue, ",") {
+			if entityTag = strings.TrimSpace(entityTag); len(entityTag) > 0 {
+				entityTags = append(entityTags, entityTag)
+			}
+		}
+	}
+	if len(entityTags) == 1 && entityTags[0] == "*" {
+		return domain.AnyVersion, nil
+	}
+	if len(entityTags) != 1 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "If-Match must carry a single entity tag or *")
+	}
+
+	version, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(entityTags[0], `"`), `"`), 10, 64)
+	if err != nil || version < 1 || !strings.HasPrefix(entityTags[0], `"`) || !strings.HasSuffix(entityTags[0], `"`) {
+		return 0, domain.NewVersionMismatchError("If-Match does not match the current version of the product")
+	}
+	return version, nil
+}
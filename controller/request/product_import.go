@@ -0,0 +1,198 @@
+package request
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"product-app/domain"
+	"product-app/service/model"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	MIMETextCSV           = "text/csv"
+	MIMEApplicationNDJSON = "application/x-ndjson"
+)
+
+const (
+	// MaxImportBytes bounds an import body, generous for domain.MaxImportRows rows, a larger body is answered with 413
+	MaxImportBytes = 32 << 20
+	// maxImportLineBytes bounds a single NDJSON line
+	maxImportLineBytes = 64 << 10
+)
+
+// importColumns are the CSV columns an import understands, in the order of AddProductRequest
+var importColumns = []string{"name", "price", "discount", "currency", "store"}
+
+// ParseProductImport reads a CSV file with a header line or NDJSON with one AddProductRequest per line. Rows
+// that can not be parsed keep their errors so they are reported next to the rows that fail validation, only a
+// file that can not be read at all, is larger than MaxImportBytes or has more than domain.MaxImportRows rows is
+// rejected as a whole, without reading any further.
+func ParseProductImport(c echo.Context) ([]model.ImportRow, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	switch mediaType {
+	case MIMETextCSV:
+		return parseCsvImport(limitedBody(c, MaxImportBytes))
+	case MIMEApplicationNDJSON:
+		return parseNdjsonImport(limitedBody(c, MaxImportBytes))
+	}
+	return nil, echo.NewHTTPError(http.StatusUnsupportedMediaType,
+		fmt.Sprintf("Import body must be %s or %s", MIMETextCSV, MIMEApplicationNDJSON))
+}
+
+// ?parseCsvImport
+func parseCsvImport(body io.Reader) ([]model.ImportRow, error) {
+	reader := csv.NewReader(body)
+	reader.TrimLeadingSpace = true
+
+	header, headerErr := reader.Read()
+	if errors.Is(headerErr, io.EOF) {
+		return nil, NewMalformedRequestError("CSV header line is required")
+	}
+	if isReadError(headerErr) {
+		return nil, bodyReadError(headerErr)
+	}
+	if headerErr != nil {
+		return nil, NewMalformedRequestError(fmt.Sprintf("CSV is malformed: %s", headerErr))
+	}
+	columnIndexes, headerFieldErrors := importColumnIndexes(header)
+	if len(headerFieldErrors) > 0 {
+		return nil, NewMalformedRequestError("CSV header is malformed", headerFieldErrors...)
+	}
+
+	rows := []model.ImportRow{}
+	for {
+		record, readErr := reader.Read()
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+		if isReadError(readErr) {
+			return nil, bodyReadError(readErr)
+		}
+		if len(rows) == domain.MaxImportRows {
+			return nil, tooManyImportRowsError()
+		}
+		if readErr != nil && !errors.Is(readErr, csv.ErrFieldCount) {
+			return nil, NewMalformedRequestError(fmt.Sprintf("CSV is malformed: %s", readErr))
+		}
+		line, _ := reader.FieldPos(0)
+		row := model.ImportRow{Line: line}
+		if readErr != nil {
+			row.Errors = []domain.FieldError{{Field: "row", Code: "columns",
+				Message: fmt.Sprintf("Line has %d columns, the header has %d", len(record), len(header))}}
+			rows = append(rows, row)
+			continue
+		}
+
+		value := func(column string) string {
+			if index, ok := columnIndexes[column]; ok {
+				return strings.TrimSpace(record[index])
+			}
+			return ""
+		}
+		row.Product = model.ProductCreate{
+			Name:     value("name"),
+			Currency: value("currency"),
+			Store:    value("store"),
+		}
+		for _, decimalField := range []struct {
+			column string
+			target *domain.Decimal
+		}{{"price", &row.Product.Price}, {"discount", &row.Product.Discount}} {
+			text := value(decimalField.column)
+			if len(text) == 0 {
+				continue
+			}
+			parsed, parseErr := domain.ParseDecimal(text)
+			if parseErr != nil {
+				row.Errors = append(row.Errors, domain.FieldError{Field: decimalField.column, Code: "type",
+					Message: fmt.Sprintf("Field %s must be a decimal number", decimalField.column)})
+				continue
+			}
+			*decimalField.target = parsed
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ?importColumnIndexes maps header names onto their position, name, price and store have to be present
+func importColumnIndexes(header []string) (map[string]int, []domain.FieldError) {
+	columnIndexes := map[string]int{}
+	var fieldErrors []domain.FieldError
+	for index, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		known := false
+		for _, importColumn := range importColumns {
+			known = known || importColumn == column
+		}
+		if !known {
+			fieldErrors = append(fieldErrors, domain.FieldError{Field: column, Code: "unknown", Message: fmt.Sprintf("Unknown column %s", column)})
+			continue
+		}
+		if _, duplicate := columnIndexes[column]; duplicate {
+			fieldErrors = append(fieldErrors, domain.FieldError{Field: column, Code: "duplicate", Message: fmt.Sprintf("Column %s is given more than once", column)})
+			continue
+		}
+		columnIndexes[column] = index
+	}
+	for _, requiredColumn := range []string{"name", "price", "store"} {
+		if _, ok := columnIndexes[requiredColumn]; !ok {
+			fieldErrors = append(fieldErrors, domain.FieldError{Field: requiredColumn, Code: "required", Message: fmt.Sprintf("Column %s is required", requiredColumn)})
+		}
+	}
+	return columnIndexes, fieldErrors
+}
+
+// ?parseNdjsonImport skips blank lines but keeps counting them, so reported lines match the file
+func parseNdjsonImport(body io.Reader) ([]model.ImportRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxImportLineBytes)
+	rows := []model.ImportRow{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		content := bytes.TrimSpace(scanner.Bytes())
+		if len(content) == 0 {
+			continue
+		}
+		if len(rows) == domain.MaxImportRows {
+			return nil, tooManyImportRowsError()
+		}
+		var addProductRequest AddProductRequest
+		fieldErrors, decodeErr := decodeObject(content, &addProductRequest)
+		if decodeErr != nil {
+			fieldErrors = []domain.FieldError{{Field: "row", Code: "type", Message: "Line is not a JSON object"}}
+		}
+		rows = append(rows, model.ImportRow{Line: line, Product: addProductRequest.ToModel(), Errors: fieldErrors})
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return nil, NewMalformedRequestError(fmt.Sprintf("Line %d is longer than %d bytes", line+1, maxImportLineBytes))
+	}
+	if scanner.Err() != nil {
+		return nil, bodyReadError(scanner.Err())
+	}
+	return rows, nil
+}
+
+// ?isReadError tells a failure to read the body apart from the CSV parse errors, which wrap nothing
+func isReadError(err error) bool {
+	var parseErr *csv.ParseError
+	return err != nil && !errors.Is(err, io.EOF) && !errors.As(err, &parseErr)
+}
+
+// ?tooManyImportRowsError is the error the service reports for too many rows, raised before reading the rest
+func tooManyImportRowsError() error {
+	return domain.NewValidationError(fmt.Sprintf("Import can not have more than %d rows", domain.MaxImportRows), domain.FieldError{
+		Field:   "rows",
+		Code:    "max",
+		Message: fmt.Sprintf("Import can not have more than %d rows", domain.MaxImportRows),
+	})
+}
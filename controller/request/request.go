@@ -1,12 +1,21 @@
 package request
 
-import "product-app/service/model"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"product-app/domain"
+	"product-app/service/model"
+	"sort"
+)
 
+// AddProductRequest is the body of both adding and replacing a product
 type AddProductRequest struct {
-	Name     string  `json:"name"`
-	Price    float32 `json:"price"`
-	Discount float32 `json:"discount"`
-	Store    string  `json:"store"`
+	Name     string         `json:"name"`
+	Price    domain.Decimal `json:"price"`
+	Discount domain.Decimal `json:"discount"`
+	Currency string         `json:"currency"`
+	Store    string         `json:"store"`
 }
 
 func (addProductRequest AddProductRequest) ToModel() model.ProductCreate {
@@ -14,6 +23,82 @@ func (addProductRequest AddProductRequest) ToModel() model.ProductCreate {
 		Name:     addProductRequest.Name,
 		Price:    addProductRequest.Price,
 		Discount: addProductRequest.Discount,
+		Currency: addProductRequest.Currency,
 		Store:    addProductRequest.Store,
 	}
 }
+
+type StoreRequest struct {
+	Name string `json:"name"`
+}
+
+// ParseProductMergePatch reads an RFC 7396 merge patch. A null discount resets it to zero and a null currency
+// resets it to the default currency, while name, price and store are required and can not be removed.
+func ParseProductMergePatch(body []byte) (model.ProductPatch, error) {
+	var productPatch model.ProductPatch
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return productPatch, NewMalformedRequestError("Merge patch must be a JSON object")
+	}
+
+	fieldNames := make([]string, 0, len(fields))
+	for field := range fields {
+		fieldNames = append(fieldNames, field)
+	}
+	sort.Strings(fieldNames)
+
+	var fieldErrors []domain.FieldError
+	var malformedFieldErrors []domain.FieldError
+	for _, field := range fieldNames {
+		value := fields[field]
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			switch field {
+			case "discount":
+				productPatch.Discount = &domain.Decimal{}
+				continue
+			case "currency":
+				defaultCurrency := domain.DefaultCurrency
+				productPatch.Currency = &defaultCurrency
+				continue
+			}
+		}
+
+		var target interface{}
+		switch field {
+		case "name":
+			target = &productPatch.Name
+		case "price":
+			target = &productPatch.Price
+		case "discount":
+			target = &productPatch.Discount
+		case "currency":
+			target = &productPatch.Currency
+		case "store":
+			target = &productPatch.Store
+		default:
+			malformedFieldErrors = append(malformedFieldErrors, domain.FieldError{Field: field, Code: "unknown", Message: fmt.Sprintf("Unknown field %s", field)})
+			continue
+		}
+		if err := json.Unmarshal(value, target); err != nil {
+			malformedFieldErrors = append(malformedFieldErrors, domain.FieldError{Field: field, Code: "type", Message: fmt.Sprintf("Field %s has an invalid type", field)})
+		}
+	}
+
+	for _, requiredField := range []struct {
+		name  string
+		isSet bool
+	}{{"name", productPatch.Name != nil}, {"price", productPatch.Price != nil}, {"store", productPatch.Store != nil}} {
+		if value, ok := fields[requiredField.name]; ok && !requiredField.isSet && bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			fieldErrors = append(fieldErrors, domain.FieldError{Field: requiredField.name, Code: "required", Message: fmt.Sprintf("Field %s can not be removed", requiredField.name)})
+		}
+	}
+
+	if len(malformedFieldErrors) > 0 {
+		return productPatch, NewMalformedRequestError("Merge patch is malformed", append(malformedFieldErrors, fieldErrors...)...)
+	}
+	if len(fieldErrors) > 0 {
+		return productPatch, domain.NewValidationError("Invalid merge patch", fieldErrors...)
+	}
+	return productPatch, nil
+}
@@ -0,0 +1,241 @@
+package request
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"product-app/domain"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrMalformedRequest marks requests that could not be decoded at all, as opposed to domain validation failures
+var ErrMalformedRequest = errors.New("malformed request")
+
+// MaxBodyBytes bounds the JSON bodies read into memory, a larger body is answered with 413
+const MaxBodyBytes = 1 << 20
+
+// !NewMalformedRequestError
+func NewMalformedRequestError(message string, fields ...domain.FieldError) error {
+	return &domain.Error{Kind: ErrMalformedRequest, Message: message, Fields: fields}
+}
+
+// DecodeJSON strictly decodes a single JSON object from the request body. Every field is decoded on its own
+// so unknown fields and mismatched types are all reported at once, each under its own name.
+func DecodeJSON(c echo.Context, target interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != echo.MIMEApplicationJSON {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "Request body must be application/json")
+	}
+
+	body, readErr := ReadBody(c)
+	if readErr != nil {
+		return readErr
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return NewMalformedRequestError("Request body is required")
+	}
+
+	fieldErrors, decodeErr := decodeObject(body, target)
+	if decodeErr != nil {
+		return NewMalformedRequestError("Request body must be a single JSON object")
+	}
+	if len(fieldErrors) > 0 {
+		return NewMalformedRequestError("Request body is malformed", fieldErrors...)
+	}
+	return nil
+}
+
+// ReadBody reads the whole request body, failing with 413 once it grows beyond MaxBodyBytes
+func ReadBody(c echo.Context) ([]byte, error) {
+	body, readErr := io.ReadAll(limitedBody(c, MaxBodyBytes))
+	if readErr != nil {
+		return nil, bodyReadError(readErr)
+	}
+	return body, nil
+}
+
+// *limitedBody is the request body, reading more than limit bytes of it fails with an *http.MaxBytesError
+func limitedBody(c echo.Context, limit int64) io.Reader {
+	return http.MaxBytesReader(c.Response(), c.Request().Body, limit)
+}
+
+// ?bodyReadError answers 413 for a body beyond its limit and rejects any other read failure as malformed
+func bodyReadError(readErr error) error {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(readErr, &maxBytesErr) {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body can not be larger than %d bytes", maxBytesErr.Limit))
+	}
+	return NewMalformedRequestError("Request body could not be read")
+}
+
+// ?decodeObject decodes a JSON object into target field by field, collecting unknown fields and mismatched types.
+// It only fails when body is not a JSON object at all.
+func decodeObject(body []byte, target interface{}) ([]domain.FieldError, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+
+	targetValue := reflect.ValueOf(target).Elem()
+	fieldIndexes := jsonFieldIndexes(targetValue.Type())
+
+	fieldNames := make([]string, 0, len(fields))
+	for field := range fields {
+		fieldNames = append(fieldNames, field)
+	}
+	sort.Strings(fieldNames)
+
+	var fieldErrors []domain.FieldError
+	for _, field := range fieldNames {
+		index, known := fieldIndexes[field]
+		if !known {
+			fieldErrors = append(fieldErrors, domain.FieldError{Field: field, Code: "unknown", Message: fmt.Sprintf("Unknown field %s", field)})
+			continue
+		}
+		if err := json.Unmarshal(fields[field], targetValue.Field(index).Addr().Interface()); err != nil {
+			fieldErrors = append(fieldErrors, domain.FieldError{Field: field, Code: "type", Message: fmt.Sprintf("Field %s has an invalid type", field)})
+		}
+	}
+	return fieldErrors, nil
+}
+
+// ?jsonFieldIndexes maps the json names of a struct's exported fields onto their index
+func jsonFieldIndexes(structType reflect.Type) map[string]int {
+	fieldIndexes := map[string]int{}
+	for index := 0; index < structType.NumField(); index++ {
+		structField := structType.Field(index)
+		name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+		if !structField.IsExported() || name == "-" {
+			continue
+		}
+		if len(name) == 0 {
+			name = structField.Name
+		}
+		fieldIndexes[name] = index
+	}
+	return fieldIndexes
+}
+
+// ParseIdParam reads a positive integer path parameter
+func ParseIdParam(c echo.Context, name string) (int64, error) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id < 1 {
+		return 0, NewMalformedRequestError(fmt.Sprintf("Path parameter %s must be a positive integer", name), domain.FieldError{
+			Field:   name,
+			Code:    "type",
+			Message: fmt.Sprintf("Path parameter %s must be a positive integer", name),
+		})
+	}
+	return id, nil
+}
+
+// QueryParser reads typed query parameters and collects every malformed one instead of stopping at the first
+type QueryParser struct {
+	c           echo.Context
+	fieldErrors []domain.FieldError
+}
+
+// !NewQueryParser
+func NewQueryParser(c echo.Context) *QueryParser {
+	return &QueryParser{c: c}
+}
+
+// Int returns 0 when the parameter is absent
+func (queryParser *QueryParser) Int(name string) int {
+	param := queryParser.c.QueryParam(name)
+	if len(param) == 0 {
+		return 0
+	}
+	value, err := strconv.Atoi(param)
+	if err != nil {
+		queryParser.addError(name, fmt.Sprintf("Parameter %s must be an integer", name))
+	}
+	return value
+}
+
+// Decimal returns nil when the parameter is absent, only plain decimal notation is accepted
+func (queryParser *QueryParser) Decimal(name string) *domain.Decimal {
+	param := queryParser.c.QueryParam(name)
+	if len(param) == 0 {
+		return nil
+	}
+	value, err := domain.ParseDecimal(param)
+	if err != nil {
+		queryParser.addError(name, fmt.Sprintf("Parameter %s must be a decimal number", name))
+		return nil
+	}
+	return &value
+}
+
+// Duration returns nil when the parameter is absent, values use Go's notation such as 720h or 90m
+func (queryParser *QueryParser) Duration(name string) *time.Duration {
+	param := queryParser.c.QueryParam(name)
+	if len(param) == 0 {
+		return nil
+	}
+	value, err := time.ParseDuration(param)
+	if err != nil {
+		queryParser.addError(name, fmt.Sprintf("Parameter %s must be a duration such as 720h", name))
+		return nil
+	}
+	return &value
+}
+
+// Time returns nil when the parameter is absent, values are RFC 3339 timestamps
+func (queryParser *QueryParser) Time(name string) *time.Time {
+	param := queryParser.c.QueryParam(name)
+	if len(param) == 0 {
+		return nil
+	}
+	value, err := time.Parse(time.RFC3339, param)
+	if err != nil {
+		queryParser.addError(name, fmt.Sprintf("Parameter %s must be an RFC 3339 timestamp", name))
+		return nil
+	}
+	return &value
+}
+
+// Enum returns fallback when the parameter is absent, any other value has to be one of allowed
+func (queryParser *QueryParser) Enum(name string, allowed []string, fallback string) string {
+	param := queryParser.c.QueryParam(name)
+	if len(param) == 0 {
+		return fallback
+	}
+	for _, value := range allowed {
+		if param == value {
+			return param
+		}
+	}
+	queryParser.fieldErrors = append(queryParser.fieldErrors, domain.FieldError{
+		Field:   name,
+		Code:    "enum",
+		Message: fmt.Sprintf("Parameter %s must be one of %s", name, strings.Join(allowed, ",")),
+	})
+	return fallback
+}
+
+// Err reports all malformed parameters at once
+func (queryParser *QueryParser) Err() error {
+	if len(queryParser.fieldErrors) == 0 {
+		return nil
+	}
+	return NewMalformedRequestError("Query parameters are malformed", queryParser.fieldErrors...)
+}
+
+func (queryParser *QueryParser) addError(name string, message string) {
+	queryParser.fieldErrors = append(queryParser.fieldErrors, domain.FieldError{
+		Field:   name,
+		Code:    "type",
+		Message: message,
+	})
+}
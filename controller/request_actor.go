@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"product-app/common/logging"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderActor    = "X-Actor"
+	AnonymousActor = "anonymous"
+	maxActorLength = 128
+)
+
+// !RequestActor takes the actor recorded in the audit log from X-Actor. The header is trusted as is, it is meant
+// to be set by the authenticating proxy in front of the service.
+func RequestActor() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			actor := c.Request().Header.Get(HeaderActor)
+			if !isValidActor(actor) {
+				actor = AnonymousActor
+			}
+			c.SetRequest(c.Request().WithContext(logging.WithActor(c.Request().Context(), actor)))
+			return next(c)
+		}
+	}
+}
+
+// ?isValidActor accepts user names and e-mail addresses that are safe to log and store
+func isValidActor(actor string) bool {
+	if len(actor) == 0 || len(actor) > maxActorLength {
+		return false
+	}
+	for _, character := range actor {
+		isAlphanumeric := (character >= 'a' && character <= 'z') || (character >= 'A' && character <= 'Z') || (character >= '0' && character <= '9')
+		if !isAlphanumeric && character != '-' && character != '_' && character != '.' && character != ':' && character != '@' {
+			return false
+		}
+	}
+	return true
+}
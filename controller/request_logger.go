@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"product-app/common/logging"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const maxRequestIdLength = 128
+
+// !RequestLogger assigns every request an id, reusing a well-formed incoming X-Request-ID, echoes it back
+// and logs one line per request once the response status is known
+func RequestLogger(logger *slog.Logger) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			startedAt := time.Now()
+
+			requestId := c.Request().Header.Get(echo.HeaderXRequestID)
+			if !isValidRequestId(requestId) {
+				requestId = newRequestId()
+			}
+			c.Response().Header().Set(echo.HeaderXRequestID, requestId)
+			ctx := logging.WithRequestId(c.Request().Context(), requestId)
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				// Let the error handler write the response now so the logged status is the real one
+				c.Error(err)
+			}
+
+			status := c.Response().Status
+			attrs := []slog.Attr{
+				slog.String("method", c.Request().Method),
+				slog.String("route", c.Path()),
+				slog.String("path", c.Request().URL.Path),
+				slog.Int("status", status),
+				slog.Float64("latency_ms", float64(time.Since(startedAt).Microseconds())/1000),
+			}
+			level := slog.LevelInfo
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+				if status >= http.StatusInternalServerError {
+					level = slog.LevelError
+				} else {
+					level = slog.LevelWarn
+				}
+			}
+			logger.LogAttrs(ctx, level, "request", attrs...)
+			return nil
+		}
+	}
+}
+
+// ?isValidRequestId accepts ids that are safe to log and echo back
+func isValidRequestId(requestId string) bool {
+	if len(requestId) == 0 || len(requestId) > maxRequestIdLength {
+		return false
+	}
+	for _, character := range requestId {
+		isAlphanumeric := (character >= 'a' && character <= 'z') || (character >= 'A' && character <= 'Z') || (character >= '0' && character <= '9')
+		if !isAlphanumeric && character != '-' && character != '_' && character != '.' && character != ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// ?newRequestId
+func newRequestId() string {
+	randomBytes := make([]byte, 16)
+	rand.Read(randomBytes)
+	return hex.EncodeToString(randomBytes)
+}
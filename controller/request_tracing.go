@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"product-app/common/tracing"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HeaderTraceparent is the W3C trace context header
+const HeaderTraceparent = "traceparent"
+
+// !RequestTracing opens a server span per request, continuing the caller's trace when a valid traceparent
+// arrives. It must be registered before RequestLogger so the span sees the final status.
+func RequestTracing(tracer *tracing.Tracer) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			if parent, ok := tracing.ParseTraceparent(c.Request().Header.Get(HeaderTraceparent)); ok {
+				ctx = tracing.ContextWithRemoteParent(ctx, parent)
+			}
+			route := c.Path()
+			if len(route) == 0 {
+				route = unmatchedRoute
+			}
+			ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", c.Request().Method, route), tracing.SpanKindServer,
+				tracing.String("http.method", c.Request().Method),
+				tracing.String("http.route", route),
+				tracing.String("http.target", c.Request().URL.RequestURI()))
+			defer span.End()
+			c.SetRequest(c.Request().WithContext(ctx))
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+			status := c.Response().Status
+			span.SetAttributes(tracing.Int("http.status_code", status))
+			if status >= http.StatusInternalServerError && err != nil {
+				span.RecordError(err)
+			}
+			return nil
+		}
+	}
+}
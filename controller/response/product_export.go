@@ -0,0 +1,135 @@
+package response
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"product-app/domain"
+	"strconv"
+)
+
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportJSON   = "json"
+)
+
+// ExportFormats are the values the format parameter of an export accepts, the first one is the default
+var ExportFormats = []string{ExportJSON, ExportCSV, ExportNDJSON}
+
+var exportContentTypes = map[string]string{
+	ExportCSV:    "text/csv; charset=UTF-8",
+	ExportNDJSON: "application/x-ndjson",
+	ExportJSON:   "application/json; charset=UTF-8",
+}
+
+// exportColumns is the CSV header, in the field order of ProductResponse
+var exportColumns = []string{"id", "name", "price", "discount", "currency", "storeId", "store", "version"}
+
+// ProductExporter writes products one at a time in an export format. Output is buffered, so nothing reaches the
+// underlying writer until the buffer fills or Close is called, and an empty export is still a well formed file.
+type ProductExporter struct {
+	format    string
+	writer    *bufio.Writer
+	csvWriter *csv.Writer
+	rows      int64
+}
+
+func NewProductExporter(format string, writer io.Writer) *ProductExporter {
+	bufferedWriter := bufio.NewWriter(writer)
+	return &ProductExporter{
+		format:    format,
+		writer:    bufferedWriter,
+		csvWriter: csv.NewWriter(bufferedWriter),
+	}
+}
+
+// ExportContentType is the media type of format
+func ExportContentType(format string) string {
+	return exportContentTypes[format]
+}
+
+// !Rows
+func (exporter *ProductExporter) Rows() int64 {
+	return exporter.rows
+}
+
+// Write adds a product, the CSV header and the opening bracket of a JSON array go out with the first one
+func (exporter *ProductExporter) Write(product domain.Product) error {
+	if exporter.rows == 0 {
+		if beginErr := exporter.begin(); beginErr != nil {
+			return beginErr
+		}
+	}
+	productResponse := ToResponse(product)
+	var writeErr error
+	switch exporter.format {
+	case ExportCSV:
+		writeErr = exporter.csvWriter.Write([]string{strconv.FormatInt(productResponse.Id, 10), productResponse.Name,
+			productResponse.Price, productResponse.Discount, productResponse.Currency, strconv.FormatInt(productResponse.StoreId, 10),
+			productResponse.Store, strconv.FormatInt(productResponse.Version, 10)})
+	case ExportNDJSON:
+		writeErr = exporter.writeJSON(productResponse, "\n")
+	default:
+		separator := ","
+		if exporter.rows == 0 {
+			separator = ""
+		}
+		_, writeErr = exporter.writer.WriteString(separator)
+		if writeErr == nil {
+			writeErr = exporter.writeJSON(productResponse, "")
+		}
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	exporter.rows++
+	return nil
+}
+
+// Close ends the file and flushes what is still buffered, it does not close the underlying writer
+func (exporter *ProductExporter) Close() error {
+	if exporter.rows == 0 {
+		if beginErr := exporter.begin(); beginErr != nil {
+			return beginErr
+		}
+	}
+	switch exporter.format {
+	case ExportCSV:
+		exporter.csvWriter.Flush()
+		if csvErr := exporter.csvWriter.Error(); csvErr != nil {
+			return csvErr
+		}
+	case ExportJSON:
+		if _, writeErr := exporter.writer.WriteString("]\n"); writeErr != nil {
+			return writeErr
+		}
+	}
+	return exporter.writer.Flush()
+}
+
+// *begin
+func (exporter *ProductExporter) begin() error {
+	switch exporter.format {
+	case ExportCSV:
+		return exporter.csvWriter.Write(exportColumns)
+	case ExportJSON:
+		_, writeErr := exporter.writer.WriteString("[")
+		return writeErr
+	}
+	return nil
+}
+
+// *writeJSON
+func (exporter *ProductExporter) writeJSON(productResponse ProductResponse, suffix string) error {
+	encoded, marshalErr := json.Marshal(productResponse)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	if _, writeErr := exporter.writer.Write(encoded); writeErr != nil {
+		return writeErr
+	}
+	_, writeErr := exporter.writer.WriteString(suffix)
+	return writeErr
+}
@@ -1,24 +1,60 @@
 package response
 
-import "product-app/domain"
+import (
+	"product-app/common/health"
+	"product-app/domain"
+	"strconv"
+	"time"
+)
 
 type ErrorResponse struct {
-	ErrorDescription string `json:"errorDescription"`
+	Code             string               `json:"code,omitempty"`
+	ErrorDescription string               `json:"errorDescription"`
+	Details          []FieldErrorResponse `json:"details,omitempty"`
 }
 
+type FieldErrorResponse struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ProductResponse carries price and discount as decimal strings so clients never see float rounding
 type ProductResponse struct {
-	Name     string  `json:"name"`
-	Price    float32 `json:"price"`
-	Discount float32 `json:"discount"`
-	Store    string  `json:"store"`
+	Id       int64  `json:"id"`
+	Name     string `json:"name"`
+	Price    string `json:"price"`
+	Discount string `json:"discount"`
+	Currency string `json:"currency"`
+	StoreId  int64  `json:"storeId"`
+	Store    string `json:"store"`
+	Version  int64  `json:"version"`
+	// DeletedAt is only present on products listed from the trash
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+const HeaderETag = "ETag"
+
+// ETag is the strong entity tag of a product, its version changes with every write
+func ETag(product domain.Product) string {
+	return strconv.Quote(strconv.FormatInt(product.Version, 10))
 }
 
 func ToResponse(product domain.Product) ProductResponse {
+	minorUnits, ok := domain.CurrencyMinorUnits(product.Currency)
+	if !ok {
+		minorUnits = domain.DecimalScale
+	}
 	return ProductResponse{
-		Name:     product.Name,
-		Price:    product.Price,
-		Discount: product.Discount,
-		Store:    product.Store,
+		Id:        product.Id,
+		Name:      product.Name,
+		Price:     product.Price.StringFixed(minorUnits),
+		Discount:  product.Discount.String(),
+		Currency:  product.Currency,
+		StoreId:   product.StoreId,
+		Store:     product.Store,
+		Version:   product.Version,
+		DeletedAt: product.DeletedAt,
 	}
 }
 
@@ -29,3 +65,272 @@ func ToResponseList(products []domain.Product) []ProductResponse {
 	}
 	return productResponseList
 }
+
+type StoreResponse struct {
+	Id        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func ToStoreResponse(store domain.Store) StoreResponse {
+	return StoreResponse{
+		Id:        store.Id,
+		Name:      store.Name,
+		CreatedAt: store.CreatedAt,
+	}
+}
+
+func ToStoreResponseList(stores []domain.Store) []StoreResponse {
+	var storeResponseList = []StoreResponse{}
+	for _, store := range stores {
+		storeResponseList = append(storeResponseList, ToStoreResponse(store))
+	}
+	return storeResponseList
+}
+
+type DeletedProductsResponse struct {
+	Items []ProductResponse `json:"items"`
+	Total int64             `json:"total"`
+}
+
+func ToDeletedProductsResponse(products []domain.Product) DeletedProductsResponse {
+	return DeletedProductsResponse{
+		Items: ToResponseList(products),
+		Total: int64(len(products)),
+	}
+}
+
+type PurgeResponse struct {
+	Purged int64 `json:"purged"`
+}
+
+type AuditRecordResponse struct {
+	Id        int64                    `json:"id"`
+	ProductId int64                    `json:"productId"`
+	Operation string                   `json:"operation"`
+	Actor     string                   `json:"actor"`
+	RequestId string                   `json:"requestId,omitempty"`
+	Before    *ProductSnapshotResponse `json:"before"`
+	After     *ProductSnapshotResponse `json:"after"`
+	Changes   []FieldChangeResponse    `json:"changes"`
+	CreatedAt time.Time                `json:"createdAt"`
+}
+
+type ProductSnapshotResponse struct {
+	Name     string `json:"name"`
+	Price    string `json:"price"`
+	Discount string `json:"discount"`
+	Currency string `json:"currency"`
+	Store    string `json:"store"`
+	Version  int64  `json:"version"`
+}
+
+// FieldChangeResponse has a null before when the product was created and a null after when it was removed
+type FieldChangeResponse struct {
+	Field  string  `json:"field"`
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+type AuditPageResponse struct {
+	Items      []AuditRecordResponse `json:"items"`
+	NextCursor *string               `json:"nextCursor"`
+}
+
+func ToAuditRecordResponse(auditRecord domain.AuditRecord) AuditRecordResponse {
+	changes := []FieldChangeResponse{}
+	for _, change := range auditRecord.Changes {
+		changes = append(changes, FieldChangeResponse{
+			Field:  change.Field,
+			Before: change.Before,
+			After:  change.After,
+		})
+	}
+	return AuditRecordResponse{
+		Id:        auditRecord.Id,
+		ProductId: auditRecord.ProductId,
+		Operation: auditRecord.Operation,
+		Actor:     auditRecord.Actor,
+		RequestId: auditRecord.RequestId,
+		Before:    toSnapshotResponse(auditRecord.Before),
+		After:     toSnapshotResponse(auditRecord.After),
+		Changes:   changes,
+		CreatedAt: auditRecord.CreatedAt,
+	}
+}
+
+func ToAuditPageResponse(auditPage domain.AuditPage) AuditPageResponse {
+	auditPageResponse := AuditPageResponse{Items: []AuditRecordResponse{}}
+	for _, auditRecord := range auditPage.Items {
+		auditPageResponse.Items = append(auditPageResponse.Items, ToAuditRecordResponse(auditRecord))
+	}
+	if auditPage.HasMore {
+		nextCursor := strconv.FormatInt(auditPage.NextCursor, 10)
+		auditPageResponse.NextCursor = &nextCursor
+	}
+	return auditPageResponse
+}
+
+// ?toSnapshotResponse
+func toSnapshotResponse(snapshot *domain.ProductSnapshot) *ProductSnapshotResponse {
+	if snapshot == nil {
+		return nil
+	}
+	return &ProductSnapshotResponse{
+		Name:     snapshot.Name,
+		Price:    snapshot.Price.String(),
+		Discount: snapshot.Discount.String(),
+		Currency: snapshot.Currency,
+		Store:    snapshot.Store,
+		Version:  snapshot.Version,
+	}
+}
+
+// PriceHistoryResponse carries points when no bucket was asked for and buckets otherwise, the other one is null
+type PriceHistoryResponse struct {
+	ProductId int64                 `json:"productId"`
+	Bucket    *string               `json:"bucket"`
+	Points    []PricePointResponse  `json:"points"`
+	Buckets   []PriceBucketResponse `json:"buckets"`
+}
+
+type PricePointResponse struct {
+	Price      string    `json:"price"`
+	Discount   string    `json:"discount"`
+	Currency   string    `json:"currency"`
+	RecordedAt time.Time `json:"recordedAt"`
+}
+
+type PriceBucketResponse struct {
+	Start    time.Time     `json:"start"`
+	Price    RangeResponse `json:"price"`
+	Discount RangeResponse `json:"discount"`
+	Currency string        `json:"currency"`
+}
+
+type RangeResponse struct {
+	Min  string `json:"min"`
+	Max  string `json:"max"`
+	Last string `json:"last"`
+}
+
+func ToPriceHistoryResponse(priceHistory domain.PriceHistory) PriceHistoryResponse {
+	priceHistoryResponse := PriceHistoryResponse{ProductId: priceHistory.ProductId}
+	if len(priceHistory.Bucket) == 0 {
+		priceHistoryResponse.Points = []PricePointResponse{}
+	} else {
+		priceHistoryResponse.Bucket = &priceHistory.Bucket
+		priceHistoryResponse.Buckets = []PriceBucketResponse{}
+	}
+	for _, pricePoint := range priceHistory.Points {
+		priceHistoryResponse.Points = append(priceHistoryResponse.Points, PricePointResponse{
+			Price:      pricePoint.Price.String(),
+			Discount:   pricePoint.Discount.String(),
+			Currency:   pricePoint.Currency,
+			RecordedAt: pricePoint.RecordedAt,
+		})
+	}
+	for _, priceBucket := range priceHistory.Buckets {
+		priceHistoryResponse.Buckets = append(priceHistoryResponse.Buckets, PriceBucketResponse{
+			Start:    priceBucket.Start,
+			Price:    RangeResponse{Min: priceBucket.MinPrice.String(), Max: priceBucket.MaxPrice.String(), Last: priceBucket.LastPrice.String()},
+			Discount: RangeResponse{Min: priceBucket.MinDiscount.String(), Max: priceBucket.MaxDiscount.String(), Last: priceBucket.LastDiscount.String()},
+			Currency: priceBucket.Currency,
+		})
+	}
+	return priceHistoryResponse
+}
+
+type ImportReportResponse struct {
+	Mode      string              `json:"mode"`
+	Committed bool                `json:"committed"`
+	Accepted  int                 `json:"accepted"`
+	Rejected  int                 `json:"rejected"`
+	Rows      []ImportRowResponse `json:"rows"`
+	// Error is set when the import was aborted after some rows were already committed
+	Error *ErrorResponse `json:"error,omitempty"`
+}
+
+type ImportRowResponse struct {
+	Line      int                  `json:"line"`
+	Status    string               `json:"status"`
+	ProductId int64                `json:"productId,omitempty"`
+	Errors    []FieldErrorResponse `json:"errors,omitempty"`
+}
+
+func ToImportReportResponse(report domain.ImportReport) ImportReportResponse {
+	importReportResponse := ImportReportResponse{
+		Mode:      report.Mode,
+		Committed: report.Committed,
+		Accepted:  report.Accepted,
+		Rejected:  report.Rejected,
+		Rows:      []ImportRowResponse{},
+	}
+	for _, row := range report.Rows {
+		importReportResponse.Rows = append(importReportResponse.Rows, ImportRowResponse{
+			Line:      row.Line,
+			Status:    row.Status,
+			ProductId: row.ProductId,
+			Errors:    ToFieldErrorResponseList(row.Errors),
+		})
+	}
+	return importReportResponse
+}
+
+type ProductPageResponse struct {
+	Items      []ProductResponse `json:"items"`
+	NextCursor *string           `json:"nextCursor"`
+	Total      int64             `json:"total"`
+}
+
+func ToProductPageResponse(productPage domain.ProductPage) ProductPageResponse {
+	productPageResponse := ProductPageResponse{
+		Items: ToResponseList(productPage.Items),
+		Total: productPage.Total,
+	}
+	if productPage.HasMore && productPage.NextCursor > 0 {
+		nextCursor := strconv.FormatInt(productPage.NextCursor, 10)
+		productPageResponse.NextCursor = &nextCursor
+	}
+	return productPageResponse
+}
+
+func ToFieldErrorResponseList(fieldErrors []domain.FieldError) []FieldErrorResponse {
+	var fieldErrorResponseList []FieldErrorResponse
+	for _, fieldError := range fieldErrors {
+		fieldErrorResponseList = append(fieldErrorResponseList, FieldErrorResponse{
+			Field:   fieldError.Field,
+			Code:    fieldError.Code,
+			Message: fieldError.Message,
+		})
+	}
+	return fieldErrorResponseList
+}
+
+type HealthResponse struct {
+	Status     string                    `json:"status"`
+	Components []ComponentHealthResponse `json:"components,omitempty"`
+}
+
+type ComponentHealthResponse struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func ToHealthResponse(report health.Report) HealthResponse {
+	var components = []ComponentHealthResponse{}
+	for _, component := range report.Components {
+		components = append(components, ComponentHealthResponse{
+			Name:      component.Name,
+			Status:    component.Status,
+			LatencyMs: float64(component.Latency.Microseconds()) / 1000,
+			Error:     component.Error,
+		})
+	}
+	return HealthResponse{
+		Status:     report.Status,
+		Components: components,
+	}
+}
@@ -0,0 +1,27 @@
+package controller
+
+import (
+	"product-app/common/health"
+	"product-app/common/metrics"
+	"product-app/service"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Dependencies are what the controllers of the application are built on
+type Dependencies struct {
+	HealthRegistry  *health.Registry
+	MetricsRegistry *metrics.Registry
+	ProductService  service.IProductService
+	StoreService    service.IStoreService
+}
+
+// !RegisterRoutes registers every route the application serves. main serves exactly these routes and the
+// documentation test checks exactly these against the OpenAPI document.
+func RegisterRoutes(e *echo.Echo, dependencies Dependencies) {
+	NewHealthController(dependencies.HealthRegistry).RegisterRoutes(e)
+	NewMetricsController(dependencies.MetricsRegistry).RegisterRoutes(e)
+	NewDocsController().RegisterRoutes(e)
+	NewProductController(&dependencies.ProductService).RegisterRoutes(e)
+	NewStoreController(&dependencies.StoreService).RegisterRoutes(e)
+}
@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"product-app/controller/request"
+	"product-app/controller/response"
+	"product-app/service"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+type StoreController struct {
+	storeService service.IStoreService
+}
+
+func NewStoreController(storeService *service.IStoreService) *StoreController {
+	return &StoreController{
+		storeService: *storeService,
+	}
+}
+
+func (storeController *StoreController) RegisterRoutes(e *echo.Echo) {
+	e.GET("/api/v1/stores/", storeController.AllStores)
+	e.POST("/api/v1/stores/", storeController.Add)
+	e.GET("/api/v1/stores/:id/", storeController.StoreById)
+	e.PUT("/api/v1/stores/:id/", storeController.Rename)
+	e.DELETE("/api/v1/stores/:id/", storeController.DeleteById)
+	e.GET("/api/v1/stores/:id/products/", storeController.Products)
+}
+
+func (storeController *StoreController) AllStores(c echo.Context) error {
+	stores, err := storeController.storeService.AllStores(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, response.ToStoreResponseList(stores))
+}
+
+func (storeController *StoreController) StoreById(c echo.Context) error {
+	storeId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+
+	store, err := storeController.storeService.StoreById(c.Request().Context(), storeId)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, response.ToStoreResponse(store))
+}
+
+func (storeController *StoreController) Add(c echo.Context) error {
+	var storeRequest request.StoreRequest
+	decodeErr := request.DecodeJSON(c, &storeRequest)
+	if decodeErr != nil {
+		return decodeErr
+	}
+	store, err := storeController.storeService.Add(c.Request().Context(), storeRequest.Name)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/api/v1/stores/%d/", store.Id))
+	return c.JSON(http.StatusCreated, response.ToStoreResponse(store))
+}
+
+// Rename replaces the name of the store, its products are listed under the new name right away
+func (storeController *StoreController) Rename(c echo.Context) error {
+	storeId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+	var storeRequest request.StoreRequest
+	decodeErr := request.DecodeJSON(c, &storeRequest)
+	if decodeErr != nil {
+		return decodeErr
+	}
+
+	store, err := storeController.storeService.Rename(c.Request().Context(), storeId, storeRequest.Name)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, response.ToStoreResponse(store))
+}
+
+// DeleteById answers 409 while products still refer to the store
+func (storeController *StoreController) DeleteById(c echo.Context) error {
+	storeId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+
+	err := storeController.storeService.DeleteById(c.Request().Context(), storeId)
+	if err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusOK)
+}
+
+// Products lists the products of the store with the filters, sort and paging of the product listing
+func (storeController *StoreController) Products(c echo.Context) error {
+	storeId, idErr := request.ParseIdParam(c, "id")
+	if idErr != nil {
+		return idErr
+	}
+	query, parseErr := parseProductQuery(c)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	productPage, err := storeController.storeService.Products(c.Request().Context(), storeId, query)
+	if err != nil {
+		return err
+	}
+
+	links := paginationLinks(c.Request().URL, query, productPage)
+	if len(links) > 0 {
+		c.Response().Header().Set("Link", strings.Join(links, ", "))
+	}
+	return c.JSON(http.StatusOK, response.ToProductPageResponse(productPage))
+}
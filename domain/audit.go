@@ -0,0 +1,138 @@
+package domain
+
+import "time"
+
+// Audit operations name what a mutation did to a product
+const (
+	AuditCreate      = "create"
+	AuditUpdate      = "update"
+	AuditPriceChange = "price_change"
+	AuditDelete      = "delete"
+	AuditRestore     = "restore"
+	AuditPurge       = "purge"
+)
+
+// AuditOperations is the whitelist an audit query can filter by
+var AuditOperations = []string{AuditCreate, AuditUpdate, AuditPriceChange, AuditDelete, AuditRestore, AuditPurge}
+
+// ProductSnapshot is the state of a product as the audit log keeps it
+type ProductSnapshot struct {
+	Name     string  `json:"name"`
+	Price    Decimal `json:"price"`
+	Discount Decimal `json:"discount"`
+	Currency string  `json:"currency"`
+	Store    string  `json:"store"`
+	Version  int64   `json:"version"`
+}
+
+// FieldChange has no Before when the product entered the catalog and no After when it left it
+type FieldChange struct {
+	Field  string  `json:"field"`
+	Before *string `json:"before"`
+	After  *string `json:"after"`
+}
+
+// AuditRecord is one mutation of one product. Before is nil for create and restore, After for delete and purge.
+type AuditRecord struct {
+	Id        int64
+	ProductId int64
+	Operation string
+	Actor     string
+	RequestId string
+	Before    *ProductSnapshot
+	After     *ProductSnapshot
+	Changes   []FieldChange
+	CreatedAt time.Time
+}
+
+// AuditQuery pages through the audit log newest first, BeforeId is the keyset cursor
+type AuditQuery struct {
+	ProductId int64
+	Actor     string
+	Operation string
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	BeforeId  int64
+}
+
+type AuditPage struct {
+	Items      []AuditRecord
+	NextCursor int64
+	HasMore    bool
+}
+
+// NewAuditRecord snapshots both sides of a mutation and lists the fields it changed, the version is left out
+// of the changes since every write bumps it
+func NewAuditRecord(operation string, before *Product, after *Product) AuditRecord {
+	auditRecord := AuditRecord{
+		Operation: operation,
+		Before:    SnapshotOf(before),
+		After:     SnapshotOf(after),
+	}
+	if before != nil {
+		auditRecord.ProductId = before.Id
+	} else if after != nil {
+		auditRecord.ProductId = after.Id
+	}
+	auditRecord.Changes = diffSnapshots(auditRecord.Before, auditRecord.After)
+	return auditRecord
+}
+
+// !SnapshotOf returns nil for a nil product
+func SnapshotOf(product *Product) *ProductSnapshot {
+	if product == nil {
+		return nil
+	}
+	return &ProductSnapshot{
+		Name:     product.Name,
+		Price:    product.Price,
+		Discount: product.Discount,
+		Currency: product.Currency,
+		Store:    product.Store,
+		Version:  product.Version,
+	}
+}
+
+// ?diffSnapshots
+func diffSnapshots(before *ProductSnapshot, after *ProductSnapshot) []FieldChange {
+	fieldsOf := func(snapshot *ProductSnapshot) []*string {
+		if snapshot == nil {
+			return make([]*string, 5)
+		}
+		price, discount := snapshot.Price.String(), snapshot.Discount.String()
+		return []*string{&snapshot.Name, &price, &discount, &snapshot.Currency, &snapshot.Store}
+	}
+
+	changes := []FieldChange{}
+	beforeFields, afterFields := fieldsOf(before), fieldsOf(after)
+	for index, field := range []string{"name", "price", "discount", "currency", "store"} {
+		beforeValue, afterValue := beforeFields[index], afterFields[index]
+		if beforeValue != nil && afterValue != nil && *beforeValue == *afterValue {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: field, Before: beforeValue, After: afterValue})
+	}
+	return changes
+}
+
+// IsAuditOperation
+func IsAuditOperation(operation string) bool {
+	for _, auditOperation := range AuditOperations {
+		if auditOperation == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAuditPage trims rows fetched with one extra element down to the limit
+func NewAuditPage(rows []AuditRecord, query AuditQuery) AuditPage {
+	auditPage := AuditPage{Items: rows}
+	if len(rows) > query.Limit {
+		auditPage.Items = rows[:query.Limit]
+		auditPage.HasMore = true
+		auditPage.NextCursor = auditPage.Items[len(auditPage.Items)-1].Id
+	}
+	return auditPage
+}
@@ -0,0 +1,22 @@
+package domain
+
+// DefaultCurrency is applied to products created without an explicit currency
+const DefaultCurrency = "TRY"
+
+// currencyMinorUnits lists the supported ISO 4217 codes with the number of digits of their minor unit
+var currencyMinorUnits = map[string]int{
+	"AZN": 2,
+	"CHF": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"JPY": 0,
+	"KWD": 3,
+	"TRY": 2,
+	"USD": 2,
+}
+
+// CurrencyMinorUnits returns how many fractional digits amounts in the currency may have
+func CurrencyMinorUnits(currency string) (int, bool) {
+	minorUnits, ok := currencyMinorUnits[currency]
+	return minorUnits, ok
+}
@@ -0,0 +1,201 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DecimalScale is the number of fractional digits a Decimal keeps exactly
+const DecimalScale = 4
+
+const (
+	decimalFactor       = 10000
+	maxDecimalIntDigits = 14
+	// maxNumericFractionDigits bounds the exponents Scan expands, numeric columns may carry trailing zeros
+	maxNumericFractionDigits = 32
+)
+
+var ErrInvalidDecimal = errors.New("invalid decimal")
+
+// Decimal is an exact fixed point number with four fractional digits, used for prices and discounts
+// so values like 19.99 survive every round trip through the database and JSON
+type Decimal struct {
+	units int64
+}
+
+// !NewDecimal
+func NewDecimal(integer int64) Decimal {
+	return Decimal{units: integer * decimalFactor}
+}
+
+// !ParseDecimal accepts plain decimal notation such as "-19.99", exponents are rejected
+func ParseDecimal(value string) (Decimal, error) {
+	text := strings.TrimSpace(value)
+	negative := strings.HasPrefix(text, "-")
+	if negative || strings.HasPrefix(text, "+") {
+		text = text[1:]
+	}
+
+	integerPart, fractionPart, _ := strings.Cut(text, ".")
+	if len(integerPart)+len(fractionPart) == 0 || !isDigits(integerPart) || !isDigits(fractionPart) {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, value)
+	}
+	fractionPart = strings.TrimRight(fractionPart, "0")
+	integerPart = strings.TrimLeft(integerPart, "0")
+	if len(integerPart) > maxDecimalIntDigits {
+		return Decimal{}, fmt.Errorf("%w: %q has more than %d integer digits", ErrInvalidDecimal, value, maxDecimalIntDigits)
+	}
+	if len(fractionPart) > DecimalScale {
+		return Decimal{}, fmt.Errorf("%w: %q has more than %d fractional digits", ErrInvalidDecimal, value, DecimalScale)
+	}
+
+	units, _ := strconv.ParseInt(integerPart+fractionPart+strings.Repeat("0", DecimalScale-len(fractionPart)), 10, 64)
+	if negative {
+		units = -units
+	}
+	return Decimal{units: units}, nil
+}
+
+// !MustParseDecimal panics on invalid input, meant for constants and tests
+func MustParseDecimal(value string) Decimal {
+	decimal, err := ParseDecimal(value)
+	if err != nil {
+		panic(err)
+	}
+	return decimal
+}
+
+// String renders the shortest exact representation, e.g. "19.99" or "3000"
+func (decimal Decimal) String() string {
+	return strings.TrimSuffix(strings.TrimRight(decimal.StringFixed(DecimalScale), "0"), ".")
+}
+
+// StringFixed renders exactly places fractional digits, e.g. "3000.00" for two places
+func (decimal Decimal) StringFixed(places int) string {
+	places = min(max(places, 0), DecimalScale)
+
+	units := decimal.units
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	text := fmt.Sprintf("%d.%04d", units/decimalFactor, units%decimalFactor)
+	if places == 0 {
+		return sign + text[:strings.Index(text, ".")]
+	}
+	return sign + text[:strings.Index(text, ".")+1+places]
+}
+
+// HasAtMostPlaces reports whether the value can be written with the given number of fractional digits
+func (decimal Decimal) HasAtMostPlaces(places int) bool {
+	divisor := int64(1)
+	for i := places; i < DecimalScale; i++ {
+		divisor *= 10
+	}
+	return decimal.units%divisor == 0
+}
+
+func (decimal Decimal) Cmp(other Decimal) int {
+	switch {
+	case decimal.units < other.units:
+		return -1
+	case decimal.units > other.units:
+		return 1
+	}
+	return 0
+}
+
+func (decimal Decimal) IsNegative() bool {
+	return decimal.units < 0
+}
+
+func (decimal Decimal) IsZero() bool {
+	return decimal.units == 0
+}
+
+// MarshalJSON writes the value as a string to avoid precision loss in JSON clients
+func (decimal Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(decimal.String())), nil
+}
+
+// UnmarshalJSON accepts both "19.99" and 19.99
+func (decimal *Decimal) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	parsed, err := ParseDecimal(text)
+	if err != nil {
+		return err
+	}
+	*decimal = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner. pgx hands numeric columns over as the text of pgtype.Numeric, an integer with an
+// exponent such as "1999e-2", so unlike ParseDecimal the exponent form is accepted here.
+func (decimal *Decimal) Scan(src interface{}) error {
+	var parsed Decimal
+	var err error
+	switch value := src.(type) {
+	case nil:
+		parsed = Decimal{}
+	case string:
+		parsed, err = parseNumericText(value)
+	case []byte:
+		parsed, err = parseNumericText(string(value))
+	case int64:
+		parsed = NewDecimal(value)
+	case float64:
+		parsed, err = ParseDecimal(strconv.FormatFloat(value, 'f', -1, 64))
+	default:
+		err = fmt.Errorf("%w: can not scan %T", ErrInvalidDecimal, src)
+	}
+	if err != nil {
+		return err
+	}
+	*decimal = parsed
+	return nil
+}
+
+// Value implements driver.Valuer so a Decimal can be bound to numeric parameters
+func (decimal Decimal) Value() (driver.Value, error) {
+	return decimal.String(), nil
+}
+
+// ?parseNumericText accepts plain decimal notation and the "<integer>e<exponent>" form of pgtype.Numeric
+func parseNumericText(value string) (Decimal, error) {
+	mantissa, exponentText, hasExponent := strings.Cut(strings.TrimSpace(value), "e")
+	if !hasExponent {
+		return ParseDecimal(value)
+	}
+	exponent, exponentErr := strconv.Atoi(exponentText)
+	sign := ""
+	if strings.HasPrefix(mantissa, "-") {
+		sign, mantissa = "-", mantissa[1:]
+	}
+	if exponentErr != nil || len(mantissa) == 0 || !isDigits(mantissa) || exponent > maxDecimalIntDigits || exponent < -maxNumericFractionDigits {
+		return Decimal{}, fmt.Errorf("%w: %q", ErrInvalidDecimal, value)
+	}
+
+	if exponent >= 0 {
+		return ParseDecimal(sign + mantissa + strings.Repeat("0", exponent))
+	}
+	digits := strings.Repeat("0", max(-exponent-len(mantissa)+1, 0)) + mantissa
+	pointAt := len(digits) + exponent
+	return ParseDecimal(sign + digits[:pointAt] + "." + digits[pointAt:])
+}
+
+// ?isDigits
+func isDigits(text string) bool {
+	for _, character := range text {
+		if character < '0' || character > '9' {
+			return false
+		}
+	}
+	return true
+}
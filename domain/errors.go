@@ -0,0 +1,77 @@
+package domain
+
+import "errors"
+
+// Sentinel errors every layer can match with errors.Is, whatever message the caller attached.
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrValidation      = errors.New("validation failed")
+	ErrConflict        = errors.New("conflict")
+	ErrUnavailable     = errors.New("unavailable")
+	ErrCanceled        = errors.New("canceled")
+	ErrVersionMismatch = errors.New("version mismatch")
+)
+
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+// Error carries a human readable message while still matching one of the sentinel errors through errors.Is
+type Error struct {
+	Kind    error
+	Message string
+	Fields  []FieldError
+	Cause   error
+}
+
+func (domainError *Error) Error() string {
+	return domainError.Message
+}
+
+func (domainError *Error) Unwrap() []error {
+	if domainError.Cause == nil {
+		return []error{domainError.Kind}
+	}
+	return []error{domainError.Kind, domainError.Cause}
+}
+
+// !NewNotFoundError
+func NewNotFoundError(message string) error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+// !NewValidationError
+func NewValidationError(message string, fields ...FieldError) error {
+	return &Error{Kind: ErrValidation, Message: message, Fields: fields}
+}
+
+// !NewConflictError
+func NewConflictError(message string, cause error) error {
+	return &Error{Kind: ErrConflict, Message: message, Cause: cause}
+}
+
+// !NewUnavailableError
+func NewUnavailableError(message string, cause error) error {
+	return &Error{Kind: ErrUnavailable, Message: message, Cause: cause}
+}
+
+// !NewCanceledError is for work abandoned because the caller went away, e.g. a client that disconnected
+func NewCanceledError(message string, cause error) error {
+	return &Error{Kind: ErrCanceled, Message: message, Cause: cause}
+}
+
+// !NewVersionMismatchError
+func NewVersionMismatchError(message string) error {
+	return &Error{Kind: ErrVersionMismatch, Message: message}
+}
+
+// ?FieldErrorsOf returns the field level details attached to err, if any
+func FieldErrorsOf(err error) []FieldError {
+	var domainError *Error
+	if errors.As(err, &domainError) {
+		return domainError.Fields
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package domain
+
+import "time"
+
+// Price history buckets, an empty bucket returns every recorded change
+const (
+	PriceBucketDay  = "day"
+	PriceBucketWeek = "week"
+)
+
+// PriceBuckets is the whitelist of bucket sizes a price history can be grouped by
+var PriceBuckets = []string{PriceBucketDay, PriceBucketWeek}
+
+// PricePoint is the price and discount a product had from RecordedAt until the next point
+type PricePoint struct {
+	Price      Decimal
+	Discount   Decimal
+	Currency   string
+	RecordedAt time.Time
+}
+
+// PriceBucket summarizes the points of one currency recorded within one day or week, starting at Start in UTC. A
+// period in which the currency changed has one bucket per currency, in the order the currencies were first used.
+// Last is the value the bucket closed with.
+type PriceBucket struct {
+	Start        time.Time
+	MinPrice     Decimal
+	MaxPrice     Decimal
+	LastPrice    Decimal
+	MinDiscount  Decimal
+	MaxDiscount  Decimal
+	LastDiscount Decimal
+	Currency     string
+}
+
+// PriceHistoryQuery selects the points of one product recorded in [From, To), both ends are optional
+type PriceHistoryQuery struct {
+	ProductId int64
+	From      *time.Time
+	To        *time.Time
+	Bucket    string
+}
+
+// PriceHistory holds Points when the query had no bucket and Buckets otherwise
+type PriceHistory struct {
+	ProductId int64
+	Bucket    string
+	Points    []PricePoint
+	Buckets   []PriceBucket
+}
+
+// IsPriceBucket
+func IsPriceBucket(bucket string) bool {
+	for _, priceBucket := range PriceBuckets {
+		if priceBucket == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// PriceChanged tells whether going from before to after has to be recorded, a nil before is a new product
+func PriceChanged(before *Product, after Product) bool {
+	return before == nil || before.Price.Cmp(after.Price) != 0 || before.Discount.Cmp(after.Discount) != 0 ||
+		before.Currency != after.Currency
+}
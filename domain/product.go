@@ -1,9 +1,23 @@
 package domain
 
+import "time"
+
+// AnyVersion is passed as the expected version of a write that should not be checked against the stored one
+const AnyVersion int64 = 0
+
+// DefaultTrashRetention is how long deleted products are kept before a purge removes them for good
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// Product.Version starts at 1 and is incremented by every write, so a stale copy can be detected.
+// Store is the name of the store StoreId refers to. DeletedAt is only set for products in the trash.
 type Product struct {
-	Id       int64
-	Name     string
-	Price    float32
-	Discount float32
-	Store    string
+	Id        int64
+	Name      string
+	Price     Decimal
+	Discount  Decimal
+	Currency  string
+	StoreId   int64
+	Store     string
+	Version   int64
+	DeletedAt *time.Time
 }
@@ -0,0 +1,51 @@
+package domain
+
+// Import modes, all or nothing commits no row as soon as one is rejected
+const (
+	ImportAllOrNothing = "all_or_nothing"
+	ImportBestEffort   = "best_effort"
+)
+
+// ImportModes is the whitelist of modes an import can run in
+var ImportModes = []string{ImportAllOrNothing, ImportBestEffort}
+
+const (
+	// ImportBatchSize is the number of rows written per statement, a best effort import commits batch by batch
+	ImportBatchSize = 1000
+	MaxImportRows   = 50000
+)
+
+// Import row statuses, skipped rows were valid but not written because another row was rejected and not attempted
+// rows were valid but not written because the import was aborted before their batch
+const (
+	ImportAccepted     = "accepted"
+	ImportRejected     = "rejected"
+	ImportSkipped      = "skipped"
+	ImportNotAttempted = "not_attempted"
+)
+
+// ImportRowResult reports one line of the imported file, ProductId is only set on accepted rows
+type ImportRowResult struct {
+	Line      int
+	Status    string
+	ProductId int64
+	Errors    []FieldError
+}
+
+type ImportReport struct {
+	Mode      string
+	Committed bool
+	Accepted  int
+	Rejected  int
+	Rows      []ImportRowResult
+}
+
+// IsImportMode
+func IsImportMode(mode string) bool {
+	for _, importMode := range ImportModes {
+		if importMode == mode {
+			return true
+		}
+	}
+	return false
+}
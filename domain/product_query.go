@@ -0,0 +1,60 @@
+package domain
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 500
+)
+
+// SortableProductFields is the whitelist of fields a listing can be ordered by
+var SortableProductFields = []string{"id", "name", "price", "discount", "store"}
+
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// ProductQuery describes which slice of the catalog a listing wants, either by offset or by keyset (AfterId).
+// Stores match store names regardless of case, StoreId restricts the listing to one store.
+type ProductQuery struct {
+	StoreId     int64
+	Stores      []string
+	Name        string
+	MinPrice    *Decimal
+	MaxPrice    *Decimal
+	MinDiscount *Decimal
+	Sort        []SortField
+	Limit       int
+	Offset      int
+	AfterId     int64
+}
+
+type ProductPage struct {
+	Items      []Product
+	Total      int64
+	NextCursor int64
+	HasMore    bool
+}
+
+// IsSortableProductField
+func IsSortableProductField(field string) bool {
+	for _, sortableField := range SortableProductFields {
+		if sortableField == field {
+			return true
+		}
+	}
+	return false
+}
+
+// NewProductPage trims rows fetched with one extra element down to the limit; a keyset cursor is only derived
+// when the query keeps the default id ordering
+func NewProductPage(rows []Product, query ProductQuery, total int64) ProductPage {
+	productPage := ProductPage{Items: rows, Total: total}
+	if len(rows) > query.Limit {
+		productPage.Items = rows[:query.Limit]
+		productPage.HasMore = true
+	}
+	if productPage.HasMore && len(query.Sort) == 0 && len(productPage.Items) > 0 {
+		productPage.NextCursor = productPage.Items[len(productPage.Items)-1].Id
+	}
+	return productPage
+}
@@ -0,0 +1,19 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// Store owns products, which refer to it by Id. Names are unique regardless of case.
+type Store struct {
+	Id        int64
+	Name      string
+	CreatedAt time.Time
+}
+
+// NormalizeStoreName trims name and collapses runs of whitespace into one space, so "abc  tech " and "abc tech"
+// name the same store. Case is kept for display, the store table compares names case-insensitively.
+func NormalizeStoreName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
@@ -2,30 +2,236 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"os/signal"
 	"product-app/common/app"
+	"product-app/common/health"
+	"product-app/common/logging"
+	"product-app/common/metrics"
 	"product-app/common/postgresql"
+	"product-app/common/tracing"
 	"product-app/controller"
 	"product-app/persistence"
+	"product-app/persistence/migration"
 	"product-app/service"
+	"syscall"
+	"time"
 
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/labstack/echo/v4"
 )
 
 func main() {
-	ctx := context.Background()
-	e := echo.New()
+	os.Exit(run())
+}
+
+// ?run returns the process exit code so deferred cleanups still happen before exiting
+func run() int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// Only the first signal starts a graceful shutdown, restoring the default handling lets a second one
+		// kill the process while it is still draining
+		<-ctx.Done()
+		stop()
+	}()
+
+	configurationManager, args, configErr := app.NewConfigurationManager(os.Args[1:])
+	if configErr != nil {
+		fmt.Fprintln(os.Stderr, configErr)
+		return 2
+	}
+
+	logger, loggerErr := logging.NewLogger(configurationManager.LogConfig, os.Stdout)
+	if loggerErr != nil {
+		fmt.Fprintln(os.Stderr, loggerErr)
+		return 2
+	}
+	slog.SetDefault(logger)
+
+	tracer, tracerErr := tracing.NewTracerFromConfig(configurationManager.TraceConfig, os.Stdout, logger)
+	if tracerErr != nil {
+		fmt.Fprintln(os.Stderr, tracerErr)
+		return 2
+	}
+	defer shutdownTracer(tracer, logger)
+
+	dbPool, poolErr := postgresql.NewConnectionPool(configurationManager.PostgreSqlConfig)
+	if poolErr != nil {
+		fmt.Fprintln(os.Stderr, poolErr)
+		return 2
+	}
+	// Once the application owns the pool it closes it after draining, until then returning closes it here
+	poolOwnedByApplication := false
+	defer func() {
+		if !poolOwnedByApplication {
+			dbPool.Close()
+		}
+	}()
+
+	migrator, migratorErr := migration.NewMigrator(dbPool, logger)
+	if migratorErr != nil {
+		logger.Error("Unable to load migrations", "error", migratorErr)
+		return 1
+	}
+
+	retryPolicy := postgresql.DefaultRetryPolicy(configurationManager.PostgreSqlConfig.ConnectRetryBudget)
+	databaseErr := postgresql.WaitForDatabase(ctx, dbPool, retryPolicy, logger)
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if databaseErr != nil {
+			fmt.Fprintln(os.Stderr, databaseErr)
+			return 1
+		}
+		if err := runMigrateCommand(ctx, migrator, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	if databaseErr == nil {
+		_, databaseErr = migrator.Up(ctx)
+	}
+	if databaseErr != nil {
+		if !configurationManager.ServerConfig.StartDegraded {
+			logger.Error("Database is not usable", "error", databaseErr)
+			return 1
+		}
+		logger.Warn("Starting in degraded mode, readiness stays down until the database is migrated", "error", databaseErr)
+		go migrateInBackground(ctx, migrator, logger)
+	}
 
-	configurationManager := app.NewConfigurationManager()
+	healthRegistry := health.NewRegistry(configurationManager.ServerConfig.HealthTimeout)
+	healthRegistry.Register("database", dbPool.Ping)
+	healthRegistry.Register("migrations", func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err == nil && pending > 0 {
+			err = fmt.Errorf("%d migration(s) pending", pending)
+		}
+		return err
+	})
 
-	dbPool := postgresql.GetConnectionPool(ctx, configurationManager.PostgreSqlConfig)
+	metricsRegistry := metrics.NewRegistry()
+	postgresql.RegisterPoolMetrics(metricsRegistry, dbPool)
 
-	productRepository := persistence.NewProductRepository(dbPool)
+	application := app.NewApplication(configurationManager.ServerConfig, newServer(dbPool, healthRegistry, metricsRegistry, tracer, logger), logger)
+	application.OnDraining(healthRegistry.MarkDraining)
+	application.OnShutdown(dbPool.Close)
+	poolOwnedByApplication = true
 
-	productService := service.NewProductService(productRepository)
+	if err := application.Run(ctx); err != nil {
+		logger.Error("Server failed", "error", err)
+		return 1
+	}
+	logger.Info("Server stopped")
+	return 0
+}
+
+// ?migrateInBackground keeps retrying until the database comes up or the process stops
+func migrateInBackground(ctx context.Context, migrator *migration.Migrator, logger *slog.Logger) {
+	retryPolicy := postgresql.DefaultRetryPolicy(time.Duration(math.MaxInt64))
+	err := postgresql.Retry(ctx, retryPolicy, func(ctx context.Context, attempt int) error {
+		_, err := migrator.Up(ctx)
+		if err != nil {
+			logger.Warn("Migration attempt failed", "attempt", attempt, "error", err)
+		}
+		return err
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Giving up on migrations", "error", err)
+		}
+		return
+	}
+	logger.Info("Database reachable and migrated, leaving degraded mode")
+}
 
-	productController := controller.NewProductController(&productService)
+// ?shutdownTracer flushes spans that are still queued for the collector
+func shutdownTracer(tracer *tracing.Tracer, logger *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		logger.Warn("Unable to flush traces", "error", err)
+	}
+}
+
+// ?newServer wires repositories, services and controllers onto a new echo instance
+func newServer(dbPool *pgxpool.Pool, healthRegistry *health.Registry, metricsRegistry *metrics.Registry, tracer *tracing.Tracer, logger *slog.Logger) *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.HTTPErrorHandler = controller.NewHTTPErrorHandler(logger)
+	e.Use(controller.RequestMetrics(metrics.NewHTTPMetrics(metricsRegistry)))
+	e.Use(controller.RequestTracing(tracer))
+	e.Use(controller.RequestActor())
+	e.Use(controller.RequestLogger(logger))
 
-	productController.RegisterRoutes(e)
+	productRepository := persistence.NewInstrumentedProductRepository(
+		persistence.NewProductRepository(dbPool, logger, tracer),
+		metrics.NewQueryMetrics(metricsRegistry),
+	)
+
+	storeRepository := persistence.NewStoreRepository(dbPool, logger, tracer)
+	auditRepository := persistence.NewAuditRepository(dbPool, logger, tracer)
+	priceHistoryRepository := persistence.NewPriceHistoryRepository(dbPool, logger, tracer)
+	transactor := persistence.NewTransactor(dbPool, logger)
+
+	productService := service.NewTracedProductService(
+		service.NewProductService(productRepository, storeRepository, auditRepository, priceHistoryRepository, transactor, logger),
+		tracer,
+	)
+
+	storeService := service.NewStoreService(storeRepository, productRepository, auditRepository, transactor, productService, logger)
+
+	controller.RegisterRoutes(e, controller.Dependencies{
+		HealthRegistry:  healthRegistry,
+		MetricsRegistry: metricsRegistry,
+		ProductService:  productService,
+		StoreService:    storeService,
+	})
+	return e
+}
 
-	e.Start("localhost:8080")
+// ?runMigrateCommand handles "migrate up", "migrate down" and "migrate status"
+func runMigrateCommand(ctx context.Context, migrator *migration.Migrator, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s [flags] migrate up|down|status", os.Args[0])
+	}
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d migration(s) applied\n", len(applied))
+	case "down":
+		reverted, found, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if !found {
+			fmt.Println("No migration to revert")
+			return nil
+		}
+		fmt.Printf("Reverted %d_%s\n", reverted.Version, reverted.Name)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+	return nil
 }
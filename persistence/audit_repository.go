@@ -0,0 +1,176 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"product-app/common/tracing"
+	"product-app/domain"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type IAuditRepository interface {
+	AddAuditRecord(ctx context.Context, auditRecord domain.AuditRecord) (domain.AuditRecord, error)
+	AddAuditRecords(ctx context.Context, auditRecords []domain.AuditRecord) error
+	GetAuditRecords(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error)
+}
+
+// auditColumns is the column order scanAuditRecord relies on
+const auditColumns = "id,product_id,operation,actor,request_id,before,after,changes,created_at"
+
+type AuditRepository struct {
+	dbPool database
+	tracer *tracing.Tracer
+	logger *slog.Logger
+}
+
+// NewAuditRepository traces every statement when tracer is not nil
+func NewAuditRepository(dbPool *pgxpool.Pool, logger *slog.Logger, tracer *tracing.Tracer) IAuditRepository {
+	var db database = dbPool
+	if tracer != nil {
+		db = &tracedDatabase{database: dbPool, tracer: tracer}
+	}
+	return &AuditRepository{
+		dbPool: db,
+		tracer: tracer,
+		logger: logger,
+	}
+}
+
+// *db runs statements in the transaction of ctx when there is one, see ITransactor
+func (auditRepository *AuditRepository) db(ctx context.Context) database {
+	return databaseFor(ctx, auditRepository.dbPool, auditRepository.tracer)
+}
+
+// AddAuditRecord is meant to run in the transaction of the change it records, so both commit or neither does
+func (auditRepository *AuditRepository) AddAuditRecord(ctx context.Context, auditRecord domain.AuditRecord) (domain.AuditRecord, error) {
+	before, beforeErr := nullableJson(auditRecord.Before)
+	after, afterErr := nullableJson(auditRecord.After)
+	changes, changesErr := json.Marshal(auditRecord.Changes)
+	if err := errors.Join(beforeErr, afterErr, changesErr); err != nil {
+		return domain.AuditRecord{}, fmt.Errorf("Unable to encode audit record: %w", err)
+	}
+
+	insertAuditSql := "INSERT INTO product_audit (product_id,operation,actor,request_id,before,after,changes) VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING " + auditColumns
+	queryRow := auditRepository.db(ctx).QueryRow(ctx, insertAuditSql,
+		auditRecord.ProductId, auditRecord.Operation, auditRecord.Actor, auditRecord.RequestId, before, after, string(changes))
+
+	var addedRecord domain.AuditRecord
+	if scanErr := scanAuditRecord(queryRow, &addedRecord); scanErr != nil {
+		auditRepository.logger.ErrorContext(ctx, "Failed to add audit record", "product_id", auditRecord.ProductId, "error", scanErr)
+		return domain.AuditRecord{}, translateError(scanErr, "Failed to add audit record")
+	}
+	return addedRecord, nil
+}
+
+// AddAuditRecords streams a batch with COPY, for imports that would otherwise insert one record per row
+func (auditRepository *AuditRepository) AddAuditRecords(ctx context.Context, auditRecords []domain.AuditRecord) error {
+	copyRows := make([][]interface{}, 0, len(auditRecords))
+	for _, auditRecord := range auditRecords {
+		// COPY encodes jsonb from bytes, a string would be sent without the jsonb version header
+		before, beforeErr := nullableJson(auditRecord.Before)
+		after, afterErr := nullableJson(auditRecord.After)
+		changes, changesErr := json.Marshal(auditRecord.Changes)
+		if err := errors.Join(beforeErr, afterErr, changesErr); err != nil {
+			return fmt.Errorf("Unable to encode audit record: %w", err)
+		}
+		copyRows = append(copyRows, []interface{}{auditRecord.ProductId, auditRecord.Operation, auditRecord.Actor, auditRecord.RequestId,
+			bytesOf(before), bytesOf(after), changes})
+	}
+	_, copyErr := auditRepository.db(ctx).CopyFrom(ctx, pgx.Identifier{"product_audit"},
+		[]string{"product_id", "operation", "actor", "request_id", "before", "after", "changes"}, pgx.CopyFromRows(copyRows))
+	if copyErr != nil {
+		auditRepository.logger.ErrorContext(ctx, "Failed to copy audit records", "count", len(auditRecords), "error", copyErr)
+		return translateError(copyErr, "Failed to copy audit records")
+	}
+	return nil
+}
+
+// !GetAuditRecords
+func (auditRepository *AuditRepository) GetAuditRecords(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	builder := &productQueryBuilder{}
+	if query.ProductId > 0 {
+		builder.addCondition("product_id=$%d", query.ProductId)
+	}
+	if len(query.Actor) > 0 {
+		builder.addCondition("actor=$%d", query.Actor)
+	}
+	if len(query.Operation) > 0 {
+		builder.addCondition("operation=$%d", query.Operation)
+	}
+	if query.From != nil {
+		builder.addCondition("created_at>=$%d", *query.From)
+	}
+	if query.To != nil {
+		builder.addCondition("created_at<$%d", *query.To)
+	}
+	if query.BeforeId > 0 {
+		builder.addCondition("id<$%d", query.BeforeId)
+	}
+	builder.args = append(builder.args, query.Limit+1)
+	auditSql := "SELECT " + auditColumns + " FROM product_audit" + builder.where() + fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(builder.args))
+
+	auditRows, err := auditRepository.db(ctx).Query(ctx, auditSql, builder.args...)
+	if err != nil {
+		auditRepository.logger.ErrorContext(ctx, "Error while getting audit records", "error", err)
+		return domain.AuditPage{}, translateError(err, "Error while getting audit records")
+	}
+	defer auditRows.Close()
+
+	auditRecords := []domain.AuditRecord{}
+	for auditRows.Next() {
+		var auditRecord domain.AuditRecord
+		if scanErr := scanAuditRecord(auditRows, &auditRecord); scanErr != nil {
+			return domain.AuditPage{}, translateError(scanErr, "Error while reading audit row")
+		}
+		auditRecords = append(auditRecords, auditRecord)
+	}
+	if rowsErr := auditRows.Err(); rowsErr != nil {
+		return domain.AuditPage{}, translateError(rowsErr, "Error while iterating audit rows")
+	}
+	return domain.NewAuditPage(auditRecords, query), nil
+}
+
+// ?scanAuditRecord reads a row selected with auditColumns
+func scanAuditRecord(row pgx.Row, auditRecord *domain.AuditRecord) error {
+	var before, after, changes []byte
+	scanErr := row.Scan(&auditRecord.Id, &auditRecord.ProductId, &auditRecord.Operation, &auditRecord.Actor, &auditRecord.RequestId,
+		&before, &after, &changes, &auditRecord.CreatedAt)
+	if scanErr != nil {
+		return scanErr
+	}
+	if len(before) > 0 {
+		auditRecord.Before = &domain.ProductSnapshot{}
+		if err := json.Unmarshal(before, auditRecord.Before); err != nil {
+			return err
+		}
+	}
+	if len(after) > 0 {
+		auditRecord.After = &domain.ProductSnapshot{}
+		if err := json.Unmarshal(after, auditRecord.After); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(changes, &auditRecord.Changes)
+}
+
+// ?bytesOf turns an encoded snapshot back into bytes for COPY, keeping SQL NULL as nil
+func bytesOf(encoded interface{}) interface{} {
+	if encoded == nil {
+		return nil
+	}
+	return []byte(encoded.(string))
+}
+
+// ?nullableJson encodes a snapshot, a nil one becomes SQL NULL
+func nullableJson(snapshot *domain.ProductSnapshot) (interface{}, error) {
+	if snapshot == nil {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(snapshot)
+	return string(encoded), err
+}
@@ -0,0 +1,37 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"product-app/domain"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+// *translateError maps a pgx error onto the domain sentinel errors, keeping the original error in the chain
+func translateError(err error, message string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "23505":
+			return domain.NewConflictError(message, err)
+		case strings.HasPrefix(pgErr.Code, "23"):
+			return &domain.Error{Kind: domain.ErrValidation, Message: message, Cause: err}
+		case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "53"), strings.HasPrefix(pgErr.Code, "57P"):
+			return domain.NewUnavailableError(message, err)
+		}
+		return fmt.Errorf("%s: %w", message, err)
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return domain.NewCanceledError(message, err)
+	}
+	var netErr net.Error
+	if pgconn.Timeout(err) || errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return domain.NewUnavailableError(message, err)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
@@ -0,0 +1,141 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"product-app/common/metrics"
+	"product-app/domain"
+	"time"
+)
+
+// InstrumentedProductRepository records the duration and failures of every call to the wrapped repository.
+// Not found and version mismatches are expected answers rather than failures, so they are not counted as errors.
+type InstrumentedProductRepository struct {
+	productRepository IProductRepository
+	queryMetrics      *metrics.QueryMetrics
+}
+
+func NewInstrumentedProductRepository(productRepository IProductRepository, queryMetrics *metrics.QueryMetrics) IProductRepository {
+	return &InstrumentedProductRepository{
+		productRepository: productRepository,
+		queryMetrics:      queryMetrics,
+	}
+}
+
+// !GetAllProducts
+func (instrumented *InstrumentedProductRepository) GetAllProducts(ctx context.Context) ([]domain.Product, error) {
+	startedAt := time.Now()
+	products, err := instrumented.productRepository.GetAllProducts(ctx)
+	instrumented.observe("GetAllProducts", startedAt, err)
+	return products, err
+}
+
+// !GetAllProductsByStore
+func (instrumented *InstrumentedProductRepository) GetAllProductsByStore(ctx context.Context, storeName string) ([]domain.Product, error) {
+	startedAt := time.Now()
+	products, err := instrumented.productRepository.GetAllProductsByStore(ctx, storeName)
+	instrumented.observe("GetAllProductsByStore", startedAt, err)
+	return products, err
+}
+
+// !GetProducts
+func (instrumented *InstrumentedProductRepository) GetProducts(ctx context.Context, query domain.ProductQuery) (domain.ProductPage, error) {
+	startedAt := time.Now()
+	productPage, err := instrumented.productRepository.GetProducts(ctx, query)
+	instrumented.observe("GetProducts", startedAt, err)
+	return productPage, err
+}
+
+// !StreamProducts
+func (instrumented *InstrumentedProductRepository) StreamProducts(ctx context.Context, query domain.ProductQuery, fn func(product domain.Product) error) error {
+	startedAt := time.Now()
+	err := instrumented.productRepository.StreamProducts(ctx, query, fn)
+	instrumented.observe("StreamProducts", startedAt, err)
+	return err
+}
+
+// !AddProduct
+func (instrumented *InstrumentedProductRepository) AddProduct(ctx context.Context, product domain.Product) (domain.Product, error) {
+	startedAt := time.Now()
+	addedProduct, err := instrumented.productRepository.AddProduct(ctx, product)
+	instrumented.observe("AddProduct", startedAt, err)
+	return addedProduct, err
+}
+
+// !AddProducts
+func (instrumented *InstrumentedProductRepository) AddProducts(ctx context.Context, products []domain.Product) ([]domain.Product, error) {
+	startedAt := time.Now()
+	addedProducts, err := instrumented.productRepository.AddProducts(ctx, products)
+	instrumented.observe("AddProducts", startedAt, err)
+	return addedProducts, err
+}
+
+// !GetProductById
+func (instrumented *InstrumentedProductRepository) GetProductById(ctx context.Context, productId int64) (domain.Product, error) {
+	startedAt := time.Now()
+	product, err := instrumented.productRepository.GetProductById(ctx, productId)
+	instrumented.observe("GetProductById", startedAt, err)
+	return product, err
+}
+
+// !DeleteProductById
+func (instrumented *InstrumentedProductRepository) DeleteProductById(ctx context.Context, productId int64, expectedVersion int64) error {
+	startedAt := time.Now()
+	err := instrumented.productRepository.DeleteProductById(ctx, productId, expectedVersion)
+	instrumented.observe("DeleteProductById", startedAt, err)
+	return err
+}
+
+// !UpdateProductPrice
+func (instrumented *InstrumentedProductRepository) UpdateProductPrice(ctx context.Context, productId int64, newPrice domain.Decimal, expectedVersion int64) error {
+	startedAt := time.Now()
+	err := instrumented.productRepository.UpdateProductPrice(ctx, productId, newPrice, expectedVersion)
+	instrumented.observe("UpdateProductPrice", startedAt, err)
+	return err
+}
+
+// !UpdateProduct
+func (instrumented *InstrumentedProductRepository) UpdateProduct(ctx context.Context, product domain.Product) (domain.Product, error) {
+	startedAt := time.Now()
+	updatedProduct, err := instrumented.productRepository.UpdateProduct(ctx, product)
+	instrumented.observe("UpdateProduct", startedAt, err)
+	return updatedProduct, err
+}
+
+// !GetDeletedProducts
+func (instrumented *InstrumentedProductRepository) GetDeletedProducts(ctx context.Context) ([]domain.Product, error) {
+	startedAt := time.Now()
+	products, err := instrumented.productRepository.GetDeletedProducts(ctx)
+	instrumented.observe("GetDeletedProducts", startedAt, err)
+	return products, err
+}
+
+// !RestoreProductById
+func (instrumented *InstrumentedProductRepository) RestoreProductById(ctx context.Context, productId int64, expectedVersion int64) (domain.Product, error) {
+	startedAt := time.Now()
+	restoredProduct, err := instrumented.productRepository.RestoreProductById(ctx, productId, expectedVersion)
+	instrumented.observe("RestoreProductById", startedAt, err)
+	return restoredProduct, err
+}
+
+// !PurgeDeletedProducts
+func (instrumented *InstrumentedProductRepository) PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) ([]domain.Product, error) {
+	startedAt := time.Now()
+	purgedProducts, err := instrumented.productRepository.PurgeDeletedProducts(ctx, deletedBefore)
+	instrumented.observe("PurgeDeletedProducts", startedAt, err)
+	return purgedProducts, err
+}
+
+// !BumpProductsOfStore
+func (instrumented *InstrumentedProductRepository) BumpProductsOfStore(ctx context.Context, store domain.Store) ([]domain.Product, error) {
+	startedAt := time.Now()
+	bumpedProducts, err := instrumented.productRepository.BumpProductsOfStore(ctx, store)
+	instrumented.observe("BumpProductsOfStore", startedAt, err)
+	return bumpedProducts, err
+}
+
+// *observe
+func (instrumented *InstrumentedProductRepository) observe(method string, startedAt time.Time, err error) {
+	failed := err != nil && !errors.Is(err, domain.ErrNotFound) && !errors.Is(err, domain.ErrVersionMismatch)
+	instrumented.queryMetrics.Observe(method, time.Since(startedAt), failed)
+}
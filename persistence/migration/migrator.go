@@ -0,0 +1,285 @@
+package migration
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+//go:embed sql/*.sql
+var migrationFiles embed.FS
+
+// advisoryLockKey serializes migrations between instances starting at the same time
+const advisoryLockKey int64 = 7_261_846_010
+
+const createMigrationsTableSql = `CREATE TABLE IF NOT EXISTS schema_migrations(
+    version bigint not null primary key,
+    name varchar(255) not null,
+    applied_at timestamptz not null default now()
+)`
+
+type Migration struct {
+	Version int64
+	Name    string
+	UpSql   string
+	DownSql string
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	dbPool     *pgxpool.Pool
+	logger     *slog.Logger
+	migrations []Migration
+}
+
+// !NewMigrator
+func NewMigrator(dbPool *pgxpool.Pool, logger *slog.Logger) (*Migrator, error) {
+	migrations, err := Load(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		dbPool:     dbPool,
+		logger:     logger,
+		migrations: migrations,
+	}, nil
+}
+
+// !Load reads numbered "<version>_<name>.up.sql" and "<version>_<name>.down.sql" pairs from the sql directory
+func Load(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read migrations: %w", err)
+	}
+
+	migrationsByVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		baseName, direction, ok := cutDirection(fileName)
+		if !ok {
+			return nil, fmt.Errorf("Migration %s must end with .up.sql or .down.sql", fileName)
+		}
+		versionText, name, ok := strings.Cut(baseName, "_")
+		version, parseErr := strconv.ParseInt(versionText, 10, 64)
+		if !ok || parseErr != nil || version < 1 {
+			return nil, fmt.Errorf("Migration %s must start with a positive version number", fileName)
+		}
+
+		content, readErr := fs.ReadFile(files, path.Join("sql", fileName))
+		if readErr != nil {
+			return nil, fmt.Errorf("Unable to read migration %s: %w", fileName, readErr)
+		}
+
+		migration, exists := migrationsByVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: name}
+			migrationsByVersion[version] = migration
+		}
+		if migration.Name != name {
+			return nil, fmt.Errorf("Migration version %d is used by both %s and %s", version, migration.Name, name)
+		}
+		if direction == "up" {
+			migration.UpSql = string(content)
+		} else {
+			migration.DownSql = string(content)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range migrationsByVersion {
+		if len(migration.UpSql) == 0 || len(migration.DownSql) == 0 {
+			return nil, fmt.Errorf("Migration %d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// !Up applies every pending migration in version order and returns the ones it applied
+func (migrator *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var applied []Migration
+	err := migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+		appliedVersions, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range migrator.migrations {
+			if _, ok := appliedVersions[migration.Version]; ok {
+				continue
+			}
+			err := runInTransaction(ctx, conn, migration.UpSql,
+				`INSERT INTO schema_migrations (version,name) VALUES ($1,$2)`, migration.Version, migration.Name)
+			if err != nil {
+				return fmt.Errorf("Migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			migrator.logger.InfoContext(ctx, "Migration applied", "version", migration.Version, "name", migration.Name)
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// !Down reverts the most recently applied migration, it returns false when nothing was applied
+func (migrator *Migrator) Down(ctx context.Context) (Migration, bool, error) {
+	var reverted Migration
+	var found bool
+	err := migrator.withLock(ctx, func(conn *pgxpool.Conn) error {
+		appliedVersions, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for index := len(migrator.migrations) - 1; index >= 0 && !found; index-- {
+			if _, ok := appliedVersions[migrator.migrations[index].Version]; ok {
+				reverted = migrator.migrations[index]
+				found = true
+			}
+		}
+		if !found {
+			return nil
+		}
+		err = runInTransaction(ctx, conn, reverted.DownSql,
+			`DELETE FROM schema_migrations WHERE version=$1`, reverted.Version)
+		if err != nil {
+			return fmt.Errorf("Reverting migration %d_%s failed: %w", reverted.Version, reverted.Name, err)
+		}
+		migrator.logger.InfoContext(ctx, "Migration reverted", "version", reverted.Version, "name", reverted.Name)
+		return nil
+	})
+	return reverted, found, err
+}
+
+// !Status lists every known migration with whether and when it was applied
+func (migrator *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := migrator.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to acquire connection for migrations: %w", err)
+	}
+	defer conn.Release()
+
+	appliedVersions, err := appliedMigrations(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []MigrationStatus
+	for _, migration := range migrator.migrations {
+		appliedAt, applied := appliedVersions[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Migration: migration,
+			Applied:   applied,
+			AppliedAt: appliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// !Pending reports how many known migrations have not been applied yet
+func (migrator *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// *withLock holds a session level advisory lock on a dedicated connection while fn runs
+func (migrator *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := migrator.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("Unable to acquire connection for migrations: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, advisoryLockKey); err != nil {
+		return fmt.Errorf("Unable to take migration lock: %w", err)
+	}
+	defer func() {
+		if _, unlockErr := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, advisoryLockKey); unlockErr != nil {
+			migrator.logger.ErrorContext(ctx, "Unable to release migration lock", "error", unlockErr)
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, createMigrationsTableSql); err != nil {
+		return fmt.Errorf("Unable to create schema_migrations table: %w", err)
+	}
+	return fn(conn)
+}
+
+// ?appliedMigrations
+func appliedMigrations(ctx context.Context, conn *pgxpool.Conn) (map[int64]time.Time, error) {
+	var exists bool
+	if err := conn.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("Unable to read applied migrations: %w", err)
+	}
+	appliedVersions := map[int64]time.Time{}
+	if !exists {
+		return appliedVersions, nil
+	}
+
+	rows, err := conn.Query(ctx, `SELECT version,applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("Unable to read applied migrations: %w", err)
+		}
+		appliedVersions[version] = appliedAt
+	}
+	return appliedVersions, rows.Err()
+}
+
+// ?runInTransaction runs a migration script and its bookkeeping statement atomically
+func runInTransaction(ctx context.Context, conn *pgxpool.Conn, script string, bookkeepingSql string, args ...interface{}) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, bookkeepingSql, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ?cutDirection
+func cutDirection(fileName string) (string, string, bool) {
+	if baseName, ok := strings.CutSuffix(fileName, ".up.sql"); ok {
+		return baseName, "up", true
+	}
+	if baseName, ok := strings.CutSuffix(fileName, ".down.sql"); ok {
+		return baseName, "down", true
+	}
+	return "", "", false
+}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS product(
+    id bigserial not null primary key,
+    name varchar(255) not null,
+    price double precision not null,
+    discount double precision,
+    store varchar(255) not null
+);
@@ -0,0 +1,4 @@
+ALTER TABLE product
+    DROP COLUMN IF EXISTS currency,
+    ALTER COLUMN price TYPE double precision,
+    ALTER COLUMN discount TYPE double precision;
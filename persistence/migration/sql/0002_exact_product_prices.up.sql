@@ -0,0 +1,4 @@
+ALTER TABLE product
+    ALTER COLUMN price TYPE numeric(19,4) USING round(price::numeric, 4),
+    ALTER COLUMN discount TYPE numeric(7,4) USING round(discount::numeric, 4),
+    ADD COLUMN IF NOT EXISTS currency char(3) not null default 'TRY';
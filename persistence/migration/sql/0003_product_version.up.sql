@@ -0,0 +1,2 @@
+ALTER TABLE product
+    ADD COLUMN IF NOT EXISTS version bigint not null default 1;
@@ -0,0 +1,7 @@
+-- Products in the trash would come back to life once the column is gone, so they are purged first
+DELETE FROM product WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS product_deleted_at_idx;
+
+ALTER TABLE product
+    DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,4 @@
+ALTER TABLE product
+    ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS product_deleted_at_idx ON product (deleted_at) WHERE deleted_at IS NOT NULL;
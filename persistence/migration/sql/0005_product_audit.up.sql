@@ -0,0 +1,15 @@
+-- No foreign key on product_id, the audit trail has to outlive purged products
+CREATE TABLE IF NOT EXISTS product_audit(
+    id bigserial not null primary key,
+    product_id bigint not null,
+    operation varchar(32) not null,
+    actor varchar(128) not null,
+    request_id varchar(128) not null default '',
+    before jsonb,
+    after jsonb,
+    changes jsonb not null default '[]',
+    created_at timestamptz not null default now()
+);
+
+CREATE INDEX IF NOT EXISTS product_audit_product_id_idx ON product_audit (product_id, id);
+CREATE INDEX IF NOT EXISTS product_audit_created_at_idx ON product_audit (created_at);
@@ -0,0 +1,15 @@
+-- Like the audit log, the history has no foreign key so it survives purged products
+CREATE TABLE IF NOT EXISTS product_price_history(
+    id bigserial not null primary key,
+    product_id bigint not null,
+    price numeric(19,4) not null,
+    discount numeric(7,4) not null default 0,
+    currency char(3) not null,
+    recorded_at timestamptz not null default now()
+);
+
+CREATE INDEX IF NOT EXISTS product_price_history_product_id_idx ON product_price_history (product_id, recorded_at);
+
+-- Every series starts from the price products have today
+INSERT INTO product_price_history (product_id, price, discount, currency)
+SELECT id, price, coalesce(discount, 0), currency FROM product;
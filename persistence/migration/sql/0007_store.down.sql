@@ -0,0 +1,12 @@
+ALTER TABLE product
+    ADD COLUMN IF NOT EXISTS store varchar(255);
+
+UPDATE product SET store=store.name
+FROM store
+WHERE store.id=product.store_id;
+
+ALTER TABLE product
+    ALTER COLUMN store SET NOT NULL,
+    DROP COLUMN store_id;
+
+DROP TABLE IF EXISTS store;
@@ -0,0 +1,29 @@
+CREATE TABLE IF NOT EXISTS store(
+    id bigserial not null primary key,
+    name varchar(255) not null,
+    created_at timestamptz not null default now()
+);
+
+-- Names that only differ in case are the same store, names are stored trimmed with single inner spaces
+CREATE UNIQUE INDEX IF NOT EXISTS store_name_key ON store (lower(name));
+
+-- Every spelling of a store becomes one row named after its most common spelling, ties go to the first in sort order.
+-- Products whose store was blank share a placeholder store so no store ends up with an empty name
+INSERT INTO store (name)
+SELECT mode() WITHIN GROUP (ORDER BY spelling)
+FROM (SELECT coalesce(nullif(btrim(regexp_replace(store, '\s+', ' ', 'g')), ''), 'Unknown store') AS spelling FROM product) spellings
+GROUP BY lower(spelling)
+ORDER BY min(spelling);
+
+ALTER TABLE product
+    ADD COLUMN IF NOT EXISTS store_id bigint REFERENCES store (id);
+
+UPDATE product SET store_id=store.id
+FROM store
+WHERE lower(store.name)=lower(coalesce(nullif(btrim(regexp_replace(product.store, '\s+', ' ', 'g')), ''), 'Unknown store'));
+
+ALTER TABLE product
+    ALTER COLUMN store_id SET NOT NULL,
+    DROP COLUMN store;
+
+CREATE INDEX IF NOT EXISTS product_store_id_idx ON product (store_id);
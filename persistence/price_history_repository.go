@@ -0,0 +1,150 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"product-app/common/tracing"
+	"product-app/domain"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type IPriceHistoryRepository interface {
+	AddPricePoint(ctx context.Context, product domain.Product) error
+	AddPricePoints(ctx context.Context, products []domain.Product) error
+	GetPricePoints(ctx context.Context, query domain.PriceHistoryQuery) ([]domain.PricePoint, error)
+	GetPriceBuckets(ctx context.Context, query domain.PriceHistoryQuery) ([]domain.PriceBucket, error)
+}
+
+type PriceHistoryRepository struct {
+	dbPool database
+	tracer *tracing.Tracer
+	logger *slog.Logger
+}
+
+// NewPriceHistoryRepository traces every statement when tracer is not nil
+func NewPriceHistoryRepository(dbPool *pgxpool.Pool, logger *slog.Logger, tracer *tracing.Tracer) IPriceHistoryRepository {
+	var db database = dbPool
+	if tracer != nil {
+		db = &tracedDatabase{database: dbPool, tracer: tracer}
+	}
+	return &PriceHistoryRepository{
+		dbPool: db,
+		tracer: tracer,
+		logger: logger,
+	}
+}
+
+// *db runs statements in the transaction of ctx when there is one, see ITransactor
+func (priceHistoryRepository *PriceHistoryRepository) db(ctx context.Context) database {
+	return databaseFor(ctx, priceHistoryRepository.dbPool, priceHistoryRepository.tracer)
+}
+
+// AddPricePoint records the current price of product, it is meant to run in the transaction that changed it
+func (priceHistoryRepository *PriceHistoryRepository) AddPricePoint(ctx context.Context, product domain.Product) error {
+	addPricePointSql := "INSERT INTO product_price_history (product_id,price,discount,currency) VALUES ($1,$2,$3,$4)"
+	_, err := priceHistoryRepository.db(ctx).Exec(ctx, addPricePointSql, product.Id, product.Price, product.Discount, product.Currency)
+	if err != nil {
+		priceHistoryRepository.logger.ErrorContext(ctx, "Failed to add price point", "product_id", product.Id, "error", err)
+		return translateError(err, "Failed to add price point")
+	}
+	return nil
+}
+
+// AddPricePoints streams the starting points of a batch of new products with COPY
+func (priceHistoryRepository *PriceHistoryRepository) AddPricePoints(ctx context.Context, products []domain.Product) error {
+	copyRows := make([][]interface{}, 0, len(products))
+	for _, product := range products {
+		price, priceErr := numericOf(product.Price)
+		discount, discountErr := numericOf(product.Discount)
+		if encodeErr := errors.Join(priceErr, discountErr); encodeErr != nil {
+			return fmt.Errorf("Unable to encode price of product %d: %w", product.Id, encodeErr)
+		}
+		copyRows = append(copyRows, []interface{}{product.Id, price, discount, product.Currency})
+	}
+	_, copyErr := priceHistoryRepository.db(ctx).CopyFrom(ctx, pgx.Identifier{"product_price_history"},
+		[]string{"product_id", "price", "discount", "currency"}, pgx.CopyFromRows(copyRows))
+	if copyErr != nil {
+		priceHistoryRepository.logger.ErrorContext(ctx, "Failed to copy price points", "count", len(products), "error", copyErr)
+		return translateError(copyErr, "Failed to copy price points")
+	}
+	return nil
+}
+
+// !GetPricePoints
+func (priceHistoryRepository *PriceHistoryRepository) GetPricePoints(ctx context.Context, query domain.PriceHistoryQuery) ([]domain.PricePoint, error) {
+	builder := priceHistoryConditions(query)
+	pricePointsSql := "SELECT price,discount,currency,recorded_at FROM product_price_history" + builder.where() + " ORDER BY recorded_at,id"
+
+	pricePointRows, err := priceHistoryRepository.db(ctx).Query(ctx, pricePointsSql, builder.args...)
+	if err != nil {
+		priceHistoryRepository.logger.ErrorContext(ctx, "Error while getting price history", "product_id", query.ProductId, "error", err)
+		return nil, translateError(err, "Error while getting price history")
+	}
+	defer pricePointRows.Close()
+
+	pricePoints := []domain.PricePoint{}
+	for pricePointRows.Next() {
+		var pricePoint domain.PricePoint
+		scanErr := pricePointRows.Scan(&pricePoint.Price, &pricePoint.Discount, &pricePoint.Currency, &pricePoint.RecordedAt)
+		if scanErr != nil {
+			return nil, translateError(scanErr, "Error while reading price history row")
+		}
+		pricePoints = append(pricePoints, pricePoint)
+	}
+	if rowsErr := pricePointRows.Err(); rowsErr != nil {
+		return nil, translateError(rowsErr, "Error while iterating price history rows")
+	}
+	return pricePoints, nil
+}
+
+// GetPriceBuckets groups the points by UTC day or ISO week and by currency, so prices in different currencies are
+// never compared. The last values come from the latest point of each bucket.
+func (priceHistoryRepository *PriceHistoryRepository) GetPriceBuckets(ctx context.Context, query domain.PriceHistoryQuery) ([]domain.PriceBucket, error) {
+	builder := priceHistoryConditions(query)
+	builder.args = append(builder.args, query.Bucket)
+	bucketSql := fmt.Sprintf("date_trunc($%d, recorded_at AT TIME ZONE 'UTC')", len(builder.args))
+	latestFirst := "ORDER BY recorded_at DESC,id DESC"
+	priceBucketsSql := "SELECT " + bucketSql + ",min(price),max(price),(array_agg(price " + latestFirst + "))[1]," +
+		"min(discount),max(discount),(array_agg(discount " + latestFirst + "))[1],currency" +
+		" FROM product_price_history" + builder.where() + " GROUP BY 1,currency ORDER BY 1,min(recorded_at)"
+
+	priceBucketRows, err := priceHistoryRepository.db(ctx).Query(ctx, priceBucketsSql, builder.args...)
+	if err != nil {
+		priceHistoryRepository.logger.ErrorContext(ctx, "Error while getting price buckets", "product_id", query.ProductId, "error", err)
+		return nil, translateError(err, "Error while getting price buckets")
+	}
+	defer priceBucketRows.Close()
+
+	priceBuckets := []domain.PriceBucket{}
+	for priceBucketRows.Next() {
+		var priceBucket domain.PriceBucket
+		scanErr := priceBucketRows.Scan(&priceBucket.Start, &priceBucket.MinPrice, &priceBucket.MaxPrice, &priceBucket.LastPrice,
+			&priceBucket.MinDiscount, &priceBucket.MaxDiscount, &priceBucket.LastDiscount, &priceBucket.Currency)
+		if scanErr != nil {
+			return nil, translateError(scanErr, "Error while reading price bucket row")
+		}
+		priceBucket.Start = priceBucket.Start.UTC()
+		priceBuckets = append(priceBuckets, priceBucket)
+	}
+	if rowsErr := priceBucketRows.Err(); rowsErr != nil {
+		return nil, translateError(rowsErr, "Error while iterating price bucket rows")
+	}
+	return priceBuckets, nil
+}
+
+// ?priceHistoryConditions
+func priceHistoryConditions(query domain.PriceHistoryQuery) *productQueryBuilder {
+	builder := &productQueryBuilder{}
+	builder.addCondition("product_id=$%d", query.ProductId)
+	if query.From != nil {
+		builder.addCondition("recorded_at>=$%d", *query.From)
+	}
+	if query.To != nil {
+		builder.addCondition("recorded_at<$%d", *query.To)
+	}
+	return builder
+}
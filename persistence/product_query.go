@@ -0,0 +1,121 @@
+package persistence
+
+import (
+	"fmt"
+	"product-app/domain"
+	"strings"
+)
+
+// sortColumns maps whitelisted sort fields onto columns so user input never reaches the ORDER BY clause
+var sortColumns = map[string]string{
+	"id":       "product.id",
+	"name":     "product.name",
+	"price":    "product.price",
+	"discount": "product.discount",
+	"store":    "store.name",
+}
+
+// *productQueryBuilder collects WHERE conditions together with their positional arguments
+type productQueryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+func (builder *productQueryBuilder) addCondition(condition string, arg interface{}) {
+	builder.args = append(builder.args, arg)
+	builder.conditions = append(builder.conditions, fmt.Sprintf(condition, len(builder.args)))
+}
+
+func (builder *productQueryBuilder) where() string {
+	if len(builder.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(builder.conditions, " AND ")
+}
+
+// ?filterConditions adds the conditions shared by the page and the total count
+func filterConditions(query domain.ProductQuery) *productQueryBuilder {
+	builder := &productQueryBuilder{conditions: []string{notDeleted}}
+	if query.StoreId > 0 {
+		builder.addCondition("product.store_id=$%d", query.StoreId)
+	}
+	if len(query.Stores) > 0 {
+		var storeNames []string
+		for _, storeName := range query.Stores {
+			storeNames = append(storeNames, domain.NormalizeStoreName(storeName))
+		}
+		builder.addCondition("lower(store.name)=ANY(SELECT lower(unnest($%d::text[])))", storeNames)
+	}
+	if len(query.Name) > 0 {
+		builder.addCondition(`product.name ILIKE '%%' || $%d || '%%' ESCAPE '\'`, escapeLike(query.Name))
+	}
+	if query.MinPrice != nil {
+		builder.addCondition("product.price>=$%d", *query.MinPrice)
+	}
+	if query.MaxPrice != nil {
+		builder.addCondition("product.price<=$%d", *query.MaxPrice)
+	}
+	if query.MinDiscount != nil {
+		builder.addCondition("product.discount>=$%d", *query.MinDiscount)
+	}
+	return builder
+}
+
+// ?orderBy always ends with id so pages stay stable between requests
+func orderBy(sortFields []domain.SortField) (string, error) {
+	var columns []string
+	for _, sortField := range sortFields {
+		column, ok := sortColumns[sortField.Field]
+		if !ok {
+			return "", domain.NewValidationError(fmt.Sprintf("Can not sort by %s", sortField.Field))
+		}
+		if sortField.Descending {
+			column += " DESC"
+		}
+		columns = append(columns, column)
+	}
+	columns = append(columns, "product.id")
+	return " ORDER BY " + strings.Join(columns, ","), nil
+}
+
+// ?escapeLike
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// ?buildCountSql
+func buildCountSql(query domain.ProductQuery) (string, []interface{}) {
+	builder := filterConditions(query)
+	return "SELECT COUNT(*) FROM product" + storeJoin + builder.where(), builder.args
+}
+
+// ?buildPageSql fetches one row more than the limit so the caller knows whether another page exists
+func buildPageSql(query domain.ProductQuery) (string, []interface{}, error) {
+	builder := filterConditions(query)
+	if query.AfterId > 0 {
+		builder.addCondition("product.id>$%d", query.AfterId)
+	}
+	orderBySql, err := orderBy(query.Sort)
+	if err != nil {
+		return "", nil, err
+	}
+	pageSql := "SELECT " + productColumns + " FROM product" + storeJoin + builder.where() + orderBySql
+
+	builder.args = append(builder.args, query.Limit+1)
+	pageSql += fmt.Sprintf(" LIMIT $%d", len(builder.args))
+	if query.Offset > 0 {
+		builder.args = append(builder.args, query.Offset)
+		pageSql += fmt.Sprintf(" OFFSET $%d", len(builder.args))
+	}
+	return pageSql, builder.args, nil
+}
+
+// ?buildExportSql selects every matching row in the order of the listing, without paging
+func buildExportSql(query domain.ProductQuery) (string, []interface{}, error) {
+	builder := filterConditions(query)
+	orderBySql, err := orderBy(query.Sort)
+	if err != nil {
+		return "", nil, err
+	}
+	return "SELECT " + productColumns + " FROM product" + storeJoin + builder.where() + orderBySql, builder.args, nil
+}
@@ -4,160 +4,451 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"product-app/common/tracing"
 	"product-app/domain"
-	"product-app/persistence/common"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/labstack/gommon/log"
 )
 
 type IProductRepository interface {
-	GetAllProducts() []domain.Product
-	GetAllProductsByStore(storeName string) []domain.Product
-	AddProduct(product domain.Product) error
-	GetProductById(productId int64) (domain.Product, error)
-	DeleteProductById(productId int64) error
-	UpdateProductPrice(productId int64, newPrice float32) error
+	GetAllProducts(ctx context.Context) ([]domain.Product, error)
+	GetAllProductsByStore(ctx context.Context, storeName string) ([]domain.Product, error)
+	GetProducts(ctx context.Context, query domain.ProductQuery) (domain.ProductPage, error)
+	StreamProducts(ctx context.Context, query domain.ProductQuery, fn func(product domain.Product) error) error
+	AddProduct(ctx context.Context, product domain.Product) (domain.Product, error)
+	AddProducts(ctx context.Context, products []domain.Product) ([]domain.Product, error)
+	GetProductById(ctx context.Context, productId int64) (domain.Product, error)
+	DeleteProductById(ctx context.Context, productId int64, expectedVersion int64) error
+	UpdateProductPrice(ctx context.Context, productId int64, newPrice domain.Decimal, expectedVersion int64) error
+	UpdateProduct(ctx context.Context, product domain.Product) (domain.Product, error)
+	GetDeletedProducts(ctx context.Context) ([]domain.Product, error)
+	RestoreProductById(ctx context.Context, productId int64, expectedVersion int64) (domain.Product, error)
+	PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) ([]domain.Product, error)
+	BumpProductsOfStore(ctx context.Context, store domain.Store) ([]domain.Product, error)
 }
 
+// productColumns is the column order scanProduct relies on, the name of the store comes from storeJoin
+const productColumns = "product.id,product.name,product.price,product.discount,product.store_id,store.name,product.currency,product.version,product.deleted_at"
+
+// storeJoin follows every product read, products only hold the id of their store
+const storeJoin = " JOIN store ON store.id=product.store_id"
+
+// copyFromThreshold is the batch size from which AddProducts switches from INSERT to COPY
+const copyFromThreshold = 100
+
+// notDeleted keeps products in the trash out of every read and write except restore and purge
+const notDeleted = "product.deleted_at IS NULL"
+
 type ProductRepository struct {
-	dbPool *pgxpool.Pool
+	dbPool database
+	tracer *tracing.Tracer
+	logger *slog.Logger
 }
 
-func NewProductRepository(dbPool *pgxpool.Pool) IProductRepository {
+// NewProductRepository traces every statement when tracer is not nil
+func NewProductRepository(dbPool *pgxpool.Pool, logger *slog.Logger, tracer *tracing.Tracer) IProductRepository {
+	var db database = dbPool
+	if tracer != nil {
+		db = &tracedDatabase{database: dbPool, tracer: tracer}
+	}
 	return &ProductRepository{
-		dbPool: dbPool,
+		dbPool: db,
+		tracer: tracer,
+		logger: logger,
 	}
 }
 
+// *db runs statements in the transaction of ctx when there is one, see ITransactor
+func (productRepository *ProductRepository) db(ctx context.Context) database {
+	return databaseFor(ctx, productRepository.dbPool, productRepository.tracer)
+}
+
 // !GetAllProducts
-func (productRepository *ProductRepository) GetAllProducts() []domain.Product {
-	ctx := context.Background()
-	productRows, err := productRepository.dbPool.Query(ctx, "SELECT * FROM product")
+func (productRepository *ProductRepository) GetAllProducts(ctx context.Context) ([]domain.Product, error) {
+	productRows, err := productRepository.db(ctx).Query(ctx, "SELECT "+productColumns+" FROM product"+storeJoin+" WHERE "+notDeleted)
 
 	if err != nil {
-		log.Error("Error while getting products", err)
+		productRepository.logger.ErrorContext(ctx, "Error while getting products", "error", err)
+		return nil, translateError(err, "Error while getting products")
 	}
 	return extractProductsFromRows(productRows)
 }
 
-// !GetAllProductsByStore
-func (productRepository *ProductRepository) GetAllProductsByStore(storeName string) []domain.Product {
-	ctx := context.Background()
+// GetAllProductsByStore matches the store name regardless of case and surrounding whitespace
+func (productRepository *ProductRepository) GetAllProductsByStore(ctx context.Context, storeName string) ([]domain.Product, error) {
+	getProductsByStoreNameSql := "SELECT " + productColumns + " FROM product" + storeJoin + " WHERE lower(store.name)=lower($1) AND " + notDeleted
 
-	getProductsByStoreNameSql := `SELECT * FROM product WHERE store=$1`
-
-	productRows, err := productRepository.dbPool.Query(ctx, getProductsByStoreNameSql, storeName)
+	productRows, err := productRepository.db(ctx).Query(ctx, getProductsByStoreNameSql, domain.NormalizeStoreName(storeName))
 
 	if err != nil {
-		log.Error("Failed to execute query for getting products by store name")
+		productRepository.logger.ErrorContext(ctx, "Failed to execute query for getting products by store name", "store", storeName, "error", err)
+		return nil, translateError(err, fmt.Sprintf("Error while getting products of store %s", storeName))
 	}
 	return extractProductsFromRows(productRows)
 }
 
-// !AddProduct
-func (productRepository *ProductRepository) AddProduct(product domain.Product) error {
-	ctx := context.Background()
+// !GetProducts
+func (productRepository *ProductRepository) GetProducts(ctx context.Context, query domain.ProductQuery) (domain.ProductPage, error) {
+	countSql, countArgs := buildCountSql(query)
 
-	insertProductSql := `INSERT INTO product (name,price,discount,store) VALUES ($1,$2,$3,$4)`
+	var total int64
+	countErr := productRepository.db(ctx).QueryRow(ctx, countSql, countArgs...).Scan(&total)
+	if countErr != nil {
+		return domain.ProductPage{}, translateError(countErr, "Error while counting products")
+	}
 
-	addNewProduct, err := productRepository.dbPool.Exec(ctx, insertProductSql, product.Name, product.Price, product.Discount, product.Store)
+	pageSql, pageArgs, buildErr := buildPageSql(query)
+	if buildErr != nil {
+		return domain.ProductPage{}, buildErr
+	}
 
+	productRows, err := productRepository.db(ctx).Query(ctx, pageSql, pageArgs...)
 	if err != nil {
-		log.Error("Failed to add new product", err)
-		return err
-	} else {
-		log.Info(fmt.Printf("Product add to database successfully: %s", addNewProduct))
+		return domain.ProductPage{}, translateError(err, "Error while getting products")
+	}
+	products, extractErr := extractProductsFromRows(productRows)
+	if extractErr != nil {
+		return domain.ProductPage{}, extractErr
+	}
+	return domain.NewProductPage(products, query, total), nil
+}
+
+// StreamProducts hands the matching products to fn one row at a time as pgx reads them off the connection, so
+// memory stays bounded however many rows match. An error from fn or a cancelled ctx closes the rows and stops the query.
+func (productRepository *ProductRepository) StreamProducts(ctx context.Context, query domain.ProductQuery, fn func(product domain.Product) error) error {
+	exportSql, exportArgs, buildErr := buildExportSql(query)
+	if buildErr != nil {
+		return buildErr
+	}
+
+	productRows, err := productRepository.db(ctx).Query(ctx, exportSql, exportArgs...)
+	if err != nil {
+		productRepository.logger.ErrorContext(ctx, "Error while streaming products", "error", err)
+		return translateError(err, "Error while streaming products")
+	}
+	defer productRows.Close()
+
+	for productRows.Next() {
+		var product domain.Product
+		if scanErr := scanProduct(productRows, &product); scanErr != nil {
+			return translateError(scanErr, "Error while reading product row")
+		}
+		if fnErr := fn(product); fnErr != nil {
+			return fnErr
+		}
+	}
+	if rowsErr := productRows.Err(); rowsErr != nil {
+		return translateError(rowsErr, "Error while iterating product rows")
 	}
 	return nil
 }
 
-// !GetProductById
-func (productRepository *ProductRepository) GetProductById(productId int64) (domain.Product, error) {
-	ctx := context.Background()
+// !AddProduct
+func (productRepository *ProductRepository) AddProduct(ctx context.Context, product domain.Product) (domain.Product, error) {
+	insertProductSql := returningProducts("INSERT INTO product (name,price,discount,store_id,currency) VALUES ($1,$2,$3,$4,$5)")
 
-	getProductById := `SELECT * FROM product WHERE id=$1`
+	queryRow := productRepository.db(ctx).QueryRow(ctx, insertProductSql, product.Name, product.Price, product.Discount, product.StoreId, product.Currency)
 
-	queryRow := productRepository.dbPool.QueryRow(ctx, getProductById, productId)
+	var addedProduct domain.Product
+	scanErr := scanProduct(queryRow, &addedProduct)
 
-	var id int64
-	var name string
-	var price float32
-	var discount float32
-	var store string
+	if scanErr != nil {
+		productRepository.logger.ErrorContext(ctx, "Failed to add new product", "error", scanErr)
+		return domain.Product{}, translateError(scanErr, "Failed to add new product")
+	}
+	productRepository.logger.DebugContext(ctx, "Product added to database", "product_id", addedProduct.Id)
+	return addedProduct, nil
+}
 
-	scanErr := queryRow.Scan(&id, &name, &price, &discount, &store)
+// AddProducts inserts a batch in one statement, large batches are streamed with COPY on ids reserved up front
+// since COPY can not return them
+func (productRepository *ProductRepository) AddProducts(ctx context.Context, products []domain.Product) ([]domain.Product, error) {
+	if len(products) == 0 {
+		return []domain.Product{}, nil
+	}
+	if len(products) < copyFromThreshold {
+		return productRepository.insertProducts(ctx, products)
+	}
 
-	if scanErr != nil && scanErr.Error() == common.NOT_FOUND {
-		return domain.Product{}, errors.New(fmt.Sprintf("Product not found with id %d", productId))
+	idRows, err := productRepository.db(ctx).Query(ctx, "SELECT nextval('product_id_seq') FROM generate_series(1,$1)", len(products))
+	if err != nil {
+		productRepository.logger.ErrorContext(ctx, "Failed to reserve product ids", "count", len(products), "error", err)
+		return nil, translateError(err, "Failed to reserve product ids")
 	}
-	if scanErr != nil {
-		return domain.Product{}, errors.New(fmt.Sprintf("Error while getting product with id %d", productId))
+	addedProducts := make([]domain.Product, 0, len(products))
+	for idRows.Next() {
+		addedProduct := products[len(addedProducts)]
+		if scanErr := idRows.Scan(&addedProduct.Id); scanErr != nil {
+			idRows.Close()
+			return nil, translateError(scanErr, "Failed to reserve product ids")
+		}
+		addedProduct.Version = 1
+		addedProduct.DeletedAt = nil
+		addedProducts = append(addedProducts, addedProduct)
+	}
+	idRows.Close()
+	if rowsErr := idRows.Err(); rowsErr != nil {
+		return nil, translateError(rowsErr, "Failed to reserve product ids")
 	}
 
-	return domain.Product{
-		Id:       id,
-		Name:     name,
-		Price:    price,
-		Discount: discount,
-		Store:    store,
-	}, nil
+	copyRows := make([][]interface{}, 0, len(addedProducts))
+	for _, addedProduct := range addedProducts {
+		price, priceErr := numericOf(addedProduct.Price)
+		discount, discountErr := numericOf(addedProduct.Discount)
+		if encodeErr := errors.Join(priceErr, discountErr); encodeErr != nil {
+			return nil, fmt.Errorf("Unable to encode product %d: %w", addedProduct.Id, encodeErr)
+		}
+		copyRows = append(copyRows, []interface{}{addedProduct.Id, addedProduct.Name, price, discount, addedProduct.StoreId, addedProduct.Currency})
+	}
+	_, copyErr := productRepository.db(ctx).CopyFrom(ctx, pgx.Identifier{"product"},
+		[]string{"id", "name", "price", "discount", "store_id", "currency"}, pgx.CopyFromRows(copyRows))
+	if copyErr != nil {
+		productRepository.logger.ErrorContext(ctx, "Failed to copy products", "count", len(products), "error", copyErr)
+		return nil, translateError(copyErr, "Failed to copy products")
+	}
+	productRepository.logger.DebugContext(ctx, "Products copied to database", "count", len(addedProducts))
+	return addedProducts, nil
 }
 
-// !DeleteProductById
-func (productRepository *ProductRepository) DeleteProductById(productId int64) error {
-	ctx := context.Background()
+// *insertProducts adds a small batch with a single multi-row INSERT
+func (productRepository *ProductRepository) insertProducts(ctx context.Context, products []domain.Product) ([]domain.Product, error) {
+	var values []string
+	var args []interface{}
+	for _, product := range products {
+		values = append(values, fmt.Sprintf("($%d,$%d,$%d,$%d,$%d)", len(args)+1, len(args)+2, len(args)+3, len(args)+4, len(args)+5))
+		args = append(args, product.Name, product.Price, product.Discount, product.StoreId, product.Currency)
+	}
+	// Ids follow the order of the VALUES, so ordering by id hands the products back in the order they were given
+	insertProductsSql := returningProducts("INSERT INTO product (name,price,discount,store_id,currency) VALUES "+strings.Join(values, ",")) + " ORDER BY product.id"
 
-	_, getErr := productRepository.GetProductById(productId)
+	productRows, err := productRepository.db(ctx).Query(ctx, insertProductsSql, args...)
+	if err != nil {
+		productRepository.logger.ErrorContext(ctx, "Failed to add products", "count", len(products), "error", err)
+		return nil, translateError(err, "Failed to add products")
+	}
+	addedProducts, extractErr := extractProductsFromRows(productRows)
+	if extractErr != nil {
+		productRepository.logger.ErrorContext(ctx, "Failed to add products", "count", len(products), "error", extractErr)
+		return nil, translateError(extractErr, "Failed to add products")
+	}
+	return addedProducts, nil
+}
 
-	if getErr != nil {
-		return errors.New("Product not found")
+// !GetProductById
+func (productRepository *ProductRepository) GetProductById(ctx context.Context, productId int64) (domain.Product, error) {
+	getProductById := "SELECT " + productColumns + " FROM product" + storeJoin + " WHERE product.id=$1 AND " + notDeleted
+	if transactionFrom(ctx) != nil {
+		// A write is about to follow, lock the row so nothing changes it in between
+		getProductById += " FOR UPDATE OF product"
 	}
 
-	deleteProductSql := "DELETE FROM product WHERE id=$1"
+	queryRow := productRepository.db(ctx).QueryRow(ctx, getProductById, productId)
 
-	_, err := productRepository.dbPool.Exec(ctx, deleteProductSql, productId)
+	var product domain.Product
+	scanErr := scanProduct(queryRow, &product)
+
+	if errors.Is(scanErr, pgx.ErrNoRows) {
+		return domain.Product{}, domain.NewNotFoundError(fmt.Sprintf("Product not found with id %d", productId))
+	}
+	if scanErr != nil {
+		return domain.Product{}, translateError(scanErr, fmt.Sprintf("Error while getting product with id %d", productId))
+	}
+	return product, nil
+}
+
+// DeleteProductById moves the product to the trash, it stays there until RestoreProductById or PurgeDeletedProducts
+func (productRepository *ProductRepository) DeleteProductById(ctx context.Context, productId int64, expectedVersion int64) error {
+	builder := versionedConditions(nil, productId, expectedVersion, false)
+	deleteProductSql := "UPDATE product SET deleted_at=now(),version=version+1" + builder.where()
+
+	commandTag, err := productRepository.db(ctx).Exec(ctx, deleteProductSql, builder.args...)
 
 	if err != nil {
-		return errors.New(fmt.Sprintf("Error while delete product with id %d", productId))
+		return translateError(err, fmt.Sprintf("Error while delete product with id %d", productId))
+	}
+	if commandTag.RowsAffected() == 0 {
+		return productRepository.missingOrStale(ctx, productId, expectedVersion, false)
 	}
-	log.Info("Product deleted successfully")
+	productRepository.logger.DebugContext(ctx, "Product moved to trash", "product_id", productId)
 	return nil
 }
 
 // !UpdateProductPrice
-func (productRepository *ProductRepository) UpdateProductPrice(productId int64, newPrice float32) error {
-	ctx := context.Background()
-
-	updateProductSql := `UPDATE product SET price=$1 WHERE id=$2`
-	_, err := productRepository.dbPool.Exec(ctx, updateProductSql, newPrice, productId)
+func (productRepository *ProductRepository) UpdateProductPrice(ctx context.Context, productId int64, newPrice domain.Decimal, expectedVersion int64) error {
+	builder := versionedConditions([]interface{}{newPrice}, productId, expectedVersion, false)
+	updateProductSql := "UPDATE product SET price=$1,version=version+1" + builder.where()
+	commandTag, err := productRepository.db(ctx).Exec(ctx, updateProductSql, builder.args...)
 
 	if err != nil {
-		return errors.New(fmt.Sprintf("Error while updating product with id %d", productId))
+		return translateError(err, fmt.Sprintf("Error while updating product with id %d", productId))
+	}
+	if commandTag.RowsAffected() == 0 {
+		return productRepository.missingOrStale(ctx, productId, expectedVersion, false)
 	}
-	log.Info("Product price update successfully")
+	productRepository.logger.DebugContext(ctx, "Product price updated in database", "product_id", productId)
 	return nil
 }
 
+// UpdateProduct only writes when product.Version is still the stored one, unless it is domain.AnyVersion
+func (productRepository *ProductRepository) UpdateProduct(ctx context.Context, product domain.Product) (domain.Product, error) {
+	builder := versionedConditions([]interface{}{product.Name, product.Price, product.Discount, product.StoreId, product.Currency}, product.Id, product.Version, false)
+	updateProductSql := returningProducts("UPDATE product SET name=$1,price=$2,discount=$3,store_id=$4,currency=$5,version=version+1" + builder.where())
+
+	queryRow := productRepository.db(ctx).QueryRow(ctx, updateProductSql, builder.args...)
+
+	var updatedProduct domain.Product
+	scanErr := scanProduct(queryRow, &updatedProduct)
+
+	if errors.Is(scanErr, pgx.ErrNoRows) {
+		return domain.Product{}, productRepository.missingOrStale(ctx, product.Id, product.Version, false)
+	}
+	if scanErr != nil {
+		return domain.Product{}, translateError(scanErr, fmt.Sprintf("Error while updating product with id %d", product.Id))
+	}
+	productRepository.logger.DebugContext(ctx, "Product updated in database", "product_id", product.Id)
+	return updatedProduct, nil
+}
+
+// !GetDeletedProducts
+func (productRepository *ProductRepository) GetDeletedProducts(ctx context.Context) ([]domain.Product, error) {
+	productRows, err := productRepository.db(ctx).Query(ctx, "SELECT "+productColumns+" FROM product"+storeJoin+" WHERE product.deleted_at IS NOT NULL ORDER BY product.deleted_at DESC,product.id")
+
+	if err != nil {
+		productRepository.logger.ErrorContext(ctx, "Error while getting deleted products", "error", err)
+		return nil, translateError(err, "Error while getting deleted products")
+	}
+	return extractProductsFromRows(productRows)
+}
+
+// !RestoreProductById
+func (productRepository *ProductRepository) RestoreProductById(ctx context.Context, productId int64, expectedVersion int64) (domain.Product, error) {
+	builder := versionedConditions(nil, productId, expectedVersion, true)
+	restoreProductSql := returningProducts("UPDATE product SET deleted_at=NULL,version=version+1" + builder.where())
+
+	queryRow := productRepository.db(ctx).QueryRow(ctx, restoreProductSql, builder.args...)
+
+	var restoredProduct domain.Product
+	scanErr := scanProduct(queryRow, &restoredProduct)
+
+	if errors.Is(scanErr, pgx.ErrNoRows) {
+		return domain.Product{}, productRepository.missingOrStale(ctx, productId, expectedVersion, true)
+	}
+	if scanErr != nil {
+		return domain.Product{}, translateError(scanErr, fmt.Sprintf("Error while restoring product with id %d", productId))
+	}
+	productRepository.logger.DebugContext(ctx, "Product restored from trash", "product_id", productId)
+	return restoredProduct, nil
+}
+
+// PurgeDeletedProducts removes products that went to the trash before deletedBefore for good and returns them
+func (productRepository *ProductRepository) PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) ([]domain.Product, error) {
+	productRows, err := productRepository.db(ctx).Query(ctx, returningProducts("DELETE FROM product WHERE deleted_at<$1"), deletedBefore)
+
+	if err != nil {
+		productRepository.logger.ErrorContext(ctx, "Error while purging deleted products", "error", err)
+		return nil, translateError(err, "Error while purging deleted products")
+	}
+	purgedProducts, extractErr := extractProductsFromRows(productRows)
+	if extractErr != nil {
+		return nil, extractErr
+	}
+	productRepository.logger.DebugContext(ctx, "Deleted products purged from database", "count", len(purgedProducts))
+	return purgedProducts, nil
+}
+
+// BumpProductsOfStore moves every product of store to a new version, those in the trash included, so ETags change
+// together with the store name the products are read with. It returns the products as they read now.
+func (productRepository *ProductRepository) BumpProductsOfStore(ctx context.Context, store domain.Store) ([]domain.Product, error) {
+	productRows, err := productRepository.db(ctx).Query(ctx, returningProducts("UPDATE product SET version=version+1 WHERE store_id=$1")+" ORDER BY product.id", store.Id)
+
+	if err != nil {
+		productRepository.logger.ErrorContext(ctx, "Error while bumping products of store", "store_id", store.Id, "error", err)
+		return nil, translateError(err, fmt.Sprintf("Error while updating products of store %d", store.Id))
+	}
+	bumpedProducts, extractErr := extractProductsFromRows(productRows)
+	if extractErr != nil {
+		return nil, extractErr
+	}
+	productRepository.logger.DebugContext(ctx, "Products of store bumped in database", "store_id", store.Id, "count", len(bumpedProducts))
+	return bumpedProducts, nil
+}
+
+// *missingOrStale explains why a versioned write matched no row, the product is either gone or was changed meanwhile.
+// deleted tells whether the write was looking for a product in the trash.
+func (productRepository *ProductRepository) missingOrStale(ctx context.Context, productId int64, expectedVersion int64, deleted bool) error {
+	notFoundErr := domain.NewNotFoundError(fmt.Sprintf("Product not found with id %d", productId))
+	if deleted {
+		notFoundErr = domain.NewNotFoundError(fmt.Sprintf("Deleted product not found with id %d", productId))
+	}
+	if expectedVersion == domain.AnyVersion {
+		return notFoundErr
+	}
+
+	var currentVersion int64
+	scanErr := productRepository.db(ctx).QueryRow(ctx, "SELECT version FROM product WHERE id=$1 AND (deleted_at IS NOT NULL)=$2", productId, deleted).Scan(&currentVersion)
+
+	if errors.Is(scanErr, pgx.ErrNoRows) {
+		return notFoundErr
+	}
+	if scanErr != nil {
+		return translateError(scanErr, fmt.Sprintf("Error while getting version of product with id %d", productId))
+	}
+	return domain.NewVersionMismatchError(fmt.Sprintf("Product %d is at version %d, not %d", productId, currentVersion, expectedVersion))
+}
+
+// ?versionedConditions targets one product, and only its expected version unless that is domain.AnyVersion.
+// setArgs come first so the SET clause can refer to them as $1, $2 and so on.
+func versionedConditions(setArgs []interface{}, productId int64, expectedVersion int64, deleted bool) *productQueryBuilder {
+	builder := &productQueryBuilder{args: setArgs}
+	builder.addCondition("product.id=$%d", productId)
+	if deleted {
+		builder.conditions = append(builder.conditions, "product.deleted_at IS NOT NULL")
+	} else {
+		builder.conditions = append(builder.conditions, notDeleted)
+	}
+	if expectedVersion != domain.AnyVersion {
+		builder.addCondition("product.version=$%d", expectedVersion)
+	}
+	return builder
+}
+
+// ?returningProducts reads the rows written by writeSql together with their store, RETURNING can not join
+func returningProducts(writeSql string) string {
+	return "WITH written AS (" + writeSql + " RETURNING *) SELECT " + productColumns + " FROM written product" + storeJoin
+}
+
+// ?scanProduct reads a row selected with productColumns
+func scanProduct(row pgx.Row, product *domain.Product) error {
+	return row.Scan(&product.Id, &product.Name, &product.Price, &product.Discount, &product.StoreId, &product.Store, &product.Currency, &product.Version, &product.DeletedAt)
+}
+
+// ?numericOf converts a Decimal for COPY, which needs binary encoders instead of the text Value gives
+func numericOf(decimal domain.Decimal) (pgtype.Numeric, error) {
+	var numeric pgtype.Numeric
+	err := numeric.Set(decimal.String())
+	return numeric, err
+}
+
 // ?extractProductsFromRows
-func extractProductsFromRows(productRows pgx.Rows) []domain.Product {
-	var products = []domain.Product{}
-	var id int64
-	var name string
-	var price float32
-	var discount float32
-	var store string
+func extractProductsFromRows(productRows pgx.Rows) ([]domain.Product, error) {
+	defer productRows.Close()
 
+	var products = []domain.Product{}
 	for productRows.Next() {
-		productRows.Scan(&id, &name, &price, &discount, &store)
-		products = append(products, domain.Product{
-			Id:       id,
-			Name:     name,
-			Price:    price,
-			Discount: discount,
-			Store:    store,
-		})
-	}
-	return products
+		var product domain.Product
+		scanErr := scanProduct(productRows, &product)
+		if scanErr != nil {
+			return nil, translateError(scanErr, "Error while reading product row")
+		}
+		products = append(products, product)
+	}
+	if rowsErr := productRows.Err(); rowsErr != nil {
+		return nil, translateError(rowsErr, "Error while iterating product rows")
+	}
+	return products, nil
 }
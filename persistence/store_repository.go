@@ -0,0 +1,154 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"product-app/common/tracing"
+	"product-app/domain"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+type IStoreRepository interface {
+	GetAllStores(ctx context.Context) ([]domain.Store, error)
+	GetStoreById(ctx context.Context, storeId int64) (domain.Store, error)
+	AddStore(ctx context.Context, name string) (domain.Store, error)
+	EnsureStore(ctx context.Context, name string) (domain.Store, error)
+	RenameStore(ctx context.Context, storeId int64, name string) (domain.Store, error)
+	DeleteStoreById(ctx context.Context, storeId int64) error
+}
+
+// storeColumns is the column order scanStore relies on
+const storeColumns = "id,name,created_at"
+
+type StoreRepository struct {
+	dbPool database
+	tracer *tracing.Tracer
+	logger *slog.Logger
+}
+
+// NewStoreRepository traces every statement when tracer is not nil
+func NewStoreRepository(dbPool *pgxpool.Pool, logger *slog.Logger, tracer *tracing.Tracer) IStoreRepository {
+	var db database = dbPool
+	if tracer != nil {
+		db = &tracedDatabase{database: dbPool, tracer: tracer}
+	}
+	return &StoreRepository{
+		dbPool: db,
+		tracer: tracer,
+		logger: logger,
+	}
+}
+
+// *db runs statements in the transaction of ctx when there is one, see ITransactor
+func (storeRepository *StoreRepository) db(ctx context.Context) database {
+	return databaseFor(ctx, storeRepository.dbPool, storeRepository.tracer)
+}
+
+// !GetAllStores
+func (storeRepository *StoreRepository) GetAllStores(ctx context.Context) ([]domain.Store, error) {
+	storeRows, err := storeRepository.db(ctx).Query(ctx, "SELECT "+storeColumns+" FROM store ORDER BY lower(name),id")
+	if err != nil {
+		storeRepository.logger.ErrorContext(ctx, "Error while getting stores", "error", err)
+		return nil, translateError(err, "Error while getting stores")
+	}
+	defer storeRows.Close()
+
+	stores := []domain.Store{}
+	for storeRows.Next() {
+		var store domain.Store
+		if scanErr := scanStore(storeRows, &store); scanErr != nil {
+			return nil, translateError(scanErr, "Error while reading store row")
+		}
+		stores = append(stores, store)
+	}
+	if rowsErr := storeRows.Err(); rowsErr != nil {
+		return nil, translateError(rowsErr, "Error while iterating store rows")
+	}
+	return stores, nil
+}
+
+// !GetStoreById
+func (storeRepository *StoreRepository) GetStoreById(ctx context.Context, storeId int64) (domain.Store, error) {
+	queryRow := storeRepository.db(ctx).QueryRow(ctx, "SELECT "+storeColumns+" FROM store WHERE id=$1", storeId)
+
+	var store domain.Store
+	scanErr := scanStore(queryRow, &store)
+	if errors.Is(scanErr, pgx.ErrNoRows) {
+		return domain.Store{}, domain.NewNotFoundError(fmt.Sprintf("Store not found with id %d", storeId))
+	}
+	if scanErr != nil {
+		return domain.Store{}, translateError(scanErr, fmt.Sprintf("Error while getting store with id %d", storeId))
+	}
+	return store, nil
+}
+
+// AddStore fails with a conflict when a store of the same name exists, whatever its case
+func (storeRepository *StoreRepository) AddStore(ctx context.Context, name string) (domain.Store, error) {
+	queryRow := storeRepository.db(ctx).QueryRow(ctx, "INSERT INTO store (name) VALUES ($1) RETURNING "+storeColumns, name)
+
+	var addedStore domain.Store
+	if scanErr := scanStore(queryRow, &addedStore); scanErr != nil {
+		storeRepository.logger.ErrorContext(ctx, "Failed to add new store", "store", name, "error", scanErr)
+		return domain.Store{}, translateError(scanErr, fmt.Sprintf("Store %s already exists or could not be added", name))
+	}
+	storeRepository.logger.DebugContext(ctx, "Store added to database", "store_id", addedStore.Id)
+	return addedStore, nil
+}
+
+// EnsureStore returns the store named name regardless of case, adding it when there is none yet. The no-op
+// update on conflict lets RETURNING hand back the existing row, also when a concurrent insert won the race.
+func (storeRepository *StoreRepository) EnsureStore(ctx context.Context, name string) (domain.Store, error) {
+	ensureStoreSql := "INSERT INTO store (name) VALUES ($1) ON CONFLICT ((lower(name))) DO UPDATE SET name=store.name RETURNING " + storeColumns
+	queryRow := storeRepository.db(ctx).QueryRow(ctx, ensureStoreSql, name)
+
+	var store domain.Store
+	if scanErr := scanStore(queryRow, &store); scanErr != nil {
+		storeRepository.logger.ErrorContext(ctx, "Failed to ensure store", "store", name, "error", scanErr)
+		return domain.Store{}, translateError(scanErr, fmt.Sprintf("Error while getting store %s", name))
+	}
+	return store, nil
+}
+
+// RenameStore changes the name every product of the store is listed under
+func (storeRepository *StoreRepository) RenameStore(ctx context.Context, storeId int64, name string) (domain.Store, error) {
+	queryRow := storeRepository.db(ctx).QueryRow(ctx, "UPDATE store SET name=$1 WHERE id=$2 RETURNING "+storeColumns, name, storeId)
+
+	var renamedStore domain.Store
+	scanErr := scanStore(queryRow, &renamedStore)
+	if errors.Is(scanErr, pgx.ErrNoRows) {
+		return domain.Store{}, domain.NewNotFoundError(fmt.Sprintf("Store not found with id %d", storeId))
+	}
+	if scanErr != nil {
+		return domain.Store{}, translateError(scanErr, fmt.Sprintf("Store %s already exists or could not be renamed", name))
+	}
+	storeRepository.logger.DebugContext(ctx, "Store renamed in database", "store_id", storeId)
+	return renamedStore, nil
+}
+
+// DeleteStoreById fails with a conflict while products refer to the store, including products in the trash
+func (storeRepository *StoreRepository) DeleteStoreById(ctx context.Context, storeId int64) error {
+	commandTag, err := storeRepository.db(ctx).Exec(ctx, "DELETE FROM store WHERE id=$1", storeId)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return domain.NewConflictError(fmt.Sprintf("Store %d still has products", storeId), err)
+	}
+	if err != nil {
+		return translateError(err, fmt.Sprintf("Error while deleting store with id %d", storeId))
+	}
+	if commandTag.RowsAffected() == 0 {
+		return domain.NewNotFoundError(fmt.Sprintf("Store not found with id %d", storeId))
+	}
+	storeRepository.logger.DebugContext(ctx, "Store deleted from database", "store_id", storeId)
+	return nil
+}
+
+// ?scanStore reads a row selected with storeColumns
+func scanStore(row pgx.Row, store *domain.Store) error {
+	return row.Scan(&store.Id, &store.Name, &store.CreatedAt)
+}
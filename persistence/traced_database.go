@@ -0,0 +1,114 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"product-app/common/tracing"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// database is the part of pgxpool.Pool the repository uses, narrowed so statements can be traced
+type database interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// *tracedDatabase opens a client span per statement carrying the SQL text and the number of rows
+type tracedDatabase struct {
+	database database
+	tracer   *tracing.Tracer
+}
+
+func (tracedDb *tracedDatabase) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, span := tracedDb.startSpan(ctx, sql)
+	rows, err := tracedDb.database.Query(ctx, sql, args...)
+	if err != nil {
+		span.RecordError(err)
+		span.End()
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (tracedDb *tracedDatabase) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	ctx, span := tracedDb.startSpan(ctx, sql)
+	return &tracedRow{row: tracedDb.database.QueryRow(ctx, sql, args...), span: span}
+}
+
+func (tracedDb *tracedDatabase) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, span := tracedDb.startSpan(ctx, sql)
+	defer span.End()
+	commandTag, err := tracedDb.database.Exec(ctx, sql, args...)
+	span.RecordError(err)
+	if err == nil {
+		span.SetAttributes(tracing.Int64("db.rows_affected", commandTag.RowsAffected()))
+	}
+	return commandTag, err
+}
+
+func (tracedDb *tracedDatabase) CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error) {
+	ctx, span := tracedDb.startSpan(ctx, fmt.Sprintf("COPY %s (%s) FROM STDIN", tableName.Sanitize(), strings.Join(columnNames, ",")))
+	defer span.End()
+	copied, err := tracedDb.database.CopyFrom(ctx, tableName, columnNames, rowSrc)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Int64("db.rows_affected", copied))
+	return copied, err
+}
+
+// *startSpan names the span after the statement verb, the full text goes into db.statement
+func (tracedDb *tracedDatabase) startSpan(ctx context.Context, sql string) (context.Context, *tracing.Span) {
+	name := "SQL"
+	if fields := strings.Fields(sql); len(fields) > 0 {
+		name = strings.ToUpper(fields[0])
+	}
+	return tracedDb.tracer.Start(ctx, name, tracing.SpanKindClient,
+		tracing.String("db.system", "postgresql"),
+		tracing.String("db.statement", sql))
+}
+
+// *tracedRows ends the span when the rows are closed, which extractProductsFromRows always does
+type tracedRows struct {
+	pgx.Rows
+	span  *tracing.Span
+	count int64
+}
+
+func (rows *tracedRows) Next() bool {
+	hasNext := rows.Rows.Next()
+	if hasNext {
+		rows.count++
+	}
+	return hasNext
+}
+
+func (rows *tracedRows) Close() {
+	rows.Rows.Close()
+	rows.span.RecordError(rows.Rows.Err())
+	rows.span.SetAttributes(tracing.Int64("db.rows", rows.count))
+	rows.span.End()
+}
+
+// *tracedRow ends the span on Scan, no rows is an answer rather than a failure
+type tracedRow struct {
+	row  pgx.Row
+	span *tracing.Span
+}
+
+func (row *tracedRow) Scan(dest ...interface{}) error {
+	err := row.row.Scan(dest...)
+	rowCount := int64(1)
+	if errors.Is(err, pgx.ErrNoRows) {
+		rowCount = 0
+	} else {
+		row.span.RecordError(err)
+	}
+	row.span.SetAttributes(tracing.Int64("db.rows", rowCount))
+	row.span.End()
+	return err
+}
@@ -0,0 +1,74 @@
+package persistence
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"product-app/common/tracing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ITransactor runs fn in one database transaction. Repositories called with the context fn receives join it,
+// and a nested call joins the outer transaction instead of starting its own.
+type ITransactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type Transactor struct {
+	dbPool *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewTransactor(dbPool *pgxpool.Pool, logger *slog.Logger) ITransactor {
+	return &Transactor{
+		dbPool: dbPool,
+		logger: logger,
+	}
+}
+
+type transactionKey struct{}
+
+// !WithinTransaction commits when fn succeeds and rolls back otherwise
+func (transactor *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if transactionFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	tx, beginErr := transactor.dbPool.Begin(ctx)
+	if beginErr != nil {
+		return translateError(beginErr, "Unable to begin transaction")
+	}
+	defer func() {
+		if rollbackErr := tx.Rollback(context.Background()); rollbackErr != nil && !errors.Is(rollbackErr, pgx.ErrTxClosed) {
+			transactor.logger.WarnContext(ctx, "Unable to roll back transaction", "error", rollbackErr)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, transactionKey{}, tx)); err != nil {
+		return err
+	}
+	if commitErr := tx.Commit(ctx); commitErr != nil {
+		return translateError(commitErr, "Unable to commit transaction")
+	}
+	return nil
+}
+
+// ?transactionFrom
+func transactionFrom(ctx context.Context) pgx.Tx {
+	tx, _ := ctx.Value(transactionKey{}).(pgx.Tx)
+	return tx
+}
+
+// *databaseFor picks the transaction carried by ctx over the pool, traced like the pool when tracer is set
+func databaseFor(ctx context.Context, dbPool database, tracer *tracing.Tracer) database {
+	tx := transactionFrom(ctx)
+	if tx == nil {
+		return dbPool
+	}
+	if tracer != nil {
+		return &tracedDatabase{database: tx, tracer: tracer}
+	}
+	return tx
+}
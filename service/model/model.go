@@ -1,8 +1,27 @@
 package model
 
+import "product-app/domain"
+
 type ProductCreate struct {
 	Name     string
-	Price    float32
-	Discount float32
+	Price    domain.Decimal
+	Discount domain.Decimal
+	Currency string
 	Store    string
 }
+
+// ProductPatch holds the fields of a JSON Merge Patch, nil fields are left untouched
+type ProductPatch struct {
+	Name     *string
+	Price    *domain.Decimal
+	Discount *domain.Decimal
+	Currency *string
+	Store    *string
+}
+
+// ImportRow is one line of an imported file, Errors holds what could not be parsed before validation
+type ImportRow struct {
+	Line    int
+	Product ProductCreate
+	Errors  []domain.FieldError
+}
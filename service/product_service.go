@@ -1,74 +1,642 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"product-app/common/logging"
 	"product-app/domain"
 	"product-app/persistence"
 	"product-app/service/model"
+	"strings"
+	"time"
 )
 
 type IProductService interface {
-	AllProducts() []domain.Product
-	ProductsByStore(storeName string) []domain.Product
-	Add(productCreate model.ProductCreate) error
-	ProductById(productId int64) (domain.Product, error)
-	DeleteById(productId int64) error
-	UpdateProductPrice(productId int64, newPrice float32) error
+	AllProducts(ctx context.Context) ([]domain.Product, error)
+	ProductsByStore(ctx context.Context, storeName string) ([]domain.Product, error)
+	ProductsPage(ctx context.Context, query domain.ProductQuery) (domain.ProductPage, error)
+	Add(ctx context.Context, productCreate model.ProductCreate) (domain.Product, error)
+	ProductById(ctx context.Context, productId int64) (domain.Product, error)
+	DeleteById(ctx context.Context, productId int64, expectedVersion int64) error
+	UpdateProductPrice(ctx context.Context, productId int64, newPrice domain.Decimal, expectedVersion int64) error
+	Update(ctx context.Context, productId int64, productUpdate model.ProductCreate, expectedVersion int64) (domain.Product, error)
+	Patch(ctx context.Context, productId int64, productPatch model.ProductPatch, expectedVersion int64) (domain.Product, error)
+	DeletedProducts(ctx context.Context) ([]domain.Product, error)
+	Restore(ctx context.Context, productId int64, expectedVersion int64) (domain.Product, error)
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error)
+	AuditRecords(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error)
+	PriceHistory(ctx context.Context, query domain.PriceHistoryQuery) (domain.PriceHistory, error)
+	Export(ctx context.Context, query domain.ProductQuery, write func(product domain.Product) error) error
+	Import(ctx context.Context, rows []model.ImportRow, mode string) (domain.ImportReport, error)
 }
 
+// SystemActor is recorded for changes made outside of a request, such as scheduled purges
+const SystemActor = "system"
+
 type ProductService struct {
-	productRepository persistence.IProductRepository
+	productRepository      persistence.IProductRepository
+	storeRepository        persistence.IStoreRepository
+	auditRepository        persistence.IAuditRepository
+	priceHistoryRepository persistence.IPriceHistoryRepository
+	transactor             persistence.ITransactor
+	logger                 *slog.Logger
 }
 
-func NewProductService(productRepository persistence.IProductRepository) IProductService {
+// NewProductService records every mutation in the audit log and every price change in the price history, in the
+// same transaction as the mutation itself. Products name their store, a store that does not exist yet is added.
+func NewProductService(productRepository persistence.IProductRepository, storeRepository persistence.IStoreRepository, auditRepository persistence.IAuditRepository,
+	priceHistoryRepository persistence.IPriceHistoryRepository, transactor persistence.ITransactor, logger *slog.Logger) IProductService {
 	return &ProductService{
-		productRepository: productRepository,
+		productRepository:      productRepository,
+		storeRepository:        storeRepository,
+		auditRepository:        auditRepository,
+		priceHistoryRepository: priceHistoryRepository,
+		transactor:             transactor,
+		logger:                 logger,
 	}
 }
 
 // !Add
-func (productService *ProductService) Add(productCreate model.ProductCreate) error {
+func (productService *ProductService) Add(ctx context.Context, productCreate model.ProductCreate) (domain.Product, error) {
+	if len(productCreate.Currency) == 0 {
+		productCreate.Currency = domain.DefaultCurrency
+	}
 	validateErr := validateProductCreate(productCreate)
 	if validateErr != nil {
-		return validateErr
+		return domain.Product{}, validateErr
 	}
-	return productService.productRepository.AddProduct(domain.Product{
-		Name:     productCreate.Name,
-		Price:    productCreate.Price,
-		Discount: productCreate.Discount,
-		Store:    productCreate.Store,
+
+	var addedProduct domain.Product
+	txErr := productService.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		store, storeErr := productService.storeRepository.EnsureStore(ctx, domain.NormalizeStoreName(productCreate.Store))
+		if storeErr != nil {
+			return storeErr
+		}
+		var addErr error
+		addedProduct, addErr = productService.productRepository.AddProduct(ctx, domain.Product{
+			Name:     productCreate.Name,
+			Price:    productCreate.Price,
+			Discount: productCreate.Discount,
+			Currency: productCreate.Currency,
+			StoreId:  store.Id,
+			Store:    store.Name,
+		})
+		if addErr != nil {
+			return addErr
+		}
+		if recordErr := productService.recordPrice(ctx, nil, addedProduct); recordErr != nil {
+			return recordErr
+		}
+		return productService.audit(ctx, domain.AuditCreate, nil, &addedProduct)
 	})
+	if txErr != nil {
+		return domain.Product{}, txErr
+	}
+	productService.logger.InfoContext(ctx, "Product added", "product_id", addedProduct.Id, "store", addedProduct.Store)
+	return addedProduct, nil
 }
 
 // !DeleteById
-func (productService *ProductService) DeleteById(productId int64) error {
-	return productService.productRepository.DeleteProductById(productId)
+func (productService *ProductService) DeleteById(ctx context.Context, productId int64, expectedVersion int64) error {
+	txErr := productService.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		product, getErr := productService.productRepository.GetProductById(ctx, productId)
+		if getErr != nil {
+			return getErr
+		}
+		versionErr := checkVersion(product, expectedVersion)
+		if versionErr != nil {
+			return versionErr
+		}
+		deleteErr := productService.productRepository.DeleteProductById(ctx, productId, product.Version)
+		if deleteErr != nil {
+			return deleteErr
+		}
+		return productService.audit(ctx, domain.AuditDelete, &product, nil)
+	})
+	if txErr != nil {
+		return txErr
+	}
+	productService.logger.InfoContext(ctx, "Product deleted", "product_id", productId)
+	return nil
 }
 
 // !ProductById
-func (productService *ProductService) ProductById(productId int64) (domain.Product, error) {
-	return productService.productRepository.GetProductById(productId)
+func (productService *ProductService) ProductById(ctx context.Context, productId int64) (domain.Product, error) {
+	return productService.productRepository.GetProductById(ctx, productId)
 }
 
 // !UpdateProductPrice
-func (productService *ProductService) UpdateProductPrice(productId int64, newPrice float32) error {
-	return productService.productRepository.UpdateProductPrice(productId, newPrice)
+func (productService *ProductService) UpdateProductPrice(ctx context.Context, productId int64, newPrice domain.Decimal, expectedVersion int64) error {
+	var product domain.Product
+	txErr := productService.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var getErr error
+		product, getErr = productService.productRepository.GetProductById(ctx, productId)
+		if getErr != nil {
+			return getErr
+		}
+		versionErr := checkVersion(product, expectedVersion)
+		if versionErr != nil {
+			return versionErr
+		}
+		validateErr := validatePrice(newPrice, product.Currency)
+		if validateErr != nil {
+			return validateErr
+		}
+		updateErr := productService.productRepository.UpdateProductPrice(ctx, productId, newPrice, product.Version)
+		if updateErr != nil {
+			return updateErr
+		}
+		// The row is locked since it was read, so the new state follows from the old one
+		updatedProduct := product
+		updatedProduct.Price = newPrice
+		updatedProduct.Version++
+		if recordErr := productService.recordPrice(ctx, &product, updatedProduct); recordErr != nil {
+			return recordErr
+		}
+		return productService.audit(ctx, domain.AuditPriceChange, &product, &updatedProduct)
+	})
+	if txErr != nil {
+		return txErr
+	}
+	productService.logger.InfoContext(ctx, "Product price changed", "product_id", productId,
+		"old_price", product.Price.String(), "new_price", newPrice.String(), "currency", product.Currency)
+	return nil
+}
+
+// !Update
+func (productService *ProductService) Update(ctx context.Context, productId int64, productUpdate model.ProductCreate, expectedVersion int64) (domain.Product, error) {
+	if len(productUpdate.Currency) == 0 {
+		productUpdate.Currency = domain.DefaultCurrency
+	}
+	validateErr := validateProductCreate(productUpdate)
+	if validateErr != nil {
+		return domain.Product{}, validateErr
+	}
+
+	var updatedProduct domain.Product
+	txErr := productService.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		currentProduct, getErr := productService.productRepository.GetProductById(ctx, productId)
+		if getErr != nil {
+			return getErr
+		}
+		versionErr := checkVersion(currentProduct, expectedVersion)
+		if versionErr != nil {
+			return versionErr
+		}
+		var replaceErr error
+		updatedProduct, replaceErr = productService.replace(ctx, currentProduct, productUpdate)
+		return replaceErr
+	})
+	if txErr != nil {
+		return domain.Product{}, txErr
+	}
+	productService.logger.InfoContext(ctx, "Product updated", "product_id", updatedProduct.Id)
+	return updatedProduct, nil
+}
+
+// Patch applies the changes to the version it read, so a concurrent write in between fails instead of being
+// silently overwritten, even when the caller did not ask for a version
+func (productService *ProductService) Patch(ctx context.Context, productId int64, productPatch model.ProductPatch, expectedVersion int64) (domain.Product, error) {
+	var patchedProduct domain.Product
+	txErr := productService.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		currentProduct, getErr := productService.productRepository.GetProductById(ctx, productId)
+		if getErr != nil {
+			return getErr
+		}
+		versionErr := checkVersion(currentProduct, expectedVersion)
+		if versionErr != nil {
+			return versionErr
+		}
+
+		productUpdate := model.ProductCreate{
+			Name:     currentProduct.Name,
+			Price:    currentProduct.Price,
+			Discount: currentProduct.Discount,
+			Currency: currentProduct.Currency,
+			Store:    currentProduct.Store,
+		}
+		if productPatch.Name != nil {
+			productUpdate.Name = *productPatch.Name
+		}
+		if productPatch.Price != nil {
+			productUpdate.Price = *productPatch.Price
+		}
+		if productPatch.Discount != nil {
+			productUpdate.Discount = *productPatch.Discount
+		}
+		if productPatch.Currency != nil {
+			productUpdate.Currency = *productPatch.Currency
+		}
+		if productPatch.Store != nil {
+			productUpdate.Store = *productPatch.Store
+		}
+		validateErr := validateProductCreate(productUpdate)
+		if validateErr != nil {
+			return validateErr
+		}
+		var replaceErr error
+		patchedProduct, replaceErr = productService.replace(ctx, currentProduct, productUpdate)
+		return replaceErr
+	})
+	if txErr != nil {
+		return domain.Product{}, txErr
+	}
+	productService.logger.InfoContext(ctx, "Product updated", "product_id", patchedProduct.Id)
+	return patchedProduct, nil
+}
+
+// !DeletedProducts
+func (productService *ProductService) DeletedProducts(ctx context.Context) ([]domain.Product, error) {
+	return productService.productRepository.GetDeletedProducts(ctx)
+}
+
+// !Restore
+func (productService *ProductService) Restore(ctx context.Context, productId int64, expectedVersion int64) (domain.Product, error) {
+	var restoredProduct domain.Product
+	txErr := productService.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var restoreErr error
+		restoredProduct, restoreErr = productService.productRepository.RestoreProductById(ctx, productId, expectedVersion)
+		if restoreErr != nil {
+			return restoreErr
+		}
+		return productService.audit(ctx, domain.AuditRestore, nil, &restoredProduct)
+	})
+	if txErr != nil {
+		return domain.Product{}, txErr
+	}
+	productService.logger.InfoContext(ctx, "Product restored", "product_id", productId)
+	return restoredProduct, nil
+}
+
+// PurgeDeleted removes products that have been in the trash for longer than retention, zero empties the trash
+func (productService *ProductService) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	validator := &validator{}
+	validator.check(retention >= 0, "olderThan", "min", "Retention can not be negative")
+	if validateErr := validator.err(); validateErr != nil {
+		return 0, validateErr
+	}
+
+	var purgedProducts []domain.Product
+	txErr := productService.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var purgeErr error
+		purgedProducts, purgeErr = productService.productRepository.PurgeDeletedProducts(ctx, time.Now().Add(-retention))
+		if purgeErr != nil {
+			return purgeErr
+		}
+		for index := range purgedProducts {
+			if auditErr := productService.audit(ctx, domain.AuditPurge, &purgedProducts[index], nil); auditErr != nil {
+				return auditErr
+			}
+		}
+		return nil
+	})
+	if txErr != nil {
+		return 0, txErr
+	}
+	productService.logger.InfoContext(ctx, "Deleted products purged", "count", len(purgedProducts), "retention", retention.String())
+	return int64(len(purgedProducts)), nil
+}
+
+// !AuditRecords
+func (productService *ProductService) AuditRecords(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	if query.Limit == 0 {
+		query.Limit = domain.DefaultPageLimit
+	}
+	validateErr := validateAuditQuery(query)
+	if validateErr != nil {
+		return domain.AuditPage{}, validateErr
+	}
+	return productService.auditRepository.GetAuditRecords(ctx, query)
+}
+
+// PriceHistory returns every recorded price of a product, or one summary per day or week when a bucket is given.
+// An unknown or deleted product is not found rather than an empty series.
+func (productService *ProductService) PriceHistory(ctx context.Context, query domain.PriceHistoryQuery) (domain.PriceHistory, error) {
+	validateErr := validatePriceHistoryQuery(query)
+	if validateErr != nil {
+		return domain.PriceHistory{}, validateErr
+	}
+	if _, getErr := productService.productRepository.GetProductById(ctx, query.ProductId); getErr != nil {
+		return domain.PriceHistory{}, getErr
+	}
+
+	priceHistory := domain.PriceHistory{ProductId: query.ProductId, Bucket: query.Bucket}
+	var err error
+	if len(query.Bucket) == 0 {
+		priceHistory.Points, err = productService.priceHistoryRepository.GetPricePoints(ctx, query)
+	} else {
+		priceHistory.Buckets, err = productService.priceHistoryRepository.GetPriceBuckets(ctx, query)
+	}
+	if err != nil {
+		return domain.PriceHistory{}, err
+	}
+	return priceHistory, nil
+}
+
+// Import validates every row with the rules of Add before writing anything. All or nothing writes no row once one
+// is rejected, best effort writes the valid rows and commits them batch by batch. When the database goes away or
+// ctx ends halfway through a best effort import, the report of the batches already committed comes with the error.
+func (productService *ProductService) Import(ctx context.Context, rows []model.ImportRow, mode string) (domain.ImportReport, error) {
+	validator := &validator{}
+	validator.check(domain.IsImportMode(mode), "mode", "enum",
+		fmt.Sprintf("Unknown mode %s, allowed modes are %s", mode, strings.Join(domain.ImportModes, ",")))
+	validator.check(len(rows) > 0, "rows", "required", "Import has no rows")
+	validator.check(len(rows) <= domain.MaxImportRows, "rows", "max",
+		fmt.Sprintf("Import can not have more than %d rows", domain.MaxImportRows))
+	if validateErr := validator.err(); validateErr != nil {
+		return domain.ImportReport{}, validateErr
+	}
+
+	report := domain.ImportReport{Mode: mode, Rows: make([]domain.ImportRowResult, len(rows))}
+	var products []domain.Product
+	var rowIndexes []int
+	for index, row := range rows {
+		rowErrors := row.Errors
+		if len(rowErrors) == 0 {
+			productCreate := row.Product
+			if len(productCreate.Currency) == 0 {
+				productCreate.Currency = domain.DefaultCurrency
+			}
+			if validateErr := validateProductCreate(productCreate); validateErr != nil {
+				rowErrors = domain.FieldErrorsOf(validateErr)
+			} else {
+				products = append(products, domain.Product{
+					Name:     productCreate.Name,
+					Price:    productCreate.Price,
+					Discount: productCreate.Discount,
+					Currency: productCreate.Currency,
+					Store:    productCreate.Store,
+				})
+				rowIndexes = append(rowIndexes, index)
+			}
+		}
+		report.Rows[index] = domain.ImportRowResult{Line: row.Line, Status: domain.ImportSkipped, Errors: rowErrors}
+		if len(rowErrors) > 0 {
+			report.Rows[index].Status = domain.ImportRejected
+			report.Rejected++
+		}
+	}
+	if mode == domain.ImportAllOrNothing && report.Rejected > 0 {
+		return report, nil
+	}
+
+	var batches [][2]int
+	for start := 0; start < len(products); start += domain.ImportBatchSize {
+		batches = append(batches, [2]int{start, min(start+domain.ImportBatchSize, len(products))})
+	}
+	if mode == domain.ImportAllOrNothing {
+		txErr := productService.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			for _, batch := range batches {
+				if importErr := productService.importBatch(ctx, &report, products[batch[0]:batch[1]], rowIndexes[batch[0]:batch[1]]); importErr != nil {
+					return importErr
+				}
+			}
+			return nil
+		})
+		if txErr != nil {
+			return domain.ImportReport{}, txErr
+		}
+	} else {
+		for _, batch := range batches {
+			acceptedBefore := report.Accepted
+			txErr := productService.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+				return productService.importBatch(ctx, &report, products[batch[0]:batch[1]], rowIndexes[batch[0]:batch[1]])
+			})
+			if txErr == nil {
+				continue
+			}
+			// The batch was rolled back, so rows it had marked as accepted are not
+			report.Accepted = acceptedBefore
+			if ctx.Err() != nil || errors.Is(txErr, domain.ErrUnavailable) {
+				for _, rowIndex := range rowIndexes[batch[0]:] {
+					report.Rows[rowIndex].Status, report.Rows[rowIndex].ProductId = domain.ImportNotAttempted, 0
+				}
+				report.Committed = report.Accepted > 0
+				productService.logger.WarnContext(ctx, "Product import aborted", "mode", mode, "accepted", report.Accepted, "error", txErr)
+				return report, txErr
+			}
+			for _, rowIndex := range rowIndexes[batch[0]:batch[1]] {
+				report.Rows[rowIndex] = domain.ImportRowResult{Line: report.Rows[rowIndex].Line, Status: domain.ImportRejected,
+					Errors: []domain.FieldError{{Field: "row", Code: "write", Message: txErr.Error()}}}
+				report.Rejected++
+			}
+		}
+	}
+	report.Committed = report.Accepted > 0
+
+	productService.logger.InfoContext(ctx, "Products imported", "mode", mode, "accepted", report.Accepted, "rejected", report.Rejected)
+	return report, nil
+}
+
+// *importBatch writes validated products together with their audit records and the start of their price history
+func (productService *ProductService) importBatch(ctx context.Context, report *domain.ImportReport, products []domain.Product, rowIndexes []int) error {
+	stores := map[string]domain.Store{}
+	for index := range products {
+		storeName := domain.NormalizeStoreName(products[index].Store)
+		store, known := stores[strings.ToLower(storeName)]
+		if !known {
+			var storeErr error
+			if store, storeErr = productService.storeRepository.EnsureStore(ctx, storeName); storeErr != nil {
+				return storeErr
+			}
+			stores[strings.ToLower(storeName)] = store
+		}
+		products[index].StoreId, products[index].Store = store.Id, store.Name
+	}
+	addedProducts, addErr := productService.productRepository.AddProducts(ctx, products)
+	if addErr != nil {
+		return addErr
+	}
+	if recordErr := productService.priceHistoryRepository.AddPricePoints(ctx, addedProducts); recordErr != nil {
+		return recordErr
+	}
+	auditRecords := make([]domain.AuditRecord, 0, len(addedProducts))
+	for index := range addedProducts {
+		auditRecords = append(auditRecords, newAuditRecord(ctx, domain.AuditCreate, nil, &addedProducts[index]))
+	}
+	if auditErr := productService.auditRepository.AddAuditRecords(ctx, auditRecords); auditErr != nil {
+		return auditErr
+	}
+
+	for index, addedProduct := range addedProducts {
+		report.Rows[rowIndexes[index]].Status = domain.ImportAccepted
+		report.Rows[rowIndexes[index]].ProductId = addedProduct.Id
+	}
+	report.Accepted += len(addedProducts)
+	return nil
+}
+
+// *replace writes an already validated productUpdate over currentProduct, which must have been read in the same
+// transaction, and records the change
+func (productService *ProductService) replace(ctx context.Context, currentProduct domain.Product, productUpdate model.ProductCreate) (domain.Product, error) {
+	store, storeErr := productService.storeRepository.EnsureStore(ctx, domain.NormalizeStoreName(productUpdate.Store))
+	if storeErr != nil {
+		return domain.Product{}, storeErr
+	}
+	updatedProduct, updateErr := productService.productRepository.UpdateProduct(ctx, domain.Product{
+		Id:       currentProduct.Id,
+		Name:     productUpdate.Name,
+		Price:    productUpdate.Price,
+		Discount: productUpdate.Discount,
+		Currency: productUpdate.Currency,
+		StoreId:  store.Id,
+		Store:    store.Name,
+		Version:  currentProduct.Version,
+	})
+	if updateErr != nil {
+		return domain.Product{}, updateErr
+	}
+	if recordErr := productService.recordPrice(ctx, &currentProduct, updatedProduct); recordErr != nil {
+		return domain.Product{}, recordErr
+	}
+	return updatedProduct, productService.audit(ctx, domain.AuditUpdate, &currentProduct, &updatedProduct)
+}
+
+// *recordPrice adds a point to the price history when the price, discount or currency changed
+func (productService *ProductService) recordPrice(ctx context.Context, before *domain.Product, after domain.Product) error {
+	if !domain.PriceChanged(before, after) {
+		return nil
+	}
+	return productService.priceHistoryRepository.AddPricePoint(ctx, after)
+}
+
+// *audit records who made the change and in which request, ctx must carry the transaction of the change
+func (productService *ProductService) audit(ctx context.Context, operation string, before *domain.Product, after *domain.Product) error {
+	_, err := productService.auditRepository.AddAuditRecord(ctx, newAuditRecord(ctx, operation, before, after))
+	return err
+}
+
+// ?newAuditRecord
+func newAuditRecord(ctx context.Context, operation string, before *domain.Product, after *domain.Product) domain.AuditRecord {
+	auditRecord := domain.NewAuditRecord(operation, before, after)
+	auditRecord.Actor = logging.Actor(ctx)
+	if len(auditRecord.Actor) == 0 {
+		auditRecord.Actor = SystemActor
+	}
+	auditRecord.RequestId = logging.RequestId(ctx)
+	return auditRecord
 }
 
 // !AllProducts
-func (productService *ProductService) AllProducts() []domain.Product {
-	return productService.productRepository.GetAllProducts()
+func (productService *ProductService) AllProducts(ctx context.Context) ([]domain.Product, error) {
+	return productService.productRepository.GetAllProducts(ctx)
 }
 
 // !ProductsByStore
-func (productService *ProductService) ProductsByStore(storeName string) []domain.Product {
-	return productService.productRepository.GetAllProductsByStore(storeName)
+func (productService *ProductService) ProductsByStore(ctx context.Context, storeName string) ([]domain.Product, error) {
+	return productService.productRepository.GetAllProductsByStore(ctx, storeName)
+}
+
+// Export hands every product matching the filters of query to write, in its sort order, without loading them all.
+// Paging fields are ignored, an error from write stops the export.
+func (productService *ProductService) Export(ctx context.Context, query domain.ProductQuery, write func(product domain.Product) error) error {
+	validator := &validator{}
+	validator.productFilters(query)
+	if validateErr := validator.err(); validateErr != nil {
+		return validateErr
+	}
+	query.Limit, query.Offset, query.AfterId = 0, 0, 0
+	return productService.productRepository.StreamProducts(ctx, query, write)
+}
+
+// !ProductsPage
+func (productService *ProductService) ProductsPage(ctx context.Context, query domain.ProductQuery) (domain.ProductPage, error) {
+	if query.Limit == 0 {
+		query.Limit = domain.DefaultPageLimit
+	}
+	validateErr := validateProductQuery(query)
+	if validateErr != nil {
+		return domain.ProductPage{}, validateErr
+	}
+	return productService.productRepository.GetProducts(ctx, query)
+}
+
+// *checkVersion fails early when the caller already holds a stale copy, the repository checks again atomically
+func checkVersion(product domain.Product, expectedVersion int64) error {
+	if expectedVersion == domain.AnyVersion || expectedVersion == product.Version {
+		return nil
+	}
+	return domain.NewVersionMismatchError(fmt.Sprintf("Product %d is at version %d, not %d", product.Id, product.Version, expectedVersion))
 }
 
 // *validateProductCreate
 func validateProductCreate(productCreate model.ProductCreate) error {
-	if productCreate.Discount > 70.0 {
-		return errors.New("Discount can not be greater than 70")
+	validator := &validator{}
+	validator.requiredText(productCreate.Name, "name", "Name")
+	validator.requiredText(productCreate.Store, "store", "Store")
+	validator.check(!productCreate.Price.IsNegative(), "price", "min", "Price can not be negative")
+	validator.currencyAmount(productCreate.Price, productCreate.Currency, "price", "Price")
+	validator.check(!productCreate.Discount.IsNegative(), "discount", "min", "Discount can not be negative")
+	validator.check(productCreate.Discount.Cmp(maxDiscount) <= 0, "discount", "max", "Discount can not be greater than 70")
+	return validator.err()
+}
+
+// *validatePrice
+func validatePrice(price domain.Decimal, currency string) error {
+	validator := &validator{}
+	validator.check(!price.IsNegative(), "newPrice", "min", "Price can not be negative")
+	validator.currencyAmount(price, currency, "newPrice", "Price")
+	return validator.err()
+}
+
+// *validateProductQuery
+func validateProductQuery(query domain.ProductQuery) error {
+	validator := &validator{}
+	validator.check(query.Limit >= 1 && query.Limit <= domain.MaxPageLimit, "limit", "range",
+		fmt.Sprintf("Limit must be between 1 and %d", domain.MaxPageLimit))
+	validator.check(query.Offset >= 0, "offset", "min", "Offset can not be negative")
+	validator.check(query.AfterId >= 0, "after_id", "min", "After_id can not be negative")
+	validator.check(query.AfterId == 0 || len(query.Sort) == 0, "after_id", "conflict",
+		"After_id can only be used with the default sort, use offset instead")
+	validator.productFilters(query)
+	return validator.err()
+}
+
+// ?productFilters checks the filters and sort shared by listings and exports
+func (validator *validator) productFilters(query domain.ProductQuery) {
+	for _, priceFilter := range []struct {
+		field string
+		value *domain.Decimal
+	}{{"minPrice", query.MinPrice}, {"maxPrice", query.MaxPrice}, {"minDiscount", query.MinDiscount}} {
+		validator.check(priceFilter.value == nil || !priceFilter.value.IsNegative(), priceFilter.field, "min",
+			fmt.Sprintf("%s can not be negative", priceFilter.field))
 	}
-	return nil
+	validator.check(query.MinPrice == nil || query.MaxPrice == nil || query.MinPrice.Cmp(*query.MaxPrice) <= 0, "minPrice", "range",
+		"minPrice can not be greater than maxPrice")
+
+	sortedFields := map[string]bool{}
+	for _, sortField := range query.Sort {
+		sortable := domain.IsSortableProductField(sortField.Field)
+		validator.check(sortable, "sort", "enum",
+			fmt.Sprintf("Can not sort by %s, allowed fields are %s", sortField.Field, strings.Join(domain.SortableProductFields, ",")))
+		validator.check(!sortable || !sortedFields[sortField.Field], "sort", "duplicate",
+			fmt.Sprintf("Field %s is sorted more than once", sortField.Field))
+		sortedFields[sortField.Field] = true
+	}
+}
+
+// *validateAuditQuery
+func validateAuditQuery(query domain.AuditQuery) error {
+	validator := &validator{}
+	validator.check(query.Limit >= 1 && query.Limit <= domain.MaxPageLimit, "limit", "range",
+		fmt.Sprintf("Limit must be between 1 and %d", domain.MaxPageLimit))
+	validator.check(query.ProductId >= 0, "productId", "min", "ProductId can not be negative")
+	validator.check(query.BeforeId >= 0, "before_id", "min", "Before_id can not be negative")
+	validator.check(len(query.Operation) == 0 || domain.IsAuditOperation(query.Operation), "operation", "enum",
+		fmt.Sprintf("Unknown operation %s, allowed operations are %s", query.Operation, strings.Join(domain.AuditOperations, ",")))
+	validator.check(query.From == nil || query.To == nil || !query.From.After(*query.To), "from", "range",
+		"From can not be after to")
+	return validator.err()
+}
+
+// *validatePriceHistoryQuery
+func validatePriceHistoryQuery(query domain.PriceHistoryQuery) error {
+	validator := &validator{}
+	validator.check(len(query.Bucket) == 0 || domain.IsPriceBucket(query.Bucket), "bucket", "enum",
+		fmt.Sprintf("Unknown bucket %s, allowed buckets are %s", query.Bucket, strings.Join(domain.PriceBuckets, ",")))
+	validator.check(query.From == nil || query.To == nil || !query.From.After(*query.To), "from", "range",
+		"From can not be after to")
+	return validator.err()
 }
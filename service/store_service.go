@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"product-app/domain"
+	"product-app/persistence"
+)
+
+type IStoreService interface {
+	AllStores(ctx context.Context) ([]domain.Store, error)
+	StoreById(ctx context.Context, storeId int64) (domain.Store, error)
+	Add(ctx context.Context, name string) (domain.Store, error)
+	Rename(ctx context.Context, storeId int64, name string) (domain.Store, error)
+	DeleteById(ctx context.Context, storeId int64) error
+	Products(ctx context.Context, storeId int64, query domain.ProductQuery) (domain.ProductPage, error)
+}
+
+type StoreService struct {
+	storeRepository   persistence.IStoreRepository
+	productRepository persistence.IProductRepository
+	auditRepository   persistence.IAuditRepository
+	transactor        persistence.ITransactor
+	productService    IProductService
+	logger            *slog.Logger
+}
+
+// NewStoreService lists the products of a store through productService, so they are paged and filtered like
+// every other listing
+func NewStoreService(storeRepository persistence.IStoreRepository, productRepository persistence.IProductRepository,
+	auditRepository persistence.IAuditRepository, transactor persistence.ITransactor, productService IProductService, logger *slog.Logger) IStoreService {
+	return &StoreService{
+		storeRepository:   storeRepository,
+		productRepository: productRepository,
+		auditRepository:   auditRepository,
+		transactor:        transactor,
+		productService:    productService,
+		logger:            logger,
+	}
+}
+
+// !AllStores
+func (storeService *StoreService) AllStores(ctx context.Context) ([]domain.Store, error) {
+	return storeService.storeRepository.GetAllStores(ctx)
+}
+
+// !StoreById
+func (storeService *StoreService) StoreById(ctx context.Context, storeId int64) (domain.Store, error) {
+	return storeService.storeRepository.GetStoreById(ctx, storeId)
+}
+
+// Add stores the normalized name, a name that only differs in case from an existing store is a conflict
+func (storeService *StoreService) Add(ctx context.Context, name string) (domain.Store, error) {
+	name = domain.NormalizeStoreName(name)
+	if validateErr := validateStoreName(name); validateErr != nil {
+		return domain.Store{}, validateErr
+	}
+	addedStore, err := storeService.storeRepository.AddStore(ctx, name)
+	if err != nil {
+		return domain.Store{}, err
+	}
+	storeService.logger.InfoContext(ctx, "Store added", "store_id", addedStore.Id, "store", addedStore.Name)
+	return addedStore, nil
+}
+
+// Rename also changes the store every product of it is listed under. Those products move to a new version and
+// the change is audited for each of them in the same transaction, as for any other change of a product.
+func (storeService *StoreService) Rename(ctx context.Context, storeId int64, name string) (domain.Store, error) {
+	name = domain.NormalizeStoreName(name)
+	if validateErr := validateStoreName(name); validateErr != nil {
+		return domain.Store{}, validateErr
+	}
+	var renamedStore domain.Store
+	var renamedProducts int
+	txErr := storeService.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		currentStore, getErr := storeService.storeRepository.GetStoreById(ctx, storeId)
+		if getErr != nil {
+			return getErr
+		}
+		var renameErr error
+		renamedStore, renameErr = storeService.storeRepository.RenameStore(ctx, storeId, name)
+		if renameErr != nil || renamedStore.Name == currentStore.Name {
+			return renameErr
+		}
+
+		bumpedProducts, bumpErr := storeService.productRepository.BumpProductsOfStore(ctx, renamedStore)
+		if bumpErr != nil || len(bumpedProducts) == 0 {
+			return bumpErr
+		}
+		auditRecords := make([]domain.AuditRecord, 0, len(bumpedProducts))
+		for index := range bumpedProducts {
+			previousProduct := bumpedProducts[index]
+			previousProduct.Store, previousProduct.Version = currentStore.Name, previousProduct.Version-1
+			auditRecords = append(auditRecords, newAuditRecord(ctx, domain.AuditUpdate, &previousProduct, &bumpedProducts[index]))
+		}
+		renamedProducts = len(bumpedProducts)
+		return storeService.auditRepository.AddAuditRecords(ctx, auditRecords)
+	})
+	if txErr != nil {
+		return domain.Store{}, txErr
+	}
+	storeService.logger.InfoContext(ctx, "Store renamed", "store_id", storeId, "store", renamedStore.Name, "products", renamedProducts)
+	return renamedStore, nil
+}
+
+// DeleteById only removes stores without products, products in the trash count until they are purged
+func (storeService *StoreService) DeleteById(ctx context.Context, storeId int64) error {
+	if err := storeService.storeRepository.DeleteStoreById(ctx, storeId); err != nil {
+		return err
+	}
+	storeService.logger.InfoContext(ctx, "Store deleted", "store_id", storeId)
+	return nil
+}
+
+// Products pages through the products of the store, an unknown store is not found rather than an empty page
+func (storeService *StoreService) Products(ctx context.Context, storeId int64, query domain.ProductQuery) (domain.ProductPage, error) {
+	if _, getErr := storeService.storeRepository.GetStoreById(ctx, storeId); getErr != nil {
+		return domain.ProductPage{}, getErr
+	}
+	query.StoreId = storeId
+	return storeService.productService.ProductsPage(ctx, query)
+}
+
+// *validateStoreName
+func validateStoreName(name string) error {
+	validator := &validator{}
+	validator.requiredText(name, "name", "Name")
+	return validator.err()
+}
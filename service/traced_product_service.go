@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"product-app/common/tracing"
+	"product-app/domain"
+	"product-app/service/model"
+	"time"
+)
+
+// TracedProductService wraps every service call in a span so slow requests show where the time went
+type TracedProductService struct {
+	productService IProductService
+	tracer         *tracing.Tracer
+}
+
+func NewTracedProductService(productService IProductService, tracer *tracing.Tracer) IProductService {
+	return &TracedProductService{
+		productService: productService,
+		tracer:         tracer,
+	}
+}
+
+// !AllProducts
+func (traced *TracedProductService) AllProducts(ctx context.Context) ([]domain.Product, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.AllProducts", tracing.SpanKindInternal)
+	defer span.End()
+	products, err := traced.productService.AllProducts(ctx)
+	span.RecordError(err)
+	return products, err
+}
+
+// !ProductsByStore
+func (traced *TracedProductService) ProductsByStore(ctx context.Context, storeName string) ([]domain.Product, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.ProductsByStore", tracing.SpanKindInternal, tracing.String("product.store", storeName))
+	defer span.End()
+	products, err := traced.productService.ProductsByStore(ctx, storeName)
+	span.RecordError(err)
+	return products, err
+}
+
+// !ProductsPage
+func (traced *TracedProductService) ProductsPage(ctx context.Context, query domain.ProductQuery) (domain.ProductPage, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.ProductsPage", tracing.SpanKindInternal,
+		tracing.Int("page.limit", query.Limit), tracing.Int("page.offset", query.Offset))
+	defer span.End()
+	productPage, err := traced.productService.ProductsPage(ctx, query)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Int("page.items", len(productPage.Items)))
+	return productPage, err
+}
+
+// !Add
+func (traced *TracedProductService) Add(ctx context.Context, productCreate model.ProductCreate) (domain.Product, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.Add", tracing.SpanKindInternal)
+	defer span.End()
+	addedProduct, err := traced.productService.Add(ctx, productCreate)
+	span.RecordError(err)
+	if err == nil {
+		span.SetAttributes(tracing.Int64("product.id", addedProduct.Id))
+	}
+	return addedProduct, err
+}
+
+// !ProductById
+func (traced *TracedProductService) ProductById(ctx context.Context, productId int64) (domain.Product, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.ProductById", tracing.SpanKindInternal, tracing.Int64("product.id", productId))
+	defer span.End()
+	product, err := traced.productService.ProductById(ctx, productId)
+	span.RecordError(err)
+	return product, err
+}
+
+// !DeleteById
+func (traced *TracedProductService) DeleteById(ctx context.Context, productId int64, expectedVersion int64) error {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.DeleteById", tracing.SpanKindInternal, tracing.Int64("product.id", productId))
+	defer span.End()
+	err := traced.productService.DeleteById(ctx, productId, expectedVersion)
+	span.RecordError(err)
+	return err
+}
+
+// !UpdateProductPrice
+func (traced *TracedProductService) UpdateProductPrice(ctx context.Context, productId int64, newPrice domain.Decimal, expectedVersion int64) error {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.UpdateProductPrice", tracing.SpanKindInternal, tracing.Int64("product.id", productId))
+	defer span.End()
+	err := traced.productService.UpdateProductPrice(ctx, productId, newPrice, expectedVersion)
+	span.RecordError(err)
+	return err
+}
+
+// !Update
+func (traced *TracedProductService) Update(ctx context.Context, productId int64, productUpdate model.ProductCreate, expectedVersion int64) (domain.Product, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.Update", tracing.SpanKindInternal, tracing.Int64("product.id", productId))
+	defer span.End()
+	updatedProduct, err := traced.productService.Update(ctx, productId, productUpdate, expectedVersion)
+	span.RecordError(err)
+	return updatedProduct, err
+}
+
+// !Patch
+func (traced *TracedProductService) Patch(ctx context.Context, productId int64, productPatch model.ProductPatch, expectedVersion int64) (domain.Product, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.Patch", tracing.SpanKindInternal, tracing.Int64("product.id", productId))
+	defer span.End()
+	patchedProduct, err := traced.productService.Patch(ctx, productId, productPatch, expectedVersion)
+	span.RecordError(err)
+	return patchedProduct, err
+}
+
+// !DeletedProducts
+func (traced *TracedProductService) DeletedProducts(ctx context.Context) ([]domain.Product, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.DeletedProducts", tracing.SpanKindInternal)
+	defer span.End()
+	products, err := traced.productService.DeletedProducts(ctx)
+	span.RecordError(err)
+	return products, err
+}
+
+// !Restore
+func (traced *TracedProductService) Restore(ctx context.Context, productId int64, expectedVersion int64) (domain.Product, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.Restore", tracing.SpanKindInternal, tracing.Int64("product.id", productId))
+	defer span.End()
+	restoredProduct, err := traced.productService.Restore(ctx, productId, expectedVersion)
+	span.RecordError(err)
+	return restoredProduct, err
+}
+
+// !PurgeDeleted
+func (traced *TracedProductService) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.PurgeDeleted", tracing.SpanKindInternal, tracing.String("trash.retention", retention.String()))
+	defer span.End()
+	purged, err := traced.productService.PurgeDeleted(ctx, retention)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Int64("trash.purged", purged))
+	return purged, err
+}
+
+// !AuditRecords
+func (traced *TracedProductService) AuditRecords(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.AuditRecords", tracing.SpanKindInternal, tracing.Int64("product.id", query.ProductId))
+	defer span.End()
+	auditPage, err := traced.productService.AuditRecords(ctx, query)
+	span.RecordError(err)
+	return auditPage, err
+}
+
+// !PriceHistory
+func (traced *TracedProductService) PriceHistory(ctx context.Context, query domain.PriceHistoryQuery) (domain.PriceHistory, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.PriceHistory", tracing.SpanKindInternal,
+		tracing.Int64("product.id", query.ProductId), tracing.String("price.bucket", query.Bucket))
+	defer span.End()
+	priceHistory, err := traced.productService.PriceHistory(ctx, query)
+	span.RecordError(err)
+	return priceHistory, err
+}
+
+// !Export
+func (traced *TracedProductService) Export(ctx context.Context, query domain.ProductQuery, write func(product domain.Product) error) error {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.Export", tracing.SpanKindInternal)
+	defer span.End()
+	var exported int64
+	err := traced.productService.Export(ctx, query, func(product domain.Product) error {
+		exported++
+		return write(product)
+	})
+	span.RecordError(err)
+	span.SetAttributes(tracing.Int64("export.rows", exported))
+	return err
+}
+
+// !Import
+func (traced *TracedProductService) Import(ctx context.Context, rows []model.ImportRow, mode string) (domain.ImportReport, error) {
+	ctx, span := traced.tracer.Start(ctx, "ProductService.Import", tracing.SpanKindInternal,
+		tracing.String("import.mode", mode), tracing.Int64("import.rows", int64(len(rows))))
+	defer span.End()
+	report, err := traced.productService.Import(ctx, rows, mode)
+	span.RecordError(err)
+	span.SetAttributes(tracing.Int64("import.accepted", int64(report.Accepted)), tracing.Int64("import.rejected", int64(report.Rejected)))
+	return report, err
+}
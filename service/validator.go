@@ -0,0 +1,57 @@
+package service
+
+import (
+	"fmt"
+	"product-app/domain"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxNameLength = 255
+
+var maxDiscount = domain.NewDecimal(70)
+
+// *validator collects every field violation so callers can report them all at once
+type validator struct {
+	fieldErrors []domain.FieldError
+}
+
+func (validator *validator) check(valid bool, field string, code string, message string) {
+	if !valid {
+		validator.fieldErrors = append(validator.fieldErrors, domain.FieldError{
+			Field:   field,
+			Code:    code,
+			Message: message,
+		})
+	}
+}
+
+// ?requiredText trims the value before checking its presence and length
+func (validator *validator) requiredText(value string, field string, label string) {
+	trimmed := strings.TrimSpace(value)
+	validator.check(len(trimmed) > 0, field, "required", fmt.Sprintf("%s can not be empty", label))
+	validator.check(utf8.RuneCountInString(trimmed) <= maxNameLength, field, "max_length",
+		fmt.Sprintf("%s can not be longer than %d characters", label, maxNameLength))
+}
+
+// ?currencyAmount checks the code is a supported ISO 4217 currency and the amount fits its minor unit
+func (validator *validator) currencyAmount(amount domain.Decimal, currency string, field string, label string) {
+	minorUnits, supported := domain.CurrencyMinorUnits(currency)
+	validator.check(supported, "currency", "enum", fmt.Sprintf("Currency %s is not a supported ISO 4217 code", currency))
+	if supported {
+		validator.check(amount.HasAtMostPlaces(minorUnits), field, "precision",
+			fmt.Sprintf("%s can not have more than %d fractional digits in %s", label, minorUnits, currency))
+	}
+}
+
+// ?err joins the messages so a single violation keeps its own message
+func (validator *validator) err() error {
+	if len(validator.fieldErrors) == 0 {
+		return nil
+	}
+	var messages []string
+	for _, fieldError := range validator.fieldErrors {
+		messages = append(messages, fieldError.Message)
+	}
+	return domain.NewValidationError(strings.Join(messages, "; "), validator.fieldErrors...)
+}
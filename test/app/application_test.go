@@ -0,0 +1,147 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"product-app/common/app"
+	"product-app/common/logging"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// *newTestApplication serves a single slow endpoint on a random local port
+func newTestApplication(shutdownTimeout time.Duration, requestStarted chan struct{}, release chan struct{}) *app.Application {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.GET("/slow", func(c echo.Context) error {
+		close(requestStarted)
+		<-release
+		return c.String(http.StatusOK, "done")
+	})
+	return app.NewApplication(app.ServerConfig{
+		Address:         "127.0.0.1:0",
+		ShutdownTimeout: shutdownTimeout,
+	}, e, logging.Discard())
+}
+
+func Test_ShouldDrainInFlightRequestsOnShutdown(t *testing.T) {
+	t.Run("ShouldDrainInFlightRequestsOnShutdown", func(t *testing.T) {
+		requestStarted, release := make(chan struct{}), make(chan struct{})
+		application := newTestApplication(5*time.Second, requestStarted, release)
+		cleanedUp := false
+		application.OnShutdown(func() { cleanedUp = true })
+		draining := make(chan struct{})
+		application.OnDraining(func() { close(draining) })
+		assert.Nil(t, application.Listen())
+
+		ctx, stop := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- application.Serve(ctx) }()
+
+		responseBody := make(chan string, 1)
+		go func() {
+			response, err := http.Get("http://" + application.Addr().String() + "/slow")
+			if err != nil {
+				responseBody <- err.Error()
+				return
+			}
+			defer response.Body.Close()
+			body, _ := io.ReadAll(response.Body)
+			responseBody <- string(body)
+		}()
+
+		<-requestStarted
+		stop()
+		<-draining
+		assert.Eventually(t, func() bool {
+			_, err := net.Dial("tcp", application.Addr().String())
+			return err != nil
+		}, time.Second, 10*time.Millisecond, "listener should stop accepting connections")
+		assert.False(t, cleanedUp)
+
+		close(release)
+		assert.Equal(t, "done", <-responseBody)
+		assert.Nil(t, <-served)
+		assert.True(t, cleanedUp)
+	})
+}
+
+func Test_ShouldKeepServingDuringDrainDelay(t *testing.T) {
+	t.Run("ShouldKeepServingDuringDrainDelay", func(t *testing.T) {
+		e := echo.New()
+		e.HideBanner = true
+		e.HidePort = true
+		e.GET("/ready", func(c echo.Context) error {
+			return c.String(http.StatusOK, "ready")
+		})
+		application := app.NewApplication(app.ServerConfig{
+			Address:         "127.0.0.1:0",
+			ShutdownTimeout: time.Second,
+			DrainDelay:      300 * time.Millisecond,
+		}, e, logging.Discard())
+		draining := make(chan time.Time, 1)
+		application.OnDraining(func() { draining <- time.Now() })
+		assert.Nil(t, application.Listen())
+
+		ctx, stop := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- application.Serve(ctx) }()
+
+		stop()
+		drainingAt := <-draining
+		response, err := http.Get("http://" + application.Addr().String() + "/ready")
+		assert.Nil(t, err)
+		if err == nil {
+			response.Body.Close()
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		assert.Nil(t, <-served)
+		assert.GreaterOrEqual(t, time.Since(drainingAt), 300*time.Millisecond)
+		_, dialErr := net.Dial("tcp", application.Addr().String())
+		assert.NotNil(t, dialErr)
+	})
+}
+
+func Test_WhenRequestsDoNotDrainInTime_ShouldReturnError(t *testing.T) {
+	t.Run("WhenRequestsDoNotDrainInTime_ShouldReturnError", func(t *testing.T) {
+		requestStarted, release := make(chan struct{}), make(chan struct{})
+		defer close(release)
+		application := newTestApplication(50*time.Millisecond, requestStarted, release)
+		cleanedUp := false
+		application.OnShutdown(func() { cleanedUp = true })
+		assert.Nil(t, application.Listen())
+
+		ctx, stop := context.WithCancel(context.Background())
+		served := make(chan error, 1)
+		go func() { served <- application.Serve(ctx) }()
+		go http.Get("http://" + application.Addr().String() + "/slow")
+
+		<-requestStarted
+		stop()
+		err := <-served
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.True(t, cleanedUp)
+	})
+}
+
+func Test_WhenAddressIsTaken_ShouldFailToStart(t *testing.T) {
+	t.Run("WhenAddressIsTaken_ShouldFailToStart", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		defer listener.Close()
+
+		application := app.NewApplication(app.ServerConfig{Address: listener.Addr().String(), ShutdownTimeout: time.Second}, echo.New(), logging.Discard())
+		cleanedUp := false
+		application.OnShutdown(func() { cleanedUp = true })
+		err = application.Run(context.Background())
+		assert.ErrorContains(t, err, "Unable to listen on")
+		assert.True(t, cleanedUp)
+	})
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"product-app/common/app"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// *env builds a lookup function over a fixed set of environment variables
+func env(values map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, found := values[name]
+		return value, found
+	}
+}
+
+// *writeFile creates a file in the test's temporary directory
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func Test_ShouldUseDefaultsWhenNothingIsConfigured(t *testing.T) {
+	t.Run("ShouldUseDefaultsWhenNothingIsConfigured", func(t *testing.T) {
+		configurationManager, args, err := app.LoadConfiguration(nil, env(nil))
+		assert.Nil(t, err)
+		assert.Empty(t, args)
+		assert.Equal(t, "localhost:8080", configurationManager.ServerConfig.Address)
+		assert.Equal(t, 5*time.Second, configurationManager.ServerConfig.DrainDelay)
+		assert.Equal(t, 6432, configurationManager.PostgreSqlConfig.Port)
+		assert.Equal(t, 10, configurationManager.PostgreSqlConfig.MaxConnections)
+		assert.Equal(t, 30*time.Second, configurationManager.PostgreSqlConfig.MaxConnectionIdleTime)
+		assert.Empty(t, configurationManager.PostgreSqlConfig.Password)
+	})
+}
+
+func Test_ShouldLayerFileEnvironmentAndFlags(t *testing.T) {
+	t.Run("ShouldLayerFileEnvironmentAndFlags", func(t *testing.T) {
+		configFile := writeFile(t, "config.yaml", `
+server:
+  address: ":9090"
+  writeTimeout: 5s
+postgres:
+  host: db.internal
+  port: 5432
+  maxConnections: 20
+`)
+		configurationManager, args, err := app.LoadConfiguration(
+			[]string{"--config", configFile, "--db-max-connections", "30", "migrate", "status"},
+			env(map[string]string{
+				"PRODUCT_APP_DB_HOST":            "db.env",
+				"PRODUCT_APP_DB_MAX_CONNECTIONS": "25",
+				"PRODUCT_APP_DB_SSLMODE":         "require",
+			}))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"migrate", "status"}, args)
+		assert.Equal(t, ":9090", configurationManager.ServerConfig.Address)
+		assert.Equal(t, 5*time.Second, configurationManager.ServerConfig.WriteTimeout)
+		assert.Equal(t, 30*time.Second, configurationManager.ServerConfig.ReadTimeout)
+		assert.Equal(t, "db.env", configurationManager.PostgreSqlConfig.Host)
+		assert.Equal(t, 5432, configurationManager.PostgreSqlConfig.Port)
+		assert.Equal(t, "require", configurationManager.PostgreSqlConfig.SslMode)
+		assert.Equal(t, 30, configurationManager.PostgreSqlConfig.MaxConnections)
+	})
+	t.Run("ShouldReadJsonConfigFileFromEnvironment", func(t *testing.T) {
+		configFile := writeFile(t, "config.json", `{"postgres": {"dbName": "catalog", "maxConnectionIdleTime": "1m"}}`)
+		configurationManager, _, err := app.LoadConfiguration(nil, env(map[string]string{"PRODUCT_APP_CONFIG": configFile}))
+		assert.Nil(t, err)
+		assert.Equal(t, "catalog", configurationManager.PostgreSqlConfig.DbName)
+		assert.Equal(t, time.Minute, configurationManager.PostgreSqlConfig.MaxConnectionIdleTime)
+	})
+	t.Run("WhenConfigFileHasUnknownKey_ShouldFail", func(t *testing.T) {
+		configFile := writeFile(t, "config.yaml", "postgres:\n  hostname: db\n")
+		_, _, err := app.LoadConfiguration([]string{"--config", configFile}, env(nil))
+		assert.ErrorContains(t, err, "hostname")
+	})
+}
+
+func Test_ShouldReadSecretsFromFiles(t *testing.T) {
+	t.Run("ShouldReadPasswordFromEnvironmentFile", func(t *testing.T) {
+		secretFile := writeFile(t, "password", "s3cr3t pass\n")
+		configurationManager, _, err := app.LoadConfiguration(nil, env(map[string]string{"PRODUCT_APP_DB_PASSWORD_FILE": secretFile}))
+		assert.Nil(t, err)
+		assert.Equal(t, "s3cr3t pass", configurationManager.PostgreSqlConfig.Password)
+		assert.Contains(t, configurationManager.PostgreSqlConfig.ConnectionString(), "password='s3cr3t pass'")
+	})
+	t.Run("ShouldReadPasswordFileFromFlag", func(t *testing.T) {
+		secretFile := writeFile(t, "password", "from-file")
+		configurationManager, _, err := app.LoadConfiguration([]string{"--db-password-file", secretFile}, env(nil))
+		assert.Nil(t, err)
+		assert.Equal(t, "from-file", configurationManager.PostgreSqlConfig.Password)
+	})
+	t.Run("WhenValueAndFileAreBothSet_ShouldFail", func(t *testing.T) {
+		_, _, err := app.LoadConfiguration(nil, env(map[string]string{
+			"PRODUCT_APP_DB_PASSWORD":      "inline",
+			"PRODUCT_APP_DB_PASSWORD_FILE": "/run/secrets/password",
+		}))
+		assert.EqualError(t, err, "Only one of PRODUCT_APP_DB_PASSWORD and PRODUCT_APP_DB_PASSWORD_FILE may be set")
+	})
+}
+
+func Test_WhenValuesAreInvalid_ShouldReportAllOfThem(t *testing.T) {
+	t.Run("WhenValuesCanNotBeParsed_ShouldReportAllOfThem", func(t *testing.T) {
+		_, _, err := app.LoadConfiguration([]string{"--db-max-connection-idle-time", "soon"}, env(map[string]string{
+			"PRODUCT_APP_DB_MAX_CONNECTIONS": "ten",
+		}))
+		assert.EqualError(t, err, "PRODUCT_APP_DB_MAX_CONNECTIONS: must be an integer, got \"ten\"\n"+
+			"--db-max-connection-idle-time: must be a duration such as 30s, got \"soon\"")
+	})
+	t.Run("WhenValuesAreOutOfRange_ShouldReportAllOfThem", func(t *testing.T) {
+		_, _, err := app.LoadConfiguration([]string{
+			"--server-address", "8080",
+			"--db-max-connections", "0",
+			"--db-max-connection-idle-time", "0s",
+			"--db-sslmode", "sometimes",
+		}, env(nil))
+		assert.EqualError(t, err, "server address must be host:port, got \"8080\"\n"+
+			"database sslmode must be one of disable, allow, prefer, require, verify-ca, verify-full, got \"sometimes\"\n"+
+			"database max connections must be at least 1, got 0\n"+
+			"database max connection idle time must be positive, got 0s")
+	})
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// echoPathParam matches echo's :name path parameters so they can be compared to OpenAPI's {name}
+var echoPathParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// *newDocumentedServer registers every route main registers, through the same controller.RegisterRoutes
+func newDocumentedServer() *echo.Echo {
+	return newServer()
+}
+
+// *readSpec fetches the document the way a client would
+func readSpec(t *testing.T, e *echo.Echo) map[string]interface{} {
+	recorder := serve(e, http.MethodGet, "/api/v1/openapi.json")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var spec map[string]interface{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &spec))
+	return spec
+}
+
+func Test_ShouldServeOpenApiDocument(t *testing.T) {
+	e := newDocumentedServer()
+	t.Run("ShouldServeOpenApi31", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/openapi.json")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.True(t, strings.HasPrefix(recorder.Header().Get(echo.HeaderContentType), echo.MIMEApplicationJSON))
+		assert.Equal(t, "3.1.0", readSpec(t, e)["openapi"])
+	})
+	t.Run("ShouldServeDocsPage", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/docs")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.True(t, strings.HasPrefix(recorder.Header().Get(echo.HeaderContentType), echo.MIMETextHTML))
+		assert.Contains(t, recorder.Body.String(), `fetch("openapi.json")`)
+	})
+}
+
+func Test_ShouldDescribeEveryRegisteredRoute(t *testing.T) {
+	e := newDocumentedServer()
+	paths := readSpec(t, e)["paths"].(map[string]interface{})
+	t.Run("WhenRouteIsRegistered_ShouldBeInSpec", func(t *testing.T) {
+		for _, route := range e.Routes() {
+			path := echoPathParam.ReplaceAllString(route.Path, "{$1}")
+			pathItem, ok := paths[path].(map[string]interface{})
+			if !assert.True(t, ok, "path %s is missing from the spec", path) {
+				continue
+			}
+			assert.Contains(t, pathItem, strings.ToLower(route.Method), "%s %s is missing from the spec", route.Method, path)
+		}
+	})
+	t.Run("WhenOperationIsInSpec_ShouldBeRegistered", func(t *testing.T) {
+		registered := map[string]bool{}
+		for _, route := range e.Routes() {
+			registered[strings.ToLower(route.Method)+" "+echoPathParam.ReplaceAllString(route.Path, "{$1}")] = true
+		}
+		for path, pathItem := range paths {
+			for method := range pathItem.(map[string]interface{}) {
+				if method == "parameters" {
+					continue
+				}
+				assert.True(t, registered[method+" "+path], "%s %s is documented but not registered", method, path)
+			}
+		}
+	})
+}
+
+func Test_ShouldResolveEveryReference(t *testing.T) {
+	spec := readSpec(t, newDocumentedServer())
+	t.Run("ShouldDescribeRequestAndResponseSchemas", func(t *testing.T) {
+		schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+		for _, name := range []string{"AddProductRequest", "ProductResponse", "ProductPageResponse", "ErrorResponse"} {
+			assert.Contains(t, schemas, name)
+		}
+	})
+	t.Run("WhenReferenceIsUsed_ShouldPointToExistingComponent", func(t *testing.T) {
+		for _, ref := range collectRefs(spec, nil) {
+			var target interface{} = spec
+			for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+				object, ok := target.(map[string]interface{})
+				if !ok {
+					target = nil
+					break
+				}
+				target = object[key]
+			}
+			assert.NotNil(t, target, "%s does not resolve", ref)
+		}
+	})
+}
+
+// ?collectRefs walks the decoded document and returns every $ref value
+func collectRefs(node interface{}, refs []string) []string {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				refs = append(refs, ref)
+				continue
+			}
+			refs = collectRefs(child, refs)
+		}
+	case []interface{}:
+		for _, child := range value {
+			refs = collectRefs(child, refs)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"product-app/common/logging"
+	"product-app/controller"
+	"product-app/controller/response"
+	"product-app/domain"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// *handle runs the central error handler for err and decodes the written ErrorResponse
+func handle(err error) (int, response.ErrorResponse) {
+	e := echo.New()
+	recorder := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/products/1/", nil), recorder)
+
+	controller.NewHTTPErrorHandler(logging.Discard())(err, c)
+
+	var errorResponse response.ErrorResponse
+	json.Unmarshal(recorder.Body.Bytes(), &errorResponse)
+	return recorder.Code, errorResponse
+}
+
+func Test_ShouldMapDomainErrorsToStatusCodes(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"NotFound", domain.NewNotFoundError("Product not found with id 5"), http.StatusNotFound, controller.CodeNotFound},
+		{"Validation", domain.NewValidationError("Discount can not be greater than 70"), http.StatusUnprocessableEntity, controller.CodeValidationFailed},
+		{"Conflict", domain.NewConflictError("Product already exists", nil), http.StatusConflict, controller.CodeConflict},
+		{"Unavailable", domain.NewUnavailableError("Error while getting product with id 1", errors.New("connection refused")), http.StatusServiceUnavailable, controller.CodeUnavailable},
+		{"Canceled", domain.NewCanceledError("Error while getting products", context.Canceled), controller.StatusClientClosedRequest, controller.CodeClientClosed},
+		{"ContextCanceled", fmt.Errorf("list: %w", context.Canceled), controller.StatusClientClosedRequest, controller.CodeClientClosed},
+		{"Wrapped", fmt.Errorf("service: %w", domain.NewNotFoundError("Product not found with id 5")), http.StatusNotFound, controller.CodeNotFound},
+		{"HTTPError", echo.NewHTTPError(http.StatusBadRequest, "Parameter newPrice is required!"), http.StatusBadRequest, controller.CodeBadRequest},
+		{"Unknown", errors.New("boom"), http.StatusInternalServerError, controller.CodeInternalError},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			status, errorResponse := handle(testCase.err)
+			assert.Equal(t, testCase.expectedStatus, status)
+			assert.Equal(t, testCase.expectedCode, errorResponse.Code)
+		})
+	}
+}
+
+func Test_WhenValidationFails_ShouldReturnFieldDetails(t *testing.T) {
+	t.Run("WhenValidationFails_ShouldReturnFieldDetails", func(t *testing.T) {
+		status, errorResponse := handle(domain.NewValidationError("Discount can not be greater than 70", domain.FieldError{
+			Field:   "discount",
+			Code:    "max",
+			Message: "Discount can not be greater than 70",
+		}))
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, []response.FieldErrorResponse{
+			{Field: "discount", Code: "max", Message: "Discount can not be greater than 70"},
+		}, errorResponse.Details)
+	})
+}
+
+func Test_WhenErrorIsUnknown_ShouldNotLeakItsMessage(t *testing.T) {
+	t.Run("WhenErrorIsUnknown_ShouldNotLeakItsMessage", func(t *testing.T) {
+		_, errorResponse := handle(errors.New("pq: password authentication failed"))
+		assert.Equal(t, http.StatusText(http.StatusInternalServerError), errorResponse.ErrorDescription)
+	})
+}
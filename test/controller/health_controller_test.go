@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"product-app/common/health"
+	"product-app/common/logging"
+	"product-app/controller"
+	"product-app/controller/response"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// *newHealthServer
+func newHealthServer(registry *health.Registry) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = controller.NewHTTPErrorHandler(logging.Discard())
+	controller.NewHealthController(registry).RegisterRoutes(e)
+	return e
+}
+
+// *readHealth
+func readHealth(t *testing.T, e *echo.Echo, target string) (int, response.HealthResponse) {
+	recorder := serve(e, http.MethodGet, target)
+	var healthResponse response.HealthResponse
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &healthResponse))
+	return recorder.Code, healthResponse
+}
+
+func Test_ShouldReportLiveness(t *testing.T) {
+	t.Run("ShouldReportLivenessEvenWhenDependenciesAreDown", func(t *testing.T) {
+		registry := health.NewRegistry(time.Second)
+		registry.Register("database", func(ctx context.Context) error { return errors.New("connection refused") })
+		recorder := serve(newHealthServer(registry), http.MethodGet, "/healthz")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"status":"up"}`, recorder.Body.String())
+	})
+}
+
+func Test_ShouldReportReadinessPerComponent(t *testing.T) {
+	t.Run("WhenAllChecksPass_ShouldBeReady", func(t *testing.T) {
+		registry := health.NewRegistry(time.Second)
+		registry.Register("database", func(ctx context.Context) error { return nil })
+		registry.Register("migrations", func(ctx context.Context) error { return nil })
+		statusCode, healthResponse := readHealth(t, newHealthServer(registry), "/readyz")
+		assert.Equal(t, http.StatusOK, statusCode)
+		assert.Equal(t, "up", healthResponse.Status)
+		assert.Equal(t, 2, len(healthResponse.Components))
+		assert.Equal(t, "database", healthResponse.Components[0].Name)
+		assert.Equal(t, "migrations", healthResponse.Components[1].Name)
+	})
+	t.Run("WhenACheckFails_ShouldNotBeReady", func(t *testing.T) {
+		registry := health.NewRegistry(time.Second)
+		registry.Register("database", func(ctx context.Context) error { return nil })
+		registry.Register("migrations", func(ctx context.Context) error { return errors.New("1 migration(s) pending") })
+		statusCode, healthResponse := readHealth(t, newHealthServer(registry), "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+		assert.Equal(t, "down", healthResponse.Status)
+		assert.Equal(t, "up", healthResponse.Components[0].Status)
+		assert.Equal(t, response.ComponentHealthResponse{
+			Name:   "migrations",
+			Status: "down",
+			Error:  "1 migration(s) pending",
+		}, zeroLatency(healthResponse.Components[1]))
+	})
+	t.Run("WhenACheckHangs_ShouldTimeOut", func(t *testing.T) {
+		registry := health.NewRegistry(20 * time.Millisecond)
+		registry.Register("database", func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		})
+		statusCode, healthResponse := readHealth(t, newHealthServer(registry), "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+		assert.Equal(t, context.DeadlineExceeded.Error(), healthResponse.Components[0].Error)
+	})
+	t.Run("WhenDraining_ShouldNotBeReady", func(t *testing.T) {
+		registry := health.NewRegistry(time.Second)
+		registry.Register("database", func(ctx context.Context) error { return nil })
+		registry.MarkDraining()
+		statusCode, healthResponse := readHealth(t, newHealthServer(registry), "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+		assert.Equal(t, "down", healthResponse.Status)
+		assert.Equal(t, "lifecycle", healthResponse.Components[0].Name)
+		assert.Equal(t, "shutting down", healthResponse.Components[0].Error)
+	})
+}
+
+// ?zeroLatency makes timing independent assertions possible
+func zeroLatency(component response.ComponentHealthResponse) response.ComponentHealthResponse {
+	component.LatencyMs = 0
+	return component
+}
@@ -0,0 +1,76 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"product-app/common/logging"
+	"product-app/common/metrics"
+	"product-app/controller"
+	"product-app/domain"
+	"product-app/persistence"
+	"product-app/service"
+	testservice "product-app/test/service"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// *failingProductRepository fails every lookup the way an unreachable database would
+type failingProductRepository struct {
+	persistence.IProductRepository
+}
+
+func (failingProductRepository) GetProductById(ctx context.Context, productId int64) (domain.Product, error) {
+	return domain.Product{}, domain.NewUnavailableError("Database unavailable", errors.New("connection refused"))
+}
+
+// *newMeteredServer wires the product routes with HTTP and repository metrics
+func newMeteredServer(productRepository persistence.IProductRepository) *echo.Echo {
+	registry := metrics.NewRegistry()
+	e := echo.New()
+	e.HTTPErrorHandler = controller.NewHTTPErrorHandler(logging.Discard())
+	e.Use(controller.RequestMetrics(metrics.NewHTTPMetrics(registry)))
+	e.Use(controller.RequestLogger(logging.Discard()))
+	controller.NewMetricsController(registry).RegisterRoutes(e)
+
+	instrumented := persistence.NewInstrumentedProductRepository(productRepository, metrics.NewQueryMetrics(registry))
+	productService := service.NewProductService(instrumented, testservice.NewFakeStoreRepository(nil),
+		testservice.NewFakeAuditRepository(), testservice.NewFakePriceHistoryRepository(), testservice.NewFakeTransactor(), logging.Discard())
+	controller.NewProductController(&productService).RegisterRoutes(e)
+	return e
+}
+
+func Test_ShouldExposeRequestAndQueryMetrics(t *testing.T) {
+	t.Run("ShouldCountRequestsPerRouteAndStatusClass", func(t *testing.T) {
+		e := newMeteredServer(testservice.NewFakeProductRepository([]domain.Product{
+			{Id: 1, Name: "AirFryer", Price: domain.NewDecimal(1000), Currency: "TRY", Store: "ABC TECH"},
+		}))
+		serve(e, http.MethodGet, "/api/v1/products/1/")
+		serve(e, http.MethodGet, "/api/v1/products/1/")
+		serve(e, http.MethodGet, "/api/v1/products/2/")
+		serve(e, http.MethodGet, "/no/such/route")
+
+		recorder := serve(e, http.MethodGet, "/metrics")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", recorder.Header().Get(echo.HeaderContentType))
+		body := recorder.Body.String()
+		assert.Contains(t, body, `http_requests_total{method="GET",route="/api/v1/products/:id/",status_class="2xx"} 2`)
+		assert.Contains(t, body, `http_requests_total{method="GET",route="/api/v1/products/:id/",status_class="4xx"} 1`)
+		assert.Contains(t, body, `http_requests_total{method="GET",route="unmatched",status_class="4xx"} 1`)
+		assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/api/v1/products/:id/"} 3`)
+		assert.Contains(t, body, `db_query_duration_seconds_count{method="GetProductById"} 3`)
+		assert.NotContains(t, body, `db_query_errors_total{method="GetProductById"}`)
+		assert.NotContains(t, body, "/no/such/route")
+	})
+	t.Run("ShouldCountRepositoryFailures", func(t *testing.T) {
+		e := newMeteredServer(failingProductRepository{testservice.NewFakeProductRepository(nil)})
+		recorder := serve(e, http.MethodGet, "/api/v1/products/1/")
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+		body := serve(e, http.MethodGet, "/metrics").Body.String()
+		assert.Contains(t, body, `db_query_errors_total{method="GetProductById"} 1`)
+		assert.Contains(t, body, `http_requests_total{method="GET",route="/api/v1/products/:id/",status_class="5xx"} 1`)
+	})
+}
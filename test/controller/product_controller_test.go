@@ -0,0 +1,681 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"product-app/common/health"
+	"product-app/common/logging"
+	"product-app/common/metrics"
+	"product-app/controller"
+	"product-app/controller/request"
+	"product-app/controller/response"
+	"product-app/domain"
+	"product-app/service"
+	testservice "product-app/test/service"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// *newServer registers every route of the application, the real controllers and services over fake repositories
+func newServer(initialProducts ...domain.Product) *echo.Echo {
+	e := echo.New()
+	e.HTTPErrorHandler = controller.NewHTTPErrorHandler(logging.Discard())
+	e.Use(controller.RequestActor())
+
+	if len(initialProducts) == 0 {
+		initialProducts = []domain.Product{
+			{
+				Id:       1,
+				Name:     "AirFryer",
+				Price:    domain.NewDecimal(1000),
+				Currency: "TRY",
+				Store:    "ABC TECH",
+			},
+		}
+	}
+	productRepository, storeRepository := testservice.NewFakeCatalog(initialProducts)
+	auditRepository, transactor := testservice.NewFakeAuditRepository(), testservice.NewFakeTransactor()
+	productService := service.NewProductService(productRepository, storeRepository,
+		auditRepository, testservice.NewFakePriceHistoryRepository(), transactor, logging.Discard())
+	storeService := service.NewStoreService(storeRepository, productRepository, auditRepository, transactor, productService, logging.Discard())
+	controller.RegisterRoutes(e, controller.Dependencies{
+		HealthRegistry:  health.NewRegistry(time.Second),
+		MetricsRegistry: metrics.NewRegistry(),
+		ProductService:  productService,
+		StoreService:    storeService,
+	})
+	return e
+}
+
+// *serve
+func serve(e *echo.Echo, method string, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+	return recorder
+}
+
+// *serveBody
+func serveBody(e *echo.Echo, method string, target string, contentType string, body string) *httptest.ResponseRecorder {
+	httpRequest := httptest.NewRequest(method, target, strings.NewReader(body))
+	httpRequest.Header.Set(echo.HeaderContentType, contentType)
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, httpRequest)
+	return recorder
+}
+
+func Test_ShouldListAllProducts(t *testing.T) {
+	e := newServer()
+	t.Run("ShouldListAllProducts", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/products/", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"items":[{"id":1,"name":"AirFryer","price":"1000.00","discount":"0","currency":"TRY","storeId":1,"store":"ABC TECH","version":1}],"nextCursor":null,"total":1}`, recorder.Body.String())
+		assert.Empty(t, recorder.Header().Get("Link"))
+	})
+}
+
+func Test_ShouldPageThroughProductsWithCursor(t *testing.T) {
+	e := newServer(
+		domain.Product{Id: 1, Name: "AirFryer", Price: domain.NewDecimal(3000), Store: "ABC TECH", Currency: "TRY"},
+		domain.Product{Id: 2, Name: "Ütü", Price: domain.NewDecimal(1500), Store: "ABC TECH", Currency: "TRY"},
+		domain.Product{Id: 3, Name: "Lambader", Price: domain.NewDecimal(2000), Store: "Dekorasyon Sarayı", Currency: "TRY"},
+	)
+	t.Run("ShouldPageThroughProductsWithCursor", func(t *testing.T) {
+		firstPage := serve(e, http.MethodGet, "/api/v1/products/?limit=2")
+		assert.Equal(t, http.StatusOK, firstPage.Code)
+		assert.JSONEq(t, `{"items":[
+			{"id":1,"name":"AirFryer","price":"3000.00","discount":"0","currency":"TRY","storeId":1,"store":"ABC TECH","version":1},
+			{"id":2,"name":"Ütü","price":"1500.00","discount":"0","currency":"TRY","storeId":1,"store":"ABC TECH","version":1}
+		],"nextCursor":"2","total":3}`, firstPage.Body.String())
+		assert.Equal(t, `</api/v1/products/?after_id=2&limit=2>; rel="next"`, firstPage.Header().Get("Link"))
+
+		secondPage := serve(e, http.MethodGet, "/api/v1/products/?after_id=2&limit=2")
+		assert.JSONEq(t, `{"items":[
+			{"id":3,"name":"Lambader","price":"2000.00","discount":"0","currency":"TRY","storeId":2,"store":"Dekorasyon Sarayı","version":1}
+		],"nextCursor":null,"total":3}`, secondPage.Body.String())
+		assert.Equal(t, `</api/v1/products/?limit=2>; rel="first"`, secondPage.Header().Get("Link"))
+	})
+}
+
+func Test_ShouldPageThroughProductsWithOffset(t *testing.T) {
+	e := newServer(
+		domain.Product{Id: 1, Name: "AirFryer", Price: domain.NewDecimal(3000), Store: "ABC TECH", Currency: "TRY"},
+		domain.Product{Id: 2, Name: "Ütü", Price: domain.NewDecimal(1500), Store: "ABC TECH", Currency: "TRY"},
+		domain.Product{Id: 3, Name: "Çamaşır Makinesi", Price: domain.NewDecimal(10000), Store: "ABC TECH", Currency: "TRY"},
+		domain.Product{Id: 4, Name: "Lambader", Price: domain.NewDecimal(2000), Store: "Dekorasyon Sarayı", Currency: "TRY"},
+	)
+	t.Run("ShouldPageThroughProductsWithOffset", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/products/?store=ABC+TECH&limit=1&offset=1")
+		assert.JSONEq(t, `{"items":[
+			{"id":2,"name":"Ütü","price":"1500.00","discount":"0","currency":"TRY","storeId":1,"store":"ABC TECH","version":1}
+		],"nextCursor":"2","total":3}`, recorder.Body.String())
+		assert.Equal(t, `</api/v1/products/?after_id=2&limit=1&store=ABC+TECH>; rel="next", `+
+			`</api/v1/products/?limit=1&store=ABC+TECH>; rel="prev", `+
+			`</api/v1/products/?limit=1&store=ABC+TECH>; rel="first"`, recorder.Header().Get("Link"))
+	})
+}
+
+func Test_WhenPaginationParametersAreInvalid_ShouldRejectRequest(t *testing.T) {
+	e := newServer()
+	t.Run("WhenPaginationParametersAreInvalid_ShouldRejectRequest", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(e, http.MethodGet, "/api/v1/products/?limit=ten").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(e, http.MethodGet, "/api/v1/products/?limit=100000").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(e, http.MethodGet, "/api/v1/products/?offset=-1").Code)
+	})
+}
+
+func Test_WhenListingTimesOut_ShouldRespondServiceUnavailable(t *testing.T) {
+	e := newServer()
+	t.Run("WhenListingTimesOut_ShouldRespondServiceUnavailable", func(t *testing.T) {
+		expiredCtx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/products/", nil).WithContext(expiredCtx))
+		assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	})
+}
+
+func Test_WhenClientCancelsListing_ShouldNotRespondInternalError(t *testing.T) {
+	e := newServer()
+	t.Run("WhenClientCancelsListing_ShouldNotRespondInternalError", func(t *testing.T) {
+		cancelledCtx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/products/", nil).WithContext(cancelledCtx))
+		assert.Equal(t, controller.StatusClientClosedRequest, recorder.Code)
+	})
+}
+
+func Test_ShouldFilterAndSortProducts(t *testing.T) {
+	e := newServer(
+		domain.Product{Id: 1, Name: "AirFryer", Price: domain.NewDecimal(3000), Discount: domain.NewDecimal(22), Store: "ABC TECH", Currency: "TRY"},
+		domain.Product{Id: 2, Name: "Ütü", Price: domain.NewDecimal(1500), Discount: domain.NewDecimal(10), Store: "ABC TECH", Currency: "TRY"},
+		domain.Product{Id: 3, Name: "Çamaşır Makinesi", Price: domain.NewDecimal(10000), Discount: domain.NewDecimal(15), Store: "ABC TECH", Currency: "TRY"},
+		domain.Product{Id: 4, Name: "Lambader", Price: domain.NewDecimal(2000), Discount: domain.NewDecimal(10), Store: "Dekorasyon Sarayı", Currency: "TRY"},
+		domain.Product{Id: 5, Name: "Kupa", Price: domain.NewDecimal(100), Discount: domain.NewDecimal(0), Store: "Kırtasiye Merkezi", Currency: "TRY"},
+	)
+	t.Run("ShouldFilterAndSortProducts", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/products/?store=ABC+TECH&store=Dekorasyon+Saray%C4%B1&minPrice=1500&maxPrice=5000&minDiscount=10&sort=-discount,price")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"items":[
+			{"id":1,"name":"AirFryer","price":"3000.00","discount":"22","currency":"TRY","storeId":1,"store":"ABC TECH","version":1},
+			{"id":2,"name":"Ütü","price":"1500.00","discount":"10","currency":"TRY","storeId":1,"store":"ABC TECH","version":1},
+			{"id":4,"name":"Lambader","price":"2000.00","discount":"10","currency":"TRY","storeId":2,"store":"Dekorasyon Sarayı","version":1}
+		],"nextCursor":null,"total":3}`, recorder.Body.String())
+	})
+	t.Run("ShouldMatchNameCaseInsensitively", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/products/?name=air")
+		assert.JSONEq(t, `{"items":[
+			{"id":1,"name":"AirFryer","price":"3000.00","discount":"22","currency":"TRY","storeId":1,"store":"ABC TECH","version":1}
+		],"nextCursor":null,"total":1}`, recorder.Body.String())
+	})
+	t.Run("WhenSortedByPrice_ShouldPageWithOffset", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/products/?sort=price&limit=2")
+		assert.JSONEq(t, `{"items":[
+			{"id":5,"name":"Kupa","price":"100.00","discount":"0","currency":"TRY","storeId":3,"store":"Kırtasiye Merkezi","version":1},
+			{"id":2,"name":"Ütü","price":"1500.00","discount":"10","currency":"TRY","storeId":1,"store":"ABC TECH","version":1}
+		],"nextCursor":null,"total":5}`, recorder.Body.String())
+		assert.Equal(t, `</api/v1/products/?limit=2&offset=2&sort=price>; rel="next"`, recorder.Header().Get("Link"))
+	})
+}
+
+func Test_WhenSortFieldIsNotWhitelisted_ShouldRejectRequest(t *testing.T) {
+	e := newServer()
+	t.Run("WhenSortFieldIsNotWhitelisted_ShouldRejectRequest", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/products/?sort=price,-password")
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"field":"sort"`)
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(e, http.MethodGet, "/api/v1/products/?minPrice=10&maxPrice=5").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(e, http.MethodGet, "/api/v1/products/?sort=price&after_id=3").Code)
+	})
+}
+
+func Test_ShouldReplaceProductWithPut(t *testing.T) {
+	e := newServer()
+	t.Run("ShouldReplaceProductWithPut", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPut, "/api/v1/products/1/", echo.MIMEApplicationJSON,
+			`{"name":"AirFryer XL","price":1200,"discount":5,"store":"ABC TECH"}`)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"id":1,"name":"AirFryer XL","price":"1200.00","discount":"5","currency":"TRY","storeId":1,"store":"ABC TECH","version":2}`, recorder.Body.String())
+	})
+	t.Run("WhenDiscountIsHigherThan70_ShouldNotReplaceProduct", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPut, "/api/v1/products/1/", echo.MIMEApplicationJSON,
+			`{"name":"AirFryer XL","price":1200,"discount":75,"store":"ABC TECH"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	})
+	t.Run("WhenProductDoesNotExist_ShouldRespondNotFound", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPut, "/api/v1/products/42/", echo.MIMEApplicationJSON,
+			`{"name":"AirFryer XL","price":1200,"discount":5,"store":"ABC TECH"}`)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+	t.Run("WhenNewPriceIsGivenForMissingProduct_ShouldRespondNotFound", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodPut, "/api/v1/products/42/?newPrice=10").Code)
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodPut, "/api/v1/products/1/?newPrice=10").Code)
+	})
+}
+
+func Test_ShouldPatchOnlyGivenFields(t *testing.T) {
+	e := newServer(domain.Product{Id: 1, Name: "AirFryer", Price: domain.NewDecimal(3000), Discount: domain.NewDecimal(22), Store: "ABC TECH", Currency: "TRY"})
+	t.Run("ShouldPatchOnlyGivenFields", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPatch, "/api/v1/products/1/", "application/merge-patch+json", `{"price":2500}`)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"id":1,"name":"AirFryer","price":"2500.00","discount":"22","currency":"TRY","storeId":1,"store":"ABC TECH","version":2}`, recorder.Body.String())
+	})
+	t.Run("WhenDiscountIsNull_ShouldResetDiscount", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPatch, "/api/v1/products/1/", "application/merge-patch+json", `{"discount":null}`)
+		assert.JSONEq(t, `{"id":1,"name":"AirFryer","price":"2500.00","discount":"0","currency":"TRY","storeId":1,"store":"ABC TECH","version":3}`, recorder.Body.String())
+	})
+	t.Run("WhenCurrencyIsNull_ShouldResetCurrencyToDefault", func(t *testing.T) {
+		serveBody(e, http.MethodPatch, "/api/v1/products/1/", "application/merge-patch+json", `{"currency":"USD"}`)
+		recorder := serveBody(e, http.MethodPatch, "/api/v1/products/1/", "application/merge-patch+json", `{"currency":null}`)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"id":1,"name":"AirFryer","price":"2500.00","discount":"0","currency":"TRY","storeId":1,"store":"ABC TECH","version":5}`, recorder.Body.String())
+	})
+	t.Run("WhenRequiredFieldIsRemoved_ShouldRejectPatch", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPatch, "/api/v1/products/1/", "application/merge-patch+json", `{"name":null}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"field":"name"`)
+	})
+	t.Run("WhenPatchHasUnknownFields_ShouldRejectPatch", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPatch, "/api/v1/products/1/", "application/merge-patch+json", `{"name":null,"colour":"red"}`)
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"field":"colour"`)
+		assert.Contains(t, recorder.Body.String(), `"field":"name"`)
+	})
+	t.Run("WhenPatchBreaksValidation_ShouldRejectPatch", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPatch, "/api/v1/products/1/", "application/merge-patch+json", `{"discount":90}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	})
+	t.Run("WhenProductDoesNotExist_ShouldRespondNotFound", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPatch, "/api/v1/products/42/", "application/merge-patch+json", `{"price":2500}`)
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+	t.Run("WhenBodyIsTooLarge_ShouldRejectPatch", func(t *testing.T) {
+		body := `{"name":"` + strings.Repeat("a", request.MaxBodyBytes) + `"}`
+		recorder := serveBody(e, http.MethodPatch, "/api/v1/products/1/", "application/merge-patch+json", body)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, serveBody(e, http.MethodPut, "/api/v1/products/1/", echo.MIMEApplicationJSON, body).Code)
+		assert.Contains(t, serve(e, http.MethodGet, "/api/v1/products/1/").Body.String(), `"name":"AirFryer"`)
+	})
+	t.Run("WhenContentTypeIsNotJson_ShouldRejectPatch", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPatch, "/api/v1/products/1/", echo.MIMETextPlain, `price=2500`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, recorder.Code)
+	})
+}
+
+// *serveIfMatch sends a JSON body guarded by the given If-Match header
+func serveIfMatch(e *echo.Echo, method string, target string, ifMatch string, body string) *httptest.ResponseRecorder {
+	httpRequest := httptest.NewRequest(method, target, strings.NewReader(body))
+	httpRequest.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	httpRequest.Header.Set(request.HeaderIfMatch, ifMatch)
+	recorder := httptest.NewRecorder()
+	e.ServeHTTP(recorder, httpRequest)
+	return recorder
+}
+
+func Test_WhenIfMatchIsStale_ShouldRejectWrite(t *testing.T) {
+	e := newServer()
+	replacement := `{"name":"AirFryer XL","price":1200,"discount":5,"store":"ABC TECH"}`
+	t.Run("ShouldReturnVersionAsETag", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/products/1/")
+		assert.Equal(t, `"1"`, recorder.Header().Get(response.HeaderETag))
+	})
+	t.Run("WhenIfMatchIsCurrent_ShouldWriteAndReturnNewETag", func(t *testing.T) {
+		recorder := serveIfMatch(e, http.MethodPut, "/api/v1/products/1/", `"1"`, replacement)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, `"2"`, recorder.Header().Get(response.HeaderETag))
+	})
+	t.Run("WhenIfMatchIsStale_ShouldRespondPreconditionFailed", func(t *testing.T) {
+		for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			recorder := serveIfMatch(e, method, "/api/v1/products/1/", `"1"`, replacement)
+			assert.Equal(t, http.StatusPreconditionFailed, recorder.Code, method)
+			assert.Contains(t, recorder.Body.String(), `"code":"PRECONDITION_FAILED"`, method)
+		}
+		assert.Equal(t, http.StatusPreconditionFailed, serveIfMatch(e, http.MethodPut, "/api/v1/products/1/?newPrice=10", `"1"`, "").Code)
+	})
+	t.Run("WhenIfMatchIsWeakOrMalformed_ShouldRespondPreconditionFailed", func(t *testing.T) {
+		assert.Equal(t, http.StatusPreconditionFailed, serveIfMatch(e, http.MethodPut, "/api/v1/products/1/", `W/"2"`, replacement).Code)
+		assert.Equal(t, http.StatusPreconditionFailed, serveIfMatch(e, http.MethodPut, "/api/v1/products/1/", `2`, replacement).Code)
+	})
+	t.Run("WhenIfMatchHasSeveralTags_ShouldRespondBadRequest", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serveIfMatch(e, http.MethodPut, "/api/v1/products/1/", `"1", "2"`, replacement).Code)
+	})
+	t.Run("WhenIfMatchIsAnyVersion_ShouldWrite", func(t *testing.T) {
+		recorder := serveIfMatch(e, http.MethodPut, "/api/v1/products/1/", "*", replacement)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, `"3"`, recorder.Header().Get(response.HeaderETag))
+		assert.Equal(t, http.StatusOK, serveIfMatch(e, http.MethodDelete, "/api/v1/products/1/", `"3"`, "").Code)
+	})
+}
+
+func Test_ShouldCreateProductAndReturnItsLocation(t *testing.T) {
+	e := newServer()
+	t.Run("ShouldCreateProductAndReturnItsLocation", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPost, "/api/v1/products/", echo.MIMEApplicationJSON,
+			`{"name":"Kupa","price":100,"discount":0,"store":"Kırtasiye Merkezi"}`)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Equal(t, "/api/v1/products/2/", recorder.Header().Get(echo.HeaderLocation))
+		assert.JSONEq(t, `{"id":2,"name":"Kupa","price":"100.00","discount":"0","currency":"TRY","storeId":2,"store":"Kırtasiye Merkezi","version":1}`, recorder.Body.String())
+
+		createdProduct := serve(e, http.MethodGet, recorder.Header().Get(echo.HeaderLocation))
+		assert.Equal(t, http.StatusOK, createdProduct.Code)
+		assert.JSONEq(t, recorder.Body.String(), createdProduct.Body.String())
+	})
+}
+
+func Test_WhenProductIsInvalid_ShouldReportEveryViolation(t *testing.T) {
+	e := newServer()
+	t.Run("WhenProductIsInvalid_ShouldReportEveryViolation", func(t *testing.T) {
+		recorder := serveBody(e, http.MethodPost, "/api/v1/products/", echo.MIMEApplicationJSON,
+			`{"name":"  ","price":-5,"discount":80,"store":""}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+
+		var errorResponse response.ErrorResponse
+		json.Unmarshal(recorder.Body.Bytes(), &errorResponse)
+		assert.Equal(t, controller.CodeValidationFailed, errorResponse.Code)
+		assert.Equal(t, []response.FieldErrorResponse{
+			{Field: "name", Code: "required", Message: "Name can not be empty"},
+			{Field: "store", Code: "required", Message: "Store can not be empty"},
+			{Field: "price", Code: "min", Message: "Price can not be negative"},
+			{Field: "discount", Code: "max", Message: "Discount can not be greater than 70"},
+		}, errorResponse.Details)
+	})
+}
+
+func Test_WhenRequestIsMalformed_ShouldRespondBadRequest(t *testing.T) {
+	e := newServer()
+	testCases := []struct {
+		name          string
+		method        string
+		target        string
+		body          string
+		expectedField string
+	}{
+		{"NonNumericId", http.MethodGet, "/api/v1/products/abc/", "", "id"},
+		{"NegativeId", http.MethodDelete, "/api/v1/products/-1/", "", "id"},
+		{"UnknownField", http.MethodPost, "/api/v1/products/", `{"name":"Kupa","price":100,"store":"ABC TECH","colour":"red"}`, "colour"},
+		{"WrongType", http.MethodPost, "/api/v1/products/", `{"name":"Kupa","price":"cheap","store":"ABC TECH"}`, "price"},
+		{"NotJson", http.MethodPut, "/api/v1/products/1/", `{"name":`, ""},
+		{"NaNPrice", http.MethodPut, "/api/v1/products/1/?newPrice=NaN", "", "newPrice"},
+		{"InfiniteFilter", http.MethodGet, "/api/v1/products/?maxPrice=Inf&limit=x", "", "maxPrice"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			recorder := serveBody(e, testCase.method, testCase.target, echo.MIMEApplicationJSON, testCase.body)
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			if len(testCase.expectedField) > 0 {
+				assert.Contains(t, recorder.Body.String(), fmt.Sprintf(`"field":"%s"`, testCase.expectedField))
+			}
+		})
+	}
+}
+
+func Test_ShouldRestoreDeletedProductFromTrash(t *testing.T) {
+	e := newServer()
+	t.Run("WhenProductIsDeleted_ShouldListItInTrash", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodDelete, "/api/v1/products/1/").Code)
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/api/v1/products/1/").Code)
+
+		recorder := serve(e, http.MethodGet, "/api/v1/products/trash/")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var deletedProducts response.DeletedProductsResponse
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &deletedProducts))
+		assert.Equal(t, int64(1), deletedProducts.Total)
+		assert.NotNil(t, deletedProducts.Items[0].DeletedAt)
+	})
+	t.Run("WhenIfMatchIsStale_ShouldNotRestore", func(t *testing.T) {
+		recorder := serveIfMatch(e, http.MethodPost, "/api/v1/products/1/restore/", `"1"`, "")
+		assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+	})
+	t.Run("ShouldRestoreProduct", func(t *testing.T) {
+		recorder := serve(e, http.MethodPost, "/api/v1/products/1/restore/")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"id":1,"name":"AirFryer","price":"1000.00","discount":"0","currency":"TRY","storeId":1,"store":"ABC TECH","version":3}`, recorder.Body.String())
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/api/v1/products/1/").Code)
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodPost, "/api/v1/products/1/restore/").Code)
+	})
+	t.Run("ShouldPurgeOnlyProductsOlderThanRetention", func(t *testing.T) {
+		serve(e, http.MethodDelete, "/api/v1/products/1/")
+		recorder := serve(e, http.MethodDelete, "/api/v1/products/trash/")
+		assert.JSONEq(t, `{"purged":0}`, recorder.Body.String())
+
+		recorder = serve(e, http.MethodDelete, "/api/v1/products/trash/?olderThan=0s")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"purged":1}`, recorder.Body.String())
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodPost, "/api/v1/products/1/restore/").Code)
+	})
+	t.Run("WhenRetentionIsMalformed_ShouldRespondBadRequest", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(e, http.MethodDelete, "/api/v1/products/trash/?olderThan=month").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(e, http.MethodDelete, "/api/v1/products/trash/?olderThan=-1h").Code)
+	})
+}
+
+func Test_ShouldListWhoChangedProduct(t *testing.T) {
+	e := newServer()
+	t.Run("WhenActorIsGiven_ShouldRecordIt", func(t *testing.T) {
+		httpRequest := httptest.NewRequest(http.MethodPut, "/api/v1/products/1/?newPrice=1100", nil)
+		httpRequest.Header.Set(controller.HeaderActor, "alice@example.com")
+		e.ServeHTTP(httptest.NewRecorder(), httpRequest)
+		serve(e, http.MethodDelete, "/api/v1/products/1/")
+
+		recorder := serve(e, http.MethodGet, "/api/v1/products/1/audit/")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var auditPage response.AuditPageResponse
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &auditPage))
+		assert.Equal(t, 2, len(auditPage.Items))
+		assert.Equal(t, "delete", auditPage.Items[0].Operation)
+		assert.Equal(t, controller.AnonymousActor, auditPage.Items[0].Actor)
+		assert.Nil(t, auditPage.Items[0].After)
+		assert.Equal(t, "price_change", auditPage.Items[1].Operation)
+		assert.Equal(t, "alice@example.com", auditPage.Items[1].Actor)
+		assert.Equal(t, "1000", auditPage.Items[1].Before.Price)
+		assert.Equal(t, "1100", auditPage.Items[1].After.Price)
+	})
+	t.Run("WhenAuditIsFiltered_ShouldLinkNextPage", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/audit/?productId=1&limit=1")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, `</api/v1/audit/?before_id=2&limit=1&productId=1>; rel="next"`, recorder.Header().Get("Link"))
+
+		recorder = serve(e, http.MethodGet, "/api/v1/audit/?actor=alice@example.com&operation=price_change")
+		assert.Contains(t, recorder.Body.String(), `"changes":[{"field":"price","before":"1000","after":"1100"}]`)
+		assert.Contains(t, recorder.Body.String(), `"nextCursor":null`)
+	})
+	t.Run("WhenAuditQueryIsInvalid_ShouldRejectRequest", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(e, http.MethodGet, "/api/v1/audit/?from=yesterday").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(e, http.MethodGet, "/api/v1/audit/?operation=drop").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity,
+			serve(e, http.MethodGet, "/api/v1/audit/?from=2026-02-01T00:00:00Z&to=2026-01-01T00:00:00Z").Code)
+	})
+}
+
+func Test_ShouldServePriceHistoryForCharts(t *testing.T) {
+	e := newServer()
+	serve(e, http.MethodPut, "/api/v1/products/1/?newPrice=1100")
+	serve(e, http.MethodPut, "/api/v1/products/1/?newPrice=1050")
+	t.Run("ShouldListEveryChange", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/products/1/prices/")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var priceHistory response.PriceHistoryResponse
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &priceHistory))
+		assert.Nil(t, priceHistory.Bucket)
+		assert.Nil(t, priceHistory.Buckets)
+		assert.Equal(t, 2, len(priceHistory.Points))
+		assert.Equal(t, "1050", priceHistory.Points[1].Price)
+	})
+	t.Run("WhenBucketIsGiven_ShouldReturnMinMaxAndLast", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/products/1/prices/?bucket=week")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var priceHistory response.PriceHistoryResponse
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &priceHistory))
+		assert.Equal(t, "week", *priceHistory.Bucket)
+		assert.Equal(t, 1, len(priceHistory.Buckets))
+		assert.Equal(t, response.RangeResponse{Min: "1050", Max: "1100", Last: "1050"}, priceHistory.Buckets[0].Price)
+		assert.Equal(t, time.Monday, priceHistory.Buckets[0].Start.Weekday())
+	})
+	t.Run("WhenRangeIsOutsideHistory_ShouldReturnEmptySeries", func(t *testing.T) {
+		recorder := serve(e, http.MethodGet, "/api/v1/products/1/prices/?to=2020-01-01T00:00:00Z")
+		assert.JSONEq(t, `{"productId":1,"bucket":null,"points":[],"buckets":null}`, recorder.Body.String())
+	})
+	t.Run("WhenProductDoesNotExist_ShouldRespondNotFound", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/api/v1/products/42/prices/").Code)
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/api/v1/products/42/prices/?bucket=day").Code)
+	})
+	t.Run("WhenProductIsDeleted_ShouldRespondNotFound", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodDelete, "/api/v1/products/1/").Code)
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/api/v1/products/1/prices/").Code)
+	})
+	t.Run("WhenQueryIsInvalid_ShouldRejectRequest", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, serve(e, http.MethodGet, "/api/v1/products/1/prices/?from=today").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(e, http.MethodGet, "/api/v1/products/1/prices/?bucket=month").Code)
+	})
+}
+
+func Test_ShouldImportProductsFromCsvAndNdjson(t *testing.T) {
+	t.Run("WhenCsvIsValid_ShouldAddEveryRow", func(t *testing.T) {
+		e := newServer()
+		csvBody := "name,price,discount,currency,store\nKupa,19.99,0,USD,Kırtasiye Merkezi\n\"Kalem, mavi\",5,,,Kırtasiye Merkezi\n"
+		recorder := serveBody(e, http.MethodPost, "/api/v1/products/import/", request.MIMETextCSV, csvBody)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"mode":"all_or_nothing","committed":true,"accepted":2,"rejected":0,"rows":[
+			{"line":2,"status":"accepted","productId":2},{"line":3,"status":"accepted","productId":3}]}`, recorder.Body.String())
+
+		recorder = serve(e, http.MethodGet, "/api/v1/products/3/")
+		assert.Contains(t, recorder.Body.String(), `"name":"Kalem, mavi"`)
+		assert.Contains(t, recorder.Body.String(), `"currency":"TRY"`)
+	})
+	t.Run("WhenRowIsRejectedInAllOrNothingMode_ShouldReportItWithoutWriting", func(t *testing.T) {
+		e := newServer()
+		csvBody := "store,name,price\nABC TECH,Kupa,abc\nABC TECH,Tabak,10\nABC TECH,Bardak\n"
+		recorder := serveBody(e, http.MethodPost, "/api/v1/products/import/", request.MIMETextCSV, csvBody)
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.JSONEq(t, `{"mode":"all_or_nothing","committed":false,"accepted":0,"rejected":2,"rows":[
+			{"line":2,"status":"rejected","errors":[{"field":"price","code":"type","message":"Field price must be a decimal number"}]},
+			{"line":3,"status":"skipped"},
+			{"line":4,"status":"rejected","errors":[{"field":"row","code":"columns","message":"Line has 2 columns, the header has 3"}]}]}`,
+			recorder.Body.String())
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/api/v1/products/2/").Code)
+	})
+	t.Run("WhenNdjsonIsImportedWithBestEffort_ShouldWriteValidLines", func(t *testing.T) {
+		e := newServer()
+		ndjsonBody := `{"name":"Kupa","price":"19.99","currency":"USD","store":"Kırtasiye Merkezi"}` + "\n\n" +
+			`{"name":"Tabak","price":10,"colour":"red","store":"ABC TECH"}` + "\n" +
+			`[1,2]` + "\n" +
+			`{"name":"","price":10,"store":"ABC TECH"}`
+		recorder := serveBody(e, http.MethodPost, "/api/v1/products/import/?mode=best_effort", request.MIMEApplicationNDJSON, ndjsonBody)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var report response.ImportReportResponse
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+		assert.Equal(t, 1, report.Accepted)
+		assert.Equal(t, []int{1, 3, 4, 5}, []int{report.Rows[0].Line, report.Rows[1].Line, report.Rows[2].Line, report.Rows[3].Line})
+		assert.Equal(t, "unknown", report.Rows[1].Errors[0].Code)
+		assert.Equal(t, "row", report.Rows[2].Errors[0].Field)
+		assert.Equal(t, "required", report.Rows[3].Errors[0].Code)
+	})
+	t.Run("WhenFileCanNotBeRead_ShouldRejectImport", func(t *testing.T) {
+		e := newServer()
+		assert.Equal(t, http.StatusUnsupportedMediaType, serveBody(e, http.MethodPost, "/api/v1/products/import/", echo.MIMEApplicationJSON, "{}").Code)
+		assert.Equal(t, http.StatusBadRequest, serveBody(e, http.MethodPost, "/api/v1/products/import/", request.MIMETextCSV, "").Code)
+		assert.Equal(t, http.StatusBadRequest, serveBody(e, http.MethodPost, "/api/v1/products/import/", request.MIMETextCSV, "name,colour\n").Code)
+		assert.Equal(t, http.StatusBadRequest, serveBody(e, http.MethodPost, "/api/v1/products/import/", request.MIMETextCSV, "name,price,store\n\"Kupa,1,A\n").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, serveBody(e, http.MethodPost, "/api/v1/products/import/", request.MIMETextCSV, "name,price,store\n").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity,
+			serveBody(e, http.MethodPost, "/api/v1/products/import/?mode=partial", request.MIMETextCSV, "name,price,store\nKupa,1,A\n").Code)
+	})
+	t.Run("WhenFileIsTooLarge_ShouldStopReadingIt", func(t *testing.T) {
+		e := newServer()
+		tooManyRows := strings.Repeat("{}\n", domain.MaxImportRows+1)
+		recorder := serveBody(e, http.MethodPost, "/api/v1/products/import/", request.MIMEApplicationNDJSON, tooManyRows)
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"max"`)
+		assert.Equal(t, http.StatusUnprocessableEntity,
+			serveBody(e, http.MethodPost, "/api/v1/products/import/", request.MIMETextCSV, "name,price,store\n"+strings.Repeat("Kupa,1,A\n", domain.MaxImportRows+1)).Code)
+
+		longLine := `{"name":"` + strings.Repeat("a", 64<<10) + `"}`
+		assert.Equal(t, http.StatusBadRequest, serveBody(e, http.MethodPost, "/api/v1/products/import/", request.MIMEApplicationNDJSON, longLine).Code)
+
+		oversizedBody := "name,price,store\n" + strings.Repeat("Kupa,1,"+strings.Repeat("A", 1000)+"\n", request.MaxImportBytes/1000)
+		assert.Equal(t, http.StatusRequestEntityTooLarge,
+			serveBody(e, http.MethodPost, "/api/v1/products/import/", request.MIMETextCSV, oversizedBody).Code)
+	})
+}
+
+func Test_ShouldExportFilteredProducts(t *testing.T) {
+	exportProducts := func() []domain.Product {
+		return []domain.Product{
+			{Id: 1, Name: "AirFryer", Price: domain.NewDecimal(1000), Currency: "TRY", Store: "ABC TECH"},
+			{Id: 2, Name: "Kalem, mavi", Price: domain.MustParseDecimal("5.5"), Currency: "USD", Store: "Kırtasiye Merkezi"},
+			{Id: 3, Name: "Ütü", Price: domain.NewDecimal(2000), Discount: domain.NewDecimal(10), Currency: "TRY", Store: "ABC TECH"},
+		}
+	}
+	t.Run("WhenFormatIsMissing_ShouldStreamJsonArrayInListingOrder", func(t *testing.T) {
+		e := newServer(exportProducts()...)
+		recorder := serve(e, http.MethodGet, "/api/v1/products/export/?store=ABC+TECH&sort=-price&limit=1")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/json; charset=UTF-8", recorder.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, `attachment; filename="products.json"`, recorder.Header().Get(echo.HeaderContentDisposition))
+		assert.JSONEq(t, `[
+			{"id":3,"name":"Ütü","price":"2000.00","discount":"10","currency":"TRY","storeId":1,"store":"ABC TECH","version":1},
+			{"id":1,"name":"AirFryer","price":"1000.00","discount":"0","currency":"TRY","storeId":1,"store":"ABC TECH","version":1}]`,
+			recorder.Body.String())
+	})
+	t.Run("WhenFormatIsCsv_ShouldWriteHeaderAndQuoteFields", func(t *testing.T) {
+		e := newServer(exportProducts()...)
+		recorder := serve(e, http.MethodGet, "/api/v1/products/export/?format=csv&maxPrice=1000")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "text/csv; charset=UTF-8", recorder.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "id,name,price,discount,currency,storeId,store,version\n"+
+			"1,AirFryer,1000.00,0,TRY,1,ABC TECH,1\n"+
+			"2,\"Kalem, mavi\",5.50,0,USD,2,Kırtasiye Merkezi,1\n", recorder.Body.String())
+	})
+	t.Run("WhenFormatIsNdjson_ShouldWriteOneProductPerLine", func(t *testing.T) {
+		e := newServer(exportProducts()...)
+		recorder := serve(e, http.MethodGet, "/api/v1/products/export/?format=ndjson&name=a")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "application/x-ndjson", recorder.Header().Get(echo.HeaderContentType))
+		lines := strings.Split(strings.TrimSuffix(recorder.Body.String(), "\n"), "\n")
+		assert.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"id":1`)
+		assert.Contains(t, lines[1], `"id":2`)
+	})
+	t.Run("WhenNothingMatches_ShouldWriteEmptyFile", func(t *testing.T) {
+		e := newServer(exportProducts()...)
+		assert.Equal(t, "[]\n", serve(e, http.MethodGet, "/api/v1/products/export/?store=Unknown").Body.String())
+		assert.Equal(t, "id,name,price,discount,currency,storeId,store,version\n",
+			serve(e, http.MethodGet, "/api/v1/products/export/?store=Unknown&format=csv").Body.String())
+		assert.Empty(t, serve(e, http.MethodGet, "/api/v1/products/export/?store=Unknown&format=ndjson").Body.String())
+	})
+	t.Run("WhenQueryIsInvalid_ShouldRejectExport", func(t *testing.T) {
+		e := newServer(exportProducts()...)
+		recorder := serve(e, http.MethodGet, "/api/v1/products/export/?format=xml")
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"code":"enum"`)
+		assert.Empty(t, recorder.Header().Get(echo.HeaderContentDisposition))
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(e, http.MethodGet, "/api/v1/products/export/?sort=colour").Code)
+	})
+	t.Run("WhenRequestIsCancelled_ShouldNotStartDownload", func(t *testing.T) {
+		e := newServer(exportProducts()...)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/products/export/", nil).WithContext(ctx))
+		assert.NotEqual(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get(echo.HeaderContentDisposition))
+	})
+	t.Run("WhenExportIsLarge_ShouldFlushAndExtendWriteDeadlinePerBatch", func(t *testing.T) {
+		var manyProducts []domain.Product
+		for id := int64(1); id <= 300; id++ {
+			manyProducts = append(manyProducts, domain.Product{Id: id, Name: fmt.Sprintf("Ürün %d", id), Price: domain.NewDecimal(id), Currency: "TRY", Store: "ABC TECH"})
+		}
+		e := newServer(manyProducts...)
+		recorder := &batchRecorder{ResponseRecorder: httptest.NewRecorder()}
+		e.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/products/export/?format=ndjson", nil))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Len(t, strings.Split(strings.TrimSuffix(recorder.Body.String(), "\n"), "\n"), 300)
+		assert.Greater(t, len(recorder.batchSizes), 1)
+		assert.Equal(t, recorder.Body.Len(), sum(recorder.batchSizes))
+		for _, batchSize := range recorder.batchSizes {
+			assert.LessOrEqual(t, batchSize, 4096)
+		}
+		assert.Len(t, recorder.deadlines, len(recorder.batchSizes))
+		assert.WithinDuration(t, time.Now().Add(30*time.Second), recorder.deadlines[len(recorder.deadlines)-1], 5*time.Second)
+	})
+}
+
+// *batchRecorder records the write deadlines and the bytes written between two flushes
+type batchRecorder struct {
+	*httptest.ResponseRecorder
+	deadlines  []time.Time
+	batchSizes []int
+	flushedLen int
+}
+
+func (recorder *batchRecorder) SetWriteDeadline(deadline time.Time) error {
+	recorder.deadlines = append(recorder.deadlines, deadline)
+	return nil
+}
+
+func (recorder *batchRecorder) Flush() {
+	recorder.batchSizes = append(recorder.batchSizes, recorder.Body.Len()-recorder.flushedLen)
+	recorder.flushedLen = recorder.Body.Len()
+	recorder.ResponseRecorder.Flush()
+}
+
+// ?sum
+func sum(values []int) int {
+	total := 0
+	for _, value := range values {
+		total += value
+	}
+	return total
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"product-app/common/logging"
+	"product-app/controller"
+	"product-app/domain"
+	"product-app/service"
+	testservice "product-app/test/service"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// *newLoggedServer serves the product routes behind RequestLogger, writing JSON log lines into output
+func newLoggedServer(output *bytes.Buffer) *echo.Echo {
+	logger, _ := logging.NewLogger(logging.Config{Format: "json", Level: "info"}, output)
+	e := echo.New()
+	e.HTTPErrorHandler = controller.NewHTTPErrorHandler(logger)
+	e.Use(controller.RequestLogger(logger))
+	productRepository, storeRepository := testservice.NewFakeCatalog([]domain.Product{
+		{Id: 1, Name: "AirFryer", Price: domain.NewDecimal(1000), Currency: "TRY", Store: "ABC TECH"},
+	})
+	productService := service.NewProductService(productRepository, storeRepository, testservice.NewFakeAuditRepository(), testservice.NewFakePriceHistoryRepository(), testservice.NewFakeTransactor(), logger)
+	controller.NewProductController(&productService).RegisterRoutes(e)
+	return e
+}
+
+// *logLines decodes every JSON log line written so far
+func logLines(t *testing.T, output *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		var decoded map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(line), &decoded))
+		lines = append(lines, decoded)
+	}
+	return lines
+}
+
+func Test_ShouldLogEveryRequestWithItsRequestId(t *testing.T) {
+	t.Run("ShouldPropagateIncomingRequestId", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		e := newLoggedServer(output)
+		httpRequest := httptest.NewRequest(http.MethodGet, "/api/v1/products/1/", nil)
+		httpRequest.Header.Set(echo.HeaderXRequestID, "abc-123")
+		recorder := httptest.NewRecorder()
+		e.ServeHTTP(recorder, httpRequest)
+
+		assert.Equal(t, "abc-123", recorder.Header().Get(echo.HeaderXRequestID))
+		lines := logLines(t, output)
+		assert.Equal(t, 1, len(lines))
+		assert.Equal(t, "request", lines[0]["msg"])
+		assert.Equal(t, "INFO", lines[0]["level"])
+		assert.Equal(t, "abc-123", lines[0]["request_id"])
+		assert.Equal(t, "GET", lines[0]["method"])
+		assert.Equal(t, "/api/v1/products/:id/", lines[0]["route"])
+		assert.Equal(t, float64(200), lines[0]["status"])
+		assert.Contains(t, lines[0], "latency_ms")
+	})
+	t.Run("WhenRequestIdIsMissingOrUnsafe_ShouldGenerateOne", func(t *testing.T) {
+		for _, incoming := range []string{"", "has spaces\nand newlines", strings.Repeat("a", 129)} {
+			output := &bytes.Buffer{}
+			httpRequest := httptest.NewRequest(http.MethodGet, "/api/v1/products/1/", nil)
+			httpRequest.Header.Set(echo.HeaderXRequestID, incoming)
+			recorder := httptest.NewRecorder()
+			newLoggedServer(output).ServeHTTP(recorder, httpRequest)
+
+			requestId := recorder.Header().Get(echo.HeaderXRequestID)
+			assert.Len(t, requestId, 32)
+			assert.Equal(t, requestId, logLines(t, output)[0]["request_id"])
+		}
+	})
+	t.Run("ShouldLogErrorsWithTheStatusSentToTheClient", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		recorder := serve(newLoggedServer(output), http.MethodGet, "/api/v1/products/99/")
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		lines := logLines(t, output)
+		assert.Equal(t, "WARN", lines[0]["level"])
+		assert.Equal(t, float64(404), lines[0]["status"])
+		assert.Equal(t, "Product not found with id 99", lines[0]["error"])
+	})
+	t.Run("ShouldCarryRequestIdIntoServiceLogs", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		httpRequest := httptest.NewRequest(http.MethodPost, "/api/v1/products/",
+			strings.NewReader(`{"name":"Kupa","price":"10","store":"ABC TECH"}`))
+		httpRequest.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		httpRequest.Header.Set(echo.HeaderXRequestID, "req-42")
+		recorder := httptest.NewRecorder()
+		newLoggedServer(output).ServeHTTP(recorder, httpRequest)
+
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		lines := logLines(t, output)
+		assert.Equal(t, 2, len(lines))
+		assert.Equal(t, "Product added", lines[0]["msg"])
+		assert.Equal(t, "req-42", lines[0]["request_id"])
+		assert.Equal(t, "req-42", lines[1]["request_id"])
+	})
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"product-app/common/logging"
+	"product-app/common/tracing"
+	"product-app/controller"
+	"product-app/domain"
+	"product-app/service"
+	testservice "product-app/test/service"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// *recordingExporter keeps ended spans in memory
+type recordingExporter struct {
+	mutex sync.Mutex
+	spans []tracing.SpanData
+}
+
+func (exporter *recordingExporter) Export(span tracing.SpanData) {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+	exporter.spans = append(exporter.spans, span)
+}
+
+func (exporter *recordingExporter) Shutdown(ctx context.Context) error { return nil }
+
+// *attribute
+func attribute(span tracing.SpanData, key string) interface{} {
+	for _, attribute := range span.Attributes {
+		if attribute.Key == key {
+			return attribute.Value
+		}
+	}
+	return nil
+}
+
+// *newTracedServer wires the traced service behind RequestTracing
+func newTracedServer(exporter *recordingExporter) *echo.Echo {
+	tracer := tracing.NewTracer(exporter)
+	e := echo.New()
+	e.HTTPErrorHandler = controller.NewHTTPErrorHandler(logging.Discard())
+	e.Use(controller.RequestTracing(tracer))
+	e.Use(controller.RequestLogger(logging.Discard()))
+	productRepository, storeRepository := testservice.NewFakeCatalog([]domain.Product{
+		{Id: 1, Name: "AirFryer", Price: domain.NewDecimal(1000), Currency: "TRY", Store: "ABC TECH"},
+	})
+	productService := service.NewTracedProductService(service.NewProductService(productRepository, storeRepository, testservice.NewFakeAuditRepository(), testservice.NewFakePriceHistoryRepository(), testservice.NewFakeTransactor(), logging.Discard()), tracer)
+	controller.NewProductController(&productService).RegisterRoutes(e)
+	return e
+}
+
+func Test_ShouldTraceRequestsThroughTheService(t *testing.T) {
+	t.Run("ShouldContinueIncomingTraceparent", func(t *testing.T) {
+		exporter := &recordingExporter{}
+		httpRequest := httptest.NewRequest(http.MethodGet, "/api/v1/products/1/", nil)
+		httpRequest.Header.Set(controller.HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		recorder := httptest.NewRecorder()
+		newTracedServer(exporter).ServeHTTP(recorder, httpRequest)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, 2, len(exporter.spans))
+		serviceSpan, serverSpan := exporter.spans[0], exporter.spans[1]
+		assert.Equal(t, "ProductService.ProductById", serviceSpan.Name)
+		assert.Equal(t, "GET /api/v1/products/:id/", serverSpan.Name)
+		assert.Equal(t, tracing.SpanKindServer, serverSpan.Kind)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", serverSpan.SpanContext.TraceId.String())
+		assert.Equal(t, "00f067aa0ba902b7", serverSpan.ParentSpanId.String())
+		assert.Equal(t, serverSpan.SpanContext.TraceId, serviceSpan.SpanContext.TraceId)
+		assert.Equal(t, serverSpan.SpanContext.SpanId, serviceSpan.ParentSpanId)
+		assert.Equal(t, int64(200), attribute(serverSpan, "http.status_code"))
+		assert.Equal(t, int64(1), attribute(serviceSpan, "product.id"))
+	})
+	t.Run("WhenServiceFails_ShouldRecordErrorOnSpans", func(t *testing.T) {
+		exporter := &recordingExporter{}
+		recorder := serve(newTracedServer(exporter), http.MethodGet, "/api/v1/products/99/")
+
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+		serviceSpan, serverSpan := exporter.spans[0], exporter.spans[1]
+		assert.True(t, serviceSpan.Failed)
+		assert.Equal(t, "Product not found with id 99", serviceSpan.StatusMessage)
+		assert.False(t, serverSpan.Failed, "client errors do not fail the server span")
+		assert.Equal(t, int64(404), attribute(serverSpan, "http.status_code"))
+		assert.False(t, serverSpan.ParentSpanId.IsValid())
+	})
+}
@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"product-app/controller/response"
+	"product-app/domain"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// *storeProducts spreads products over two stores, the second one spelled differently by each product
+func storeProducts() []domain.Product {
+	return []domain.Product{
+		{Id: 1, Name: "AirFryer", Price: domain.NewDecimal(1000), Currency: "TRY", Store: "ABC TECH"},
+		{Id: 2, Name: "Lambader", Price: domain.NewDecimal(2000), Currency: "TRY", Store: "Dekorasyon Sarayı"},
+		{Id: 3, Name: "Ütü", Price: domain.NewDecimal(1500), Currency: "TRY", Store: " abc  tech"},
+	}
+}
+
+func Test_ShouldManageStores(t *testing.T) {
+	t.Run("ShouldListStoresOnceRegardlessOfSpelling", func(t *testing.T) {
+		e := newServer(storeProducts()...)
+		recorder := serve(e, http.MethodGet, "/api/v1/stores/")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		var stores []response.StoreResponse
+		assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &stores))
+		assert.Equal(t, []string{"ABC TECH", "Dekorasyon Sarayı"}, []string{stores[0].Name, stores[1].Name})
+	})
+	t.Run("ShouldCreateStoreAndReturnItsLocation", func(t *testing.T) {
+		e := newServer(storeProducts()...)
+		recorder := serveBody(e, http.MethodPost, "/api/v1/stores/", echo.MIMEApplicationJSON, `{"name":"  Kırtasiye   Merkezi "}`)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Equal(t, "/api/v1/stores/3/", recorder.Header().Get(echo.HeaderLocation))
+		assert.Contains(t, recorder.Body.String(), `"name":"Kırtasiye Merkezi"`)
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodGet, "/api/v1/stores/3/").Code)
+	})
+	t.Run("WhenNameIsTakenOrEmpty_ShouldRejectStore", func(t *testing.T) {
+		e := newServer(storeProducts()...)
+		assert.Equal(t, http.StatusConflict, serveBody(e, http.MethodPost, "/api/v1/stores/", echo.MIMEApplicationJSON, `{"name":"abc tech"}`).Code)
+		assert.Equal(t, http.StatusConflict, serveBody(e, http.MethodPut, "/api/v1/stores/2/", echo.MIMEApplicationJSON, `{"name":"Abc Tech"}`).Code)
+		recorder := serveBody(e, http.MethodPost, "/api/v1/stores/", echo.MIMEApplicationJSON, `{"name":"   "}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"field":"name"`)
+		assert.Equal(t, http.StatusBadRequest, serveBody(e, http.MethodPost, "/api/v1/stores/", echo.MIMEApplicationJSON, `{"title":"Kupa"}`).Code)
+	})
+	t.Run("WhenStoreIsRenamed_ShouldListProductsUnderNewName", func(t *testing.T) {
+		e := newServer(storeProducts()...)
+		etagBefore := serve(e, http.MethodGet, "/api/v1/products/1/").Header().Get(response.HeaderETag)
+		recorder := serveBody(e, http.MethodPut, "/api/v1/stores/1/", echo.MIMEApplicationJSON, `{"name":"ABC Teknoloji"}`)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"name":"ABC Teknoloji"`)
+		productRecorder := serve(e, http.MethodGet, "/api/v1/products/1/")
+		assert.Contains(t, productRecorder.Body.String(), `"store":"ABC Teknoloji"`)
+		assert.NotEqual(t, etagBefore, productRecorder.Header().Get(response.HeaderETag))
+		assert.Equal(t, http.StatusPreconditionFailed,
+			serveIfMatch(e, http.MethodPatch, "/api/v1/products/1/", etagBefore, `{"price":1200}`).Code)
+		assert.Equal(t, http.StatusNotFound, serveBody(e, http.MethodPut, "/api/v1/stores/42/", echo.MIMEApplicationJSON, `{"name":"Kupa"}`).Code)
+	})
+	t.Run("WhenStoreHasProducts_ShouldNotDeleteIt", func(t *testing.T) {
+		e := newServer(storeProducts()...)
+		assert.Equal(t, http.StatusConflict, serve(e, http.MethodDelete, "/api/v1/stores/2/").Code)
+		assert.Equal(t, http.StatusOK, serveIfMatch(e, http.MethodDelete, "/api/v1/products/2/", `"1"`, "").Code)
+		assert.Equal(t, http.StatusConflict, serve(e, http.MethodDelete, "/api/v1/stores/2/").Code)
+
+		serveBody(e, http.MethodPost, "/api/v1/stores/", echo.MIMEApplicationJSON, `{"name":"Kırtasiye Merkezi"}`)
+		assert.Equal(t, http.StatusOK, serve(e, http.MethodDelete, "/api/v1/stores/3/").Code)
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/api/v1/stores/3/").Code)
+	})
+}
+
+func Test_ShouldListProductsOfStore(t *testing.T) {
+	t.Run("ShouldOnlyListProductsOfStore", func(t *testing.T) {
+		e := newServer(storeProducts()...)
+		recorder := serve(e, http.MethodGet, "/api/v1/stores/1/products/?sort=-price")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"items":[
+			{"id":3,"name":"Ütü","price":"1500.00","discount":"0","currency":"TRY","storeId":1,"store":"ABC TECH","version":1},
+			{"id":1,"name":"AirFryer","price":"1000.00","discount":"0","currency":"TRY","storeId":1,"store":"ABC TECH","version":1}],
+			"nextCursor":null,"total":2}`, recorder.Body.String())
+	})
+	t.Run("WhenProductNamesStoreInAnotherSpelling_ShouldJoinExistingStore", func(t *testing.T) {
+		e := newServer(storeProducts()...)
+		recorder := serveBody(e, http.MethodPost, "/api/v1/products/", echo.MIMEApplicationJSON,
+			`{"name":"Abajur","price":300,"store":"dekorasyon  sarayı "}`)
+		assert.Equal(t, http.StatusCreated, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), `"storeId":2,"store":"Dekorasyon Sarayı"`)
+
+		recorder = serve(e, http.MethodGet, "/api/v1/stores/2/products/?limit=1")
+		assert.Contains(t, recorder.Body.String(), `"total":2`)
+		assert.Contains(t, recorder.Header().Get("Link"), `rel="next"`)
+		assert.Contains(t, serve(e, http.MethodGet, "/api/v1/products/?store=dekorasyon%20saray%C4%B1").Body.String(), `"total":2`)
+	})
+	t.Run("WhenStoreDoesNotExist_ShouldRespondNotFound", func(t *testing.T) {
+		e := newServer(storeProducts()...)
+		assert.Equal(t, http.StatusNotFound, serve(e, http.MethodGet, "/api/v1/stores/42/products/").Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(e, http.MethodGet, "/api/v1/stores/1/products/?limit=1000").Code)
+	})
+}
@@ -0,0 +1,101 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"product-app/domain"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ShouldParseAndFormatDecimalsExactly(t *testing.T) {
+	testCases := []struct {
+		input         string
+		expected      string
+		expectedFixed string
+	}{
+		{"19.99", "19.99", "19.99"},
+		{"10000.0", "10000", "10000.00"},
+		{"-0.5", "-0.5", "-0.50"},
+		{"0012.3400", "12.34", "12.34"},
+		{".25", "0.25", "0.25"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.input, func(t *testing.T) {
+			decimal, err := domain.ParseDecimal(testCase.input)
+			assert.Nil(t, err)
+			assert.Equal(t, testCase.expected, decimal.String())
+			assert.Equal(t, testCase.expectedFixed, decimal.StringFixed(2))
+		})
+	}
+}
+
+func Test_WhenDecimalIsMalformed_ShouldNotParse(t *testing.T) {
+	for _, input := range []string{"", "-", ".", "1e3", "NaN", "Inf", "1.23456", "123456789012345", "1,5"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := domain.ParseDecimal(input)
+			assert.ErrorIs(t, err, domain.ErrInvalidDecimal)
+		})
+	}
+}
+
+func Test_ShouldRoundTripDecimalThroughJsonAndSql(t *testing.T) {
+	t.Run("ShouldRoundTripDecimalThroughJsonAndSql", func(t *testing.T) {
+		var fromNumber, fromString domain.Decimal
+		assert.Nil(t, json.Unmarshal([]byte(`19.99`), &fromNumber))
+		assert.Nil(t, json.Unmarshal([]byte(`"19.99"`), &fromString))
+		assert.Equal(t, fromNumber, fromString)
+
+		encoded, _ := json.Marshal(fromNumber)
+		assert.Equal(t, `"19.99"`, string(encoded))
+
+		var scanned domain.Decimal
+		assert.Nil(t, scanned.Scan("19.9900"))
+		assert.Equal(t, 0, scanned.Cmp(fromNumber))
+
+		value, _ := scanned.Value()
+		assert.Equal(t, "19.99", value)
+	})
+}
+
+func Test_ShouldScanNumericTextOfPostgres(t *testing.T) {
+	testCases := []struct {
+		input    interface{}
+		expected string
+	}{
+		{"1999e-2", "19.99"},
+		{"1e3", "1000"},
+		{"-25e-1", "-2.5"},
+		{"5e-3", "0.005"},
+		{"72500000e-7", "7.25"},
+		{[]byte("400099e-2"), "4000.99"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(fmt.Sprint(testCase.input), func(t *testing.T) {
+			var scanned domain.Decimal
+			assert.Nil(t, scanned.Scan(testCase.input))
+			assert.Equal(t, testCase.expected, scanned.String())
+		})
+	}
+
+	t.Run("ShouldScanValueOfPgtypeNumeric", func(t *testing.T) {
+		var numeric pgtype.Numeric
+		assert.Nil(t, numeric.DecodeText(nil, []byte("3000.0000")))
+		numericValue, _ := numeric.Value()
+
+		var scanned domain.Decimal
+		assert.Nil(t, scanned.Scan(numericValue))
+		assert.Equal(t, "3000", scanned.String())
+	})
+
+	for _, input := range []string{"1e-5", "1e15", "1.5e2", "e3", "1e", "NaN"} {
+		t.Run(input, func(t *testing.T) {
+			var scanned domain.Decimal
+			assert.ErrorIs(t, scanned.Scan(input), domain.ErrInvalidDecimal)
+		})
+	}
+}
@@ -1,19 +1,27 @@
 package infrastructure
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"product-app/common/logging"
 	"product-app/common/postgresql"
+	"product-app/common/tracing"
 	"product-app/domain"
 	"product-app/persistence"
+	"product-app/persistence/migration"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/stretchr/testify/assert"
 )
 
 var productRepository persistence.IProductRepository
+var storeRepository persistence.IStoreRepository
 var dbPool *pgxpool.Pool
 var ctx context.Context
 
@@ -21,17 +29,34 @@ var ctx context.Context
 func TestMain(m *testing.M) {
 	ctx = context.Background()
 
-	dbPool = postgresql.GetConnectionPool(ctx, postgresql.Config{
+	var poolErr error
+	dbPool, poolErr = postgresql.NewConnectionPool(postgresql.Config{
 		Host:                  "localhost",
-		Port:                  "6432",
+		Port:                  6432,
 		DbName:                "productapp",
 		UserName:              "postgres",
 		Password:              "123321",
-		MaxConnections:        "10",
-		MaxConnectionIdleTime: "30s",
+		SslMode:               "disable",
+		MaxConnections:        10,
+		MaxConnectionIdleTime: 30 * time.Second,
 	})
+	if poolErr != nil {
+		panic(poolErr)
+	}
+	if err := postgresql.WaitForDatabase(ctx, dbPool, postgresql.DefaultRetryPolicy(10*time.Second), logging.Discard()); err != nil {
+		panic(err)
+	}
+
+	migrator, migratorErr := migration.NewMigrator(dbPool, logging.Discard())
+	if migratorErr != nil {
+		panic(migratorErr)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		panic(err)
+	}
 
-	productRepository = persistence.NewProductRepository(dbPool)
+	productRepository = persistence.NewProductRepository(dbPool, logging.Discard(), nil)
+	storeRepository = persistence.NewStoreRepository(dbPool, logging.Discard(), nil)
 	fmt.Println("Before all tests...")
 	exitCode := m.Run()
 	fmt.Println("After all tests...")
@@ -54,35 +79,48 @@ func TestGetAllProducts(t *testing.T) {
 		{
 			Id:       1,
 			Name:     "AirFryer",
-			Price:    3000.0,
-			Discount: 22.0,
+			Price:    domain.NewDecimal(3000),
+			Discount: domain.NewDecimal(22),
+			Currency: "TRY",
+			StoreId:  1,
 			Store:    "ABC TECH",
+			Version:  1,
 		},
 		{
 			Id:       2,
 			Name:     "Ütü",
-			Price:    1500.0,
-			Discount: 10.0,
+			Price:    domain.NewDecimal(1500),
+			Discount: domain.NewDecimal(10),
+			Currency: "TRY",
+			StoreId:  1,
 			Store:    "ABC TECH",
+			Version:  1,
 		},
 		{
 			Id:       3,
 			Name:     "Çamaşır Makinesi",
-			Price:    10000.0,
-			Discount: 15.0,
+			Price:    domain.NewDecimal(10000),
+			Discount: domain.NewDecimal(15),
+			Currency: "TRY",
+			StoreId:  1,
 			Store:    "ABC TECH",
+			Version:  1,
 		},
 		{
 			Id:       4,
 			Name:     "Lambader",
-			Price:    2000.0,
-			Discount: 0.0,
+			Price:    domain.NewDecimal(2000),
+			Discount: domain.NewDecimal(0),
+			Currency: "TRY",
+			StoreId:  2,
 			Store:    "Dekorasyon Sarayı",
+			Version:  1,
 		},
 	}
 
 	t.Run("GetAllProducts", func(t *testing.T) {
-		actualProducts := productRepository.GetAllProducts()
+		actualProducts, err := productRepository.GetAllProducts(ctx)
+		assert.Nil(t, err)
 		assert.Equal(t, 4, len(actualProducts))
 		assert.Equal(t, expectedProducts, actualProducts)
 	})
@@ -98,28 +136,38 @@ func TestGetAllProductsByStore(t *testing.T) {
 		{
 			Id:       1,
 			Name:     "AirFryer",
-			Price:    3000.0,
-			Discount: 22.0,
+			Price:    domain.NewDecimal(3000),
+			Discount: domain.NewDecimal(22),
+			Currency: "TRY",
+			StoreId:  1,
 			Store:    "ABC TECH",
+			Version:  1,
 		},
 		{
 			Id:       2,
 			Name:     "Ütü",
-			Price:    1500.0,
-			Discount: 10.0,
+			Price:    domain.NewDecimal(1500),
+			Discount: domain.NewDecimal(10),
+			Currency: "TRY",
+			StoreId:  1,
 			Store:    "ABC TECH",
+			Version:  1,
 		},
 		{
 			Id:       3,
 			Name:     "Çamaşır Makinesi",
-			Price:    10000.0,
-			Discount: 15.0,
+			Price:    domain.NewDecimal(10000),
+			Discount: domain.NewDecimal(15),
+			Currency: "TRY",
+			StoreId:  1,
 			Store:    "ABC TECH",
+			Version:  1,
 		},
 	}
 
 	t.Run("GetAllProductsByStore", func(t *testing.T) {
-		actualProducts := productRepository.GetAllProductsByStore("ABC TECH")
+		actualProducts, err := productRepository.GetAllProductsByStore(ctx, "ABC TECH")
+		assert.Nil(t, err)
 		assert.Equal(t, 3, len(actualProducts))
 		assert.Equal(t, expectedProducts, actualProducts)
 	})
@@ -131,24 +179,32 @@ func TestGetAllProductsByStore(t *testing.T) {
 
 // !TestAddProduct
 func TestAddProduct(t *testing.T) {
+	store, _ := storeRepository.EnsureStore(ctx, "Kırtasiye Merkezi")
 	expectedProducts := []domain.Product{
 		{
 			Id:       1,
 			Name:     "Kupa",
-			Price:    100.0,
-			Discount: 0.0,
+			Price:    domain.NewDecimal(100),
+			Discount: domain.NewDecimal(0),
+			Currency: "TRY",
+			StoreId:  store.Id,
 			Store:    "Kırtasiye Merkezi",
+			Version:  1,
 		},
 	}
 	newProduct := domain.Product{
 		Name:     "Kupa",
-		Price:    100.0,
-		Discount: 0.0,
+		Price:    domain.NewDecimal(100),
+		Discount: domain.NewDecimal(0),
+		Currency: "TRY",
+		StoreId:  store.Id,
 		Store:    "Kırtasiye Merkezi",
 	}
 	t.Run("AddProduct", func(t *testing.T) {
-		productRepository.AddProduct(newProduct)
-		actualProducts := productRepository.GetAllProducts()
+		addedProduct, err := productRepository.AddProduct(ctx, newProduct)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedProducts[0], addedProduct)
+		actualProducts, _ := productRepository.GetAllProducts(ctx)
 		assert.Equal(t, 1, len(actualProducts))
 		assert.Equal(t, expectedProducts, actualProducts)
 	})
@@ -159,16 +215,20 @@ func TestAddProduct(t *testing.T) {
 func TestGetProductById(t *testing.T) {
 	setup(ctx, dbPool)
 	t.Run("GetProductById", func(t *testing.T) {
-		actualProduct, _ := productRepository.GetProductById(1)
-		_, err := productRepository.GetProductById(5)
+		actualProduct, _ := productRepository.GetProductById(ctx, 1)
+		_, err := productRepository.GetProductById(ctx, 5)
 		assert.Equal(t, domain.Product{
 			Id:       1,
 			Name:     "AirFryer",
-			Price:    3000.0,
-			Discount: 22.0,
+			Price:    domain.NewDecimal(3000),
+			Discount: domain.NewDecimal(22),
+			Currency: "TRY",
+			StoreId:  1,
 			Store:    "ABC TECH",
+			Version:  1,
 		}, actualProduct)
 		assert.Equal(t, "Product not found with id 5", err.Error())
+		assert.ErrorIs(t, err, domain.ErrNotFound)
 	})
 	clear(ctx, dbPool)
 }
@@ -177,8 +237,8 @@ func TestGetProductById(t *testing.T) {
 func TestDeleteById(t *testing.T) {
 	setup(ctx, dbPool)
 	t.Run("DeleteProductById", func(t *testing.T) {
-		productRepository.DeleteProductById(1)
-		_, err := productRepository.GetProductById(1)
+		productRepository.DeleteProductById(ctx, 1, domain.AnyVersion)
+		_, err := productRepository.GetProductById(ctx, 1)
 		assert.Equal(t, "Product not found with id 1", err.Error())
 	})
 	clear(ctx, dbPool)
@@ -188,11 +248,372 @@ func TestDeleteById(t *testing.T) {
 func TestUpdateProductPrice(t *testing.T) {
 	setup(ctx, dbPool)
 	t.Run("UpdateProductPrice", func(t *testing.T) {
-		productBeforeUpdate, _ := productRepository.GetProductById(1)
-		assert.Equal(t, float32(3000.0), productBeforeUpdate.Price)
-		productRepository.UpdateProductPrice(1, 4000.0)
-		productAfterUpdate, _ := productRepository.GetProductById(1)
-		assert.Equal(t, float32(4000.0), productAfterUpdate.Price)
+		productBeforeUpdate, _ := productRepository.GetProductById(ctx, 1)
+		assert.Equal(t, domain.NewDecimal(3000), productBeforeUpdate.Price)
+		productRepository.UpdateProductPrice(ctx, 1, domain.MustParseDecimal("4000.99"), domain.AnyVersion)
+		productAfterUpdate, _ := productRepository.GetProductById(ctx, 1)
+		assert.Equal(t, "4000.99", productAfterUpdate.Price.String())
+		assert.Equal(t, int64(2), productAfterUpdate.Version)
+	})
+	clear(ctx, dbPool)
+}
+
+// !TestGetProducts
+func TestGetProducts(t *testing.T) {
+	setup(ctx, dbPool)
+	t.Run("GetProducts", func(t *testing.T) {
+		firstPage, err := productRepository.GetProducts(ctx, domain.ProductQuery{Stores: []string{"ABC TECH"}, Limit: 2})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(3), firstPage.Total)
+		assert.Equal(t, 2, len(firstPage.Items))
+		assert.True(t, firstPage.HasMore)
+		assert.Equal(t, int64(2), firstPage.NextCursor)
+
+		secondPage, err := productRepository.GetProducts(ctx, domain.ProductQuery{Stores: []string{"ABC TECH"}, Limit: 2, AfterId: firstPage.NextCursor})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(secondPage.Items))
+		assert.Equal(t, "Çamaşır Makinesi", secondPage.Items[0].Name)
+		assert.False(t, secondPage.HasMore)
+
+		offsetPage, err := productRepository.GetProducts(ctx, domain.ProductQuery{Limit: 2, Offset: 3})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), offsetPage.Total)
+		assert.Equal(t, "Lambader", offsetPage.Items[0].Name)
+	})
+	clear(ctx, dbPool)
+}
+
+// !TestGetProductsWithFilterAndSort
+func TestGetProductsWithFilterAndSort(t *testing.T) {
+	setup(ctx, dbPool)
+	t.Run("GetProductsWithFilterAndSort", func(t *testing.T) {
+		minPrice := domain.NewDecimal(1500)
+		minDiscount := domain.NewDecimal(10)
+		productPage, err := productRepository.GetProducts(ctx, domain.ProductQuery{
+			Stores:      []string{"ABC TECH", "Dekorasyon Sarayı"},
+			MinPrice:    &minPrice,
+			MinDiscount: &minDiscount,
+			Sort:        []domain.SortField{{Field: "discount", Descending: true}},
+			Limit:       10,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(3), productPage.Total)
+		assert.Equal(t, []string{"AirFryer", "Çamaşır Makinesi", "Ütü"}, []string{
+			productPage.Items[0].Name, productPage.Items[1].Name, productPage.Items[2].Name,
+		})
+
+		nameMatches, err := productRepository.GetProducts(ctx, domain.ProductQuery{Name: "LAMBA", Limit: 10})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(nameMatches.Items))
+
+		_, sortErr := productRepository.GetProducts(ctx, domain.ProductQuery{Sort: []domain.SortField{{Field: "price;DROP TABLE product"}}, Limit: 10})
+		assert.ErrorIs(t, sortErr, domain.ErrValidation)
+	})
+	clear(ctx, dbPool)
+}
+
+// !TestStreamProducts
+func TestStreamProducts(t *testing.T) {
+	setup(ctx, dbPool)
+	t.Run("StreamProducts", func(t *testing.T) {
+		var streamedIds []int64
+		err := productRepository.StreamProducts(ctx, domain.ProductQuery{
+			Stores: []string{"ABC TECH"},
+			Sort:   []domain.SortField{{Field: "price", Descending: true}},
+		}, func(product domain.Product) error {
+			streamedIds = append(streamedIds, product.Id)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{3, 1, 2}, streamedIds)
+
+		stopErr := errors.New("client went away")
+		streamed := 0
+		err = productRepository.StreamProducts(ctx, domain.ProductQuery{}, func(product domain.Product) error {
+			streamed++
+			return stopErr
+		})
+		assert.ErrorIs(t, err, stopErr)
+		assert.Equal(t, 1, streamed)
+
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		err = productRepository.StreamProducts(cancelledCtx, domain.ProductQuery{}, func(product domain.Product) error {
+			return nil
+		})
+		assert.NotNil(t, err)
+	})
+	clear(ctx, dbPool)
+}
+
+// !TestUpdateProduct
+func TestUpdateProduct(t *testing.T) {
+	setup(ctx, dbPool)
+	t.Run("UpdateProduct", func(t *testing.T) {
+		updatedProduct, err := productRepository.UpdateProduct(ctx, domain.Product{
+			Id:       1,
+			Name:     "AirFryer XL",
+			Price:    domain.NewDecimal(3500),
+			Discount: domain.NewDecimal(5),
+			Currency: "TRY",
+			StoreId:  1,
+			Store:    "ABC TECH",
+		})
+		assert.Nil(t, err)
+		actualProduct, _ := productRepository.GetProductById(ctx, 1)
+		assert.Equal(t, updatedProduct, actualProduct)
+
+		_, notFoundErr := productRepository.UpdateProduct(ctx, domain.Product{Id: 42, Name: "Kupa", Price: domain.NewDecimal(100), StoreId: 1, Store: "ABC TECH", Currency: "TRY"})
+		assert.ErrorIs(t, notFoundErr, domain.ErrNotFound)
+		assert.ErrorIs(t, productRepository.UpdateProductPrice(ctx, 42, domain.NewDecimal(100), domain.AnyVersion), domain.ErrNotFound)
+	})
+	clear(ctx, dbPool)
+}
+
+// !TestVersionedWrites
+func TestVersionedWrites(t *testing.T) {
+	setup(ctx, dbPool)
+	t.Run("VersionedWrites", func(t *testing.T) {
+		product, _ := productRepository.GetProductById(ctx, 1)
+		assert.Equal(t, int64(1), product.Version)
+
+		product.Price = domain.NewDecimal(3100)
+		updatedProduct, err := productRepository.UpdateProduct(ctx, product)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), updatedProduct.Version)
+
+		_, staleErr := productRepository.UpdateProduct(ctx, product)
+		assert.ErrorIs(t, staleErr, domain.ErrVersionMismatch)
+		assert.Equal(t, "Product 1 is at version 2, not 1", staleErr.Error())
+		assert.ErrorIs(t, productRepository.UpdateProductPrice(ctx, 1, domain.NewDecimal(3200), 1), domain.ErrVersionMismatch)
+		assert.ErrorIs(t, productRepository.DeleteProductById(ctx, 1, 1), domain.ErrVersionMismatch)
+		assert.ErrorIs(t, productRepository.DeleteProductById(ctx, 42, 1), domain.ErrNotFound)
+
+		assert.Nil(t, productRepository.DeleteProductById(ctx, 1, 2))
+		_, getErr := productRepository.GetProductById(ctx, 1)
+		assert.ErrorIs(t, getErr, domain.ErrNotFound)
+	})
+	clear(ctx, dbPool)
+}
+
+// !TestSoftDelete
+func TestSoftDelete(t *testing.T) {
+	setup(ctx, dbPool)
+	t.Run("SoftDelete", func(t *testing.T) {
+		assert.Nil(t, productRepository.DeleteProductById(ctx, 1, domain.AnyVersion))
+		assert.ErrorIs(t, productRepository.DeleteProductById(ctx, 1, domain.AnyVersion), domain.ErrNotFound)
+		_, updateErr := productRepository.UpdateProduct(ctx, domain.Product{Id: 1, Name: "Kupa", Price: domain.NewDecimal(100), StoreId: 1, Store: "ABC TECH", Currency: "TRY"})
+		assert.ErrorIs(t, updateErr, domain.ErrNotFound)
+
+		activeProducts, _ := productRepository.GetAllProducts(ctx)
+		assert.Equal(t, 3, len(activeProducts))
+		storeProducts, _ := productRepository.GetAllProductsByStore(ctx, "ABC TECH")
+		assert.Equal(t, 2, len(storeProducts))
+		productPage, _ := productRepository.GetProducts(ctx, domain.ProductQuery{Limit: 10})
+		assert.Equal(t, int64(3), productPage.Total)
+
+		deletedProducts, err := productRepository.GetDeletedProducts(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(deletedProducts))
+		assert.NotNil(t, deletedProducts[0].DeletedAt)
+		assert.Equal(t, int64(2), deletedProducts[0].Version)
+
+		_, staleErr := productRepository.RestoreProductById(ctx, 1, 1)
+		assert.ErrorIs(t, staleErr, domain.ErrVersionMismatch)
+		restoredProduct, err := productRepository.RestoreProductById(ctx, 1, 2)
+		assert.Nil(t, err)
+		assert.Nil(t, restoredProduct.DeletedAt)
+		assert.Equal(t, int64(3), restoredProduct.Version)
+		_, restoreErr := productRepository.RestoreProductById(ctx, 1, domain.AnyVersion)
+		assert.ErrorIs(t, restoreErr, domain.ErrNotFound)
+
+		assert.Nil(t, productRepository.DeleteProductById(ctx, 2, domain.AnyVersion))
+		purged, err := productRepository.PurgeDeletedProducts(ctx, time.Now().Add(-time.Hour))
+		assert.Nil(t, err)
+		assert.Empty(t, purged)
+		purged, err = productRepository.PurgeDeletedProducts(ctx, time.Now().Add(time.Minute))
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(purged))
+		assert.Equal(t, int64(2), purged[0].Id)
+		deletedProducts, _ = productRepository.GetDeletedProducts(ctx)
+		assert.Equal(t, 0, len(deletedProducts))
+	})
+	clear(ctx, dbPool)
+}
+
+// !TestAuditLog
+func TestAuditLog(t *testing.T) {
+	setup(ctx, dbPool)
+	auditRepository := persistence.NewAuditRepository(dbPool, logging.Discard(), nil)
+	transactor := persistence.NewTransactor(dbPool, logging.Discard())
+	t.Run("AuditLog", func(t *testing.T) {
+		commitErr := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			product, getErr := productRepository.GetProductById(ctx, 1)
+			if getErr != nil {
+				return getErr
+			}
+			if updateErr := productRepository.UpdateProductPrice(ctx, 1, domain.NewDecimal(3100), product.Version); updateErr != nil {
+				return updateErr
+			}
+			updatedProduct := product
+			updatedProduct.Price = domain.NewDecimal(3100)
+			updatedProduct.Version++
+			auditRecord := domain.NewAuditRecord(domain.AuditPriceChange, &product, &updatedProduct)
+			auditRecord.Actor = "alice"
+			_, addErr := auditRepository.AddAuditRecord(ctx, auditRecord)
+			return addErr
+		})
+		assert.Nil(t, commitErr)
+
+		auditPage, err := auditRepository.GetAuditRecords(ctx, domain.AuditQuery{ProductId: 1, Limit: 10})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(auditPage.Items))
+		assert.Equal(t, "alice", auditPage.Items[0].Actor)
+		assert.Equal(t, "3000", auditPage.Items[0].Before.Price.String())
+		assert.Equal(t, "3100", auditPage.Items[0].After.Price.String())
+		assert.Equal(t, []domain.FieldChange{{Field: "price", Before: pointerTo("3000"), After: pointerTo("3100")}}, auditPage.Items[0].Changes)
+
+		errAborted := errors.New("aborted")
+		rollbackErr := transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			if deleteErr := productRepository.DeleteProductById(ctx, 1, domain.AnyVersion); deleteErr != nil {
+				return deleteErr
+			}
+			if _, addErr := auditRepository.AddAuditRecord(ctx, domain.AuditRecord{ProductId: 1, Operation: domain.AuditDelete, Actor: "alice"}); addErr != nil {
+				return addErr
+			}
+			return errAborted
+		})
+		assert.ErrorIs(t, rollbackErr, errAborted)
+		product, getErr := productRepository.GetProductById(ctx, 1)
+		assert.Nil(t, getErr)
+		assert.Equal(t, int64(2), product.Version)
+
+		auditPage, _ = auditRepository.GetAuditRecords(ctx, domain.AuditQuery{Operation: domain.AuditDelete, Limit: 10})
+		assert.Empty(t, auditPage.Items)
+	})
+	clear(ctx, dbPool)
+}
+
+// !TestPriceHistory
+func TestPriceHistory(t *testing.T) {
+	setup(ctx, dbPool)
+	priceHistoryRepository := persistence.NewPriceHistoryRepository(dbPool, logging.Discard(), nil)
+	t.Run("PriceHistory", func(t *testing.T) {
+		_, insertErr := dbPool.Exec(ctx, `INSERT INTO product_price_history (product_id,price,discount,currency,recorded_at) VALUES
+			(1,3000,22,'TRY','2026-03-02T09:00:00Z'),
+			(1,2800,22,'TRY','2026-03-02T18:00:00Z'),
+			(1,2900,10,'TRY','2026-03-04T12:00:00Z'),
+			(1,3100,0,'TRY','2026-03-09T08:00:00Z'),
+			(2,1000,0,'TRY','2026-03-02T10:00:00Z')`)
+		assert.Nil(t, insertErr)
+
+		from := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+		pricePoints, err := priceHistoryRepository.GetPricePoints(ctx, domain.PriceHistoryQuery{ProductId: 1, From: &from})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(pricePoints))
+		assert.Equal(t, "2800", pricePoints[0].Price.String())
+
+		dailyBuckets, err := priceHistoryRepository.GetPriceBuckets(ctx, domain.PriceHistoryQuery{ProductId: 1, Bucket: domain.PriceBucketDay})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(dailyBuckets))
+		assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC), dailyBuckets[0].Start)
+		assert.Equal(t, "2800", dailyBuckets[0].MinPrice.String())
+		assert.Equal(t, "3000", dailyBuckets[0].MaxPrice.String())
+		assert.Equal(t, "2800", dailyBuckets[0].LastPrice.String())
+
+		weeklyBuckets, err := priceHistoryRepository.GetPriceBuckets(ctx, domain.PriceHistoryQuery{ProductId: 1, Bucket: domain.PriceBucketWeek})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(weeklyBuckets))
+		assert.Equal(t, "2900", weeklyBuckets[0].LastPrice.String())
+		assert.Equal(t, "10", weeklyBuckets[0].MinDiscount.String())
+		assert.Equal(t, "3100", weeklyBuckets[1].LastPrice.String())
+
+		_, insertErr = dbPool.Exec(ctx, `INSERT INTO product_price_history (product_id,price,discount,currency,recorded_at) VALUES
+			(1,100,0,'USD','2026-03-09T20:00:00Z')`)
+		assert.Nil(t, insertErr)
+		weeklyBuckets, err = priceHistoryRepository.GetPriceBuckets(ctx, domain.PriceHistoryQuery{ProductId: 1, Bucket: domain.PriceBucketWeek})
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(weeklyBuckets))
+		assert.Equal(t, "TRY", weeklyBuckets[1].Currency)
+		assert.Equal(t, "3100", weeklyBuckets[1].MaxPrice.String())
+		assert.Equal(t, "USD", weeklyBuckets[2].Currency)
+		assert.Equal(t, "100", weeklyBuckets[2].MaxPrice.String())
+
+		assert.Nil(t, priceHistoryRepository.AddPricePoint(ctx, domain.Product{Id: 2, Price: domain.NewDecimal(900), Currency: "TRY"}))
+		pricePoints, _ = priceHistoryRepository.GetPricePoints(ctx, domain.PriceHistoryQuery{ProductId: 2})
+		assert.Equal(t, 2, len(pricePoints))
+	})
+	clear(ctx, dbPool)
+}
+
+// !TestAddProducts
+func TestAddProducts(t *testing.T) {
+	setup(ctx, dbPool)
+	auditRepository := persistence.NewAuditRepository(dbPool, logging.Discard(), nil)
+	priceHistoryRepository := persistence.NewPriceHistoryRepository(dbPool, logging.Discard(), nil)
+	t.Run("AddProducts", func(t *testing.T) {
+		smallBatch := []domain.Product{
+			{Name: "Kupa", Price: domain.MustParseDecimal("19.99"), Discount: domain.MustParseDecimal("12.5"), StoreId: 3, Store: "Kırtasiye Merkezi", Currency: "USD"},
+			{Name: "Tabak", Price: domain.NewDecimal(50), StoreId: 3, Store: "Kırtasiye Merkezi", Currency: "TRY"},
+		}
+		insertedProducts, err := productRepository.AddProducts(ctx, smallBatch)
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{5, 6}, []int64{insertedProducts[0].Id, insertedProducts[1].Id})
+
+		var largeBatch []domain.Product
+		for index := 0; index < 250; index++ {
+			largeBatch = append(largeBatch, domain.Product{Name: fmt.Sprintf("Kalem %d", index), Price: domain.MustParseDecimal("7.25"),
+				Discount: domain.NewDecimal(5), StoreId: 3, Store: "Kırtasiye Merkezi", Currency: "TRY"})
+		}
+		copiedProducts, err := productRepository.AddProducts(ctx, largeBatch)
+		assert.Nil(t, err)
+		assert.Equal(t, 250, len(copiedProducts))
+		assert.Equal(t, int64(7), copiedProducts[0].Id)
+
+		copiedProduct, err := productRepository.GetProductById(ctx, copiedProducts[249].Id)
+		assert.Nil(t, err)
+		assert.Equal(t, copiedProducts[249], copiedProduct)
+		assert.Equal(t, "7.25", copiedProduct.Price.String())
+
+		auditRecords := []domain.AuditRecord{domain.NewAuditRecord(domain.AuditCreate, nil, &copiedProducts[0])}
+		auditRecords[0].Actor = "importer"
+		assert.Nil(t, auditRepository.AddAuditRecords(ctx, auditRecords))
+		auditPage, _ := auditRepository.GetAuditRecords(ctx, domain.AuditQuery{ProductId: copiedProducts[0].Id, Limit: 10})
+		assert.Equal(t, 1, len(auditPage.Items))
+		assert.Nil(t, auditPage.Items[0].Before)
+		assert.Equal(t, "Kalem 0", auditPage.Items[0].After.Name)
+
+		assert.Nil(t, priceHistoryRepository.AddPricePoints(ctx, copiedProducts))
+		pricePoints, _ := priceHistoryRepository.GetPricePoints(ctx, domain.PriceHistoryQuery{ProductId: copiedProducts[0].Id})
+		assert.Equal(t, 1, len(pricePoints))
+		assert.Equal(t, "5", pricePoints[0].Discount.String())
+	})
+	clear(ctx, dbPool)
+}
+
+// *pointerTo
+func pointerTo[T any](value T) *T {
+	return &value
+}
+
+// !TestTracedStatements
+func TestTracedStatements(t *testing.T) {
+	setup(ctx, dbPool)
+	t.Run("TracedStatements", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		tracedRepository := persistence.NewProductRepository(dbPool, logging.Discard(), tracing.NewTracer(tracing.NewWriterExporter(output)))
+
+		_, err := tracedRepository.GetAllProductsByStore(ctx, "ABC TECH")
+		assert.Nil(t, err)
+
+		var span map[string]interface{}
+		assert.Nil(t, json.Unmarshal(output.Bytes(), &span))
+		assert.Equal(t, "SELECT", span["name"])
+		assert.Equal(t, "client", span["kind"])
+		attributes := span["attributes"].(map[string]interface{})
+		assert.Equal(t, "SELECT product.id,product.name,product.price,product.discount,product.store_id,store.name,product.currency,product.version,product.deleted_at"+
+			" FROM product JOIN store ON store.id=product.store_id WHERE lower(store.name)=lower($1) AND product.deleted_at IS NULL", attributes["db.statement"])
+		assert.Equal(t, float64(3), attributes["db.rows"])
 	})
 	clear(ctx, dbPool)
 }
@@ -0,0 +1,70 @@
+package infrastructure
+
+import (
+	"testing"
+
+	"product-app/domain"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// !TestStores
+func TestStores(t *testing.T) {
+	setup(ctx, dbPool)
+	t.Run("Stores", func(t *testing.T) {
+		stores, err := storeRepository.GetAllStores(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"ABC TECH", "Dekorasyon Sarayı", "Kırtasiye Merkezi"}, []string{stores[0].Name, stores[1].Name, stores[2].Name})
+
+		existingStore, err := storeRepository.EnsureStore(ctx, "abc tech")
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), existingStore.Id)
+		assert.Equal(t, "ABC TECH", existingStore.Name)
+		_, conflictErr := storeRepository.AddStore(ctx, "Abc Tech")
+		assert.ErrorIs(t, conflictErr, domain.ErrConflict)
+
+		renamedStore, err := storeRepository.RenameStore(ctx, 1, "ABC Teknoloji")
+		assert.Nil(t, err)
+		assert.Equal(t, "ABC Teknoloji", renamedStore.Name)
+		product, _ := productRepository.GetProductById(ctx, 1)
+		assert.Equal(t, "ABC Teknoloji", product.Store)
+		bumpedProducts, bumpErr := productRepository.BumpProductsOfStore(ctx, renamedStore)
+		assert.Nil(t, bumpErr)
+		assert.Equal(t, []int64{1, 2, 3}, []int64{bumpedProducts[0].Id, bumpedProducts[1].Id, bumpedProducts[2].Id})
+		assert.Equal(t, int64(2), bumpedProducts[0].Version)
+		assert.Equal(t, "ABC Teknoloji", bumpedProducts[0].Store)
+		_, renameConflictErr := storeRepository.RenameStore(ctx, 2, "abc teknoloji")
+		assert.ErrorIs(t, renameConflictErr, domain.ErrConflict)
+		_, renameNotFoundErr := storeRepository.RenameStore(ctx, 42, "Kupa")
+		assert.ErrorIs(t, renameNotFoundErr, domain.ErrNotFound)
+
+		assert.ErrorIs(t, storeRepository.DeleteStoreById(ctx, 2), domain.ErrConflict)
+		assert.Nil(t, storeRepository.DeleteStoreById(ctx, 3))
+		assert.ErrorIs(t, storeRepository.DeleteStoreById(ctx, 3), domain.ErrNotFound)
+		_, getErr := storeRepository.GetStoreById(ctx, 3)
+		assert.ErrorIs(t, getErr, domain.ErrNotFound)
+	})
+	clear(ctx, dbPool)
+}
+
+// !TestGetProductsOfStore
+func TestGetProductsOfStore(t *testing.T) {
+	setup(ctx, dbPool)
+	t.Run("GetProductsOfStore", func(t *testing.T) {
+		storePage, err := productRepository.GetProducts(ctx, domain.ProductQuery{StoreId: 2, Limit: 10})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), storePage.Total)
+		assert.Equal(t, "Lambader", storePage.Items[0].Name)
+
+		namePage, err := productRepository.GetProducts(ctx, domain.ProductQuery{Stores: []string{" abc  tech", "DEKORASYON SARAYı"}, Limit: 10,
+			Sort: []domain.SortField{{Field: "store", Descending: true}}})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), namePage.Total)
+		assert.Equal(t, "Dekorasyon Sarayı", namePage.Items[0].Store)
+
+		storeProducts, err := productRepository.GetAllProductsByStore(ctx, "abc tech ")
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(storeProducts))
+	})
+	clear(ctx, dbPool)
+}
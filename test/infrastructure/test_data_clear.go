@@ -1,16 +1,17 @@
 package infrastructure
 
 import (
+	"context"
+	"log/slog"
+
 	"github.com/jackc/pgx/v4/pgxpool"
-	"github.com/labstack/gommon/log"
-	"golang.org/x/net/context"
 )
 
 func TruncateTestData(ctx context.Context, dbPool *pgxpool.Pool) {
-	_, truncateResultErr := dbPool.Exec(ctx, "TRUNCATE product RESTART IDENTITY")
+	_, truncateResultErr := dbPool.Exec(ctx, "TRUNCATE product, store, product_audit, product_price_history RESTART IDENTITY")
 	if truncateResultErr != nil {
-		log.Error("Not product exists on database")
+		slog.Error("Unable to truncate products", "error", truncateResultErr)
 	} else {
-		log.Info("Products table truncated")
+		slog.Info("Products table truncated")
 	}
 }
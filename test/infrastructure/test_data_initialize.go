@@ -2,25 +2,32 @@ package infrastructure
 
 import (
 	"context"
-	"fmt"
-
-	"github.com/labstack/gommon/log"
+	"log/slog"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 )
 
-var INSERT_PRODUCTS = `INSERT INTO product (name, price, discount,store) 
-VALUES('AirFryer',3000.0, 22.0, 'ABC TECH'),
-('Ütü',1500.0, 10.0, 'ABC TECH'),
-('Çamaşır Makinesi',10000.0, 15.0, 'ABC TECH'),
-('Lambader',2000.0, 0.0, 'Dekorasyon Sarayı');
+var INSERT_STORES = `INSERT INTO store (name)
+VALUES('ABC TECH'),
+('Dekorasyon Sarayı'),
+('Kırtasiye Merkezi');
+`
+
+var INSERT_PRODUCTS = `INSERT INTO product (name, price, discount,store_id) 
+VALUES('AirFryer',3000.0, 22.0, 1),
+('Ütü',1500.0, 10.0, 1),
+('Çamaşır Makinesi',10000.0, 15.0, 1),
+('Lambader',2000.0, 0.0, 2);
 `
 
 func TestDataInitialize(ctx context.Context, dbPool *pgxpool.Pool) {
+	if _, insertStoresErr := dbPool.Exec(ctx, INSERT_STORES); insertStoresErr != nil {
+		slog.Error("Unable to insert test stores", "error", insertStoresErr)
+	}
 	insertProductsResult, insertProductsErr := dbPool.Exec(ctx, INSERT_PRODUCTS)
 	if insertProductsErr != nil {
-		log.Error("InsertProductErr")
+		slog.Error("Unable to insert test products", "error", insertProductsErr)
 	} else {
-		log.Info(fmt.Sprintf("Products data created with %d rows,how many rows affected...", insertProductsResult.RowsAffected()))
+		slog.Info("Test products created", "rows", insertProductsResult.RowsAffected())
 	}
 }
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"bytes"
+	"product-app/common/metrics"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// *render
+func render(t *testing.T, registry *metrics.Registry) string {
+	output := &bytes.Buffer{}
+	assert.Nil(t, registry.Write(output))
+	return output.String()
+}
+
+func Test_ShouldWriteCountersInTextFormat(t *testing.T) {
+	t.Run("ShouldWriteCountersInTextFormat", func(t *testing.T) {
+		registry := metrics.NewRegistry()
+		requests := registry.NewCounterVec("requests_total", "Requests served.", "route", "status_class")
+		requests.Inc("/b", "2xx")
+		requests.Inc("/a", "2xx")
+		requests.Add(2, "/a", "2xx")
+		requests.Inc("/a", "5xx")
+
+		assert.Equal(t, `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{route="/a",status_class="2xx"} 3
+requests_total{route="/a",status_class="5xx"} 1
+requests_total{route="/b",status_class="2xx"} 1
+`, render(t, registry))
+	})
+	t.Run("ShouldEscapeLabelValues", func(t *testing.T) {
+		registry := metrics.NewRegistry()
+		registry.NewCounterVec("errors_total", "Errors.", "message").Inc("say \"hi\"\\\n")
+		assert.Contains(t, render(t, registry), `errors_total{message="say \"hi\"\\\n"} 1`)
+	})
+}
+
+func Test_ShouldWriteHistogramsWithCumulativeBuckets(t *testing.T) {
+	t.Run("ShouldWriteHistogramsWithCumulativeBuckets", func(t *testing.T) {
+		registry := metrics.NewRegistry()
+		duration := registry.NewHistogramVec("duration_seconds", "Durations.", []float64{0.1, 1}, "method")
+		duration.Observe(0.05, "Get")
+		duration.Observe(0.5, "Get")
+		duration.Observe(3, "Get")
+
+		assert.Equal(t, `# HELP duration_seconds Durations.
+# TYPE duration_seconds histogram
+duration_seconds_bucket{method="Get",le="0.1"} 1
+duration_seconds_bucket{method="Get",le="1"} 2
+duration_seconds_bucket{method="Get",le="+Inf"} 3
+duration_seconds_sum{method="Get"} 3.55
+duration_seconds_count{method="Get"} 3
+`, render(t, registry))
+	})
+}
+
+func Test_ShouldReadFuncMetricsAtScrapeTime(t *testing.T) {
+	t.Run("ShouldReadFuncMetricsAtScrapeTime", func(t *testing.T) {
+		registry := metrics.NewRegistry()
+		idle := 3
+		registry.NewGaugeFunc("idle_connections", "Idle connections.", func() float64 { return float64(idle) })
+		assert.Contains(t, render(t, registry), "idle_connections 3\n")
+		idle = 1
+		assert.Contains(t, render(t, registry), "# TYPE idle_connections gauge\nidle_connections 1\n")
+	})
+	t.Run("WhenNameIsRegisteredTwice_ShouldPanic", func(t *testing.T) {
+		registry := metrics.NewRegistry()
+		registry.NewGaugeFunc("idle_connections", "Idle connections.", func() float64 { return 0 })
+		assert.Panics(t, func() {
+			registry.NewCounterVec("idle_connections", "Idle connections.")
+		})
+	})
+}
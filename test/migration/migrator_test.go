@@ -0,0 +1,55 @@
+package migration
+
+import (
+	"product-app/common/logging"
+	"product-app/persistence/migration"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ShouldLoadEmbeddedMigrations(t *testing.T) {
+	t.Run("ShouldLoadEmbeddedMigrations", func(t *testing.T) {
+		_, err := migration.NewMigrator(nil, logging.Discard())
+		assert.Nil(t, err)
+	})
+}
+
+func Test_ShouldLoadMigrationsInVersionOrder(t *testing.T) {
+	t.Run("ShouldLoadMigrationsInVersionOrder", func(t *testing.T) {
+		migrations, err := migration.Load(fstest.MapFS{
+			"sql/0010_add_index.up.sql":      {Data: []byte("CREATE INDEX")},
+			"sql/0010_add_index.down.sql":    {Data: []byte("DROP INDEX")},
+			"sql/0002_add_column.up.sql":     {Data: []byte("ALTER TABLE ADD")},
+			"sql/0002_add_column.down.sql":   {Data: []byte("ALTER TABLE DROP")},
+			"sql/0001_create_table.up.sql":   {Data: []byte("CREATE TABLE")},
+			"sql/0001_create_table.down.sql": {Data: []byte("DROP TABLE")},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []migration.Migration{
+			{Version: 1, Name: "create_table", UpSql: "CREATE TABLE", DownSql: "DROP TABLE"},
+			{Version: 2, Name: "add_column", UpSql: "ALTER TABLE ADD", DownSql: "ALTER TABLE DROP"},
+			{Version: 10, Name: "add_index", UpSql: "CREATE INDEX", DownSql: "DROP INDEX"},
+		}, migrations)
+	})
+	t.Run("WhenDownFileIsMissing_ShouldFail", func(t *testing.T) {
+		_, err := migration.Load(fstest.MapFS{
+			"sql/0001_create_table.up.sql": {Data: []byte("CREATE TABLE")},
+		})
+		assert.EqualError(t, err, "Migration 1_create_table needs both an up and a down file")
+	})
+	t.Run("WhenFileNameHasNoVersion_ShouldFail", func(t *testing.T) {
+		_, err := migration.Load(fstest.MapFS{
+			"sql/create_table.up.sql": {Data: []byte("CREATE TABLE")},
+		})
+		assert.EqualError(t, err, "Migration create_table.up.sql must start with a positive version number")
+	})
+	t.Run("WhenVersionIsUsedTwice_ShouldFail", func(t *testing.T) {
+		_, err := migration.Load(fstest.MapFS{
+			"sql/0001_create_table.up.sql": {Data: []byte("CREATE TABLE")},
+			"sql/0001_other_table.up.sql":  {Data: []byte("CREATE TABLE")},
+		})
+		assert.ErrorContains(t, err, "Migration version 1 is used by both")
+	})
+}
@@ -0,0 +1,93 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"product-app/common/logging"
+	"product-app/common/postgresql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errRefused = errors.New("connection refused")
+
+// *fastRetryPolicy keeps the tests quick while still exercising the backoff
+func fastRetryPolicy(budget time.Duration) postgresql.RetryPolicy {
+	return postgresql.RetryPolicy{
+		Budget:         budget,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     4 * time.Millisecond,
+	}
+}
+
+func Test_ShouldRetryUntilOperationSucceeds(t *testing.T) {
+	t.Run("ShouldRetryUntilOperationSucceeds", func(t *testing.T) {
+		var attempts []int
+		err := postgresql.Retry(context.Background(), fastRetryPolicy(time.Second), func(ctx context.Context, attempt int) error {
+			attempts = append(attempts, attempt)
+			if attempt < 4 {
+				return errRefused
+			}
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []int{1, 2, 3, 4}, attempts)
+	})
+}
+
+func Test_WhenBudgetIsSpent_ShouldReturnLastError(t *testing.T) {
+	t.Run("WhenBudgetIsSpent_ShouldReturnLastError", func(t *testing.T) {
+		attempts := 0
+		err := postgresql.Retry(context.Background(), fastRetryPolicy(30*time.Millisecond), func(ctx context.Context, attempt int) error {
+			attempts = attempt
+			return errRefused
+		})
+		assert.ErrorIs(t, err, errRefused)
+		assert.Greater(t, attempts, 1)
+	})
+	t.Run("WhenBudgetIsZero_ShouldTryOnce", func(t *testing.T) {
+		attempts := 0
+		err := postgresql.Retry(context.Background(), fastRetryPolicy(0), func(ctx context.Context, attempt int) error {
+			attempts = attempt
+			return errRefused
+		})
+		assert.ErrorContains(t, err, "Giving up after 1 attempt(s)")
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func Test_WhenContextIsCancelled_ShouldStopRetrying(t *testing.T) {
+	t.Run("WhenContextIsCancelled_ShouldStopRetrying", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := postgresql.Retry(ctx, fastRetryPolicy(time.Hour), func(ctx context.Context, attempt int) error {
+			if attempt == 2 {
+				cancel()
+			}
+			return errRefused
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorIs(t, err, errRefused)
+	})
+}
+
+func Test_WhenDatabaseIsUnreachable_ShouldReturnErrorInsteadOfPanicking(t *testing.T) {
+	t.Run("WhenDatabaseIsUnreachable_ShouldReturnErrorInsteadOfPanicking", func(t *testing.T) {
+		dbPool, err := postgresql.NewConnectionPool(postgresql.Config{
+			Host:                  "127.0.0.1",
+			Port:                  1,
+			DbName:                "productapp",
+			UserName:              "postgres",
+			SslMode:               "disable",
+			ConnectTimeout:        time.Second,
+			MaxConnections:        1,
+			MaxConnectionIdleTime: time.Second,
+		})
+		assert.Nil(t, err)
+		defer dbPool.Close()
+
+		err = postgresql.WaitForDatabase(context.Background(), dbPool, fastRetryPolicy(0), logging.Discard())
+		assert.ErrorContains(t, err, "Giving up after 1 attempt(s)")
+	})
+}
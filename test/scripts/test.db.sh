@@ -37,18 +37,7 @@ sleep 3
 echo "Database for productapp created"
 
 
-#!Create table for created database
-winpty docker exec -it postgres-test psql -U postgres -d productapp -c "
-    CREATE TABLE IF NOT EXISTS product(
-        id bigserial not null primary key,
-        name varchar(255) not null,
-        price double precision not null,
-        discount double precision,
-        store varchar(255) not null
-    );
-"
-sleep 3
-echo "Product table created successfully"
+#!Tables are created by the embedded migrations, either when the app starts or with "go run . migrate up"
 
 # docker exec -it postgres-test psql -U postgres -d productapp -c "SELECT * FROM product"
 # docker exec -it postgres-test psql -U postgres -d productapp -c "TRUNCATE product RESTART IDENTITY"
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"product-app/domain"
+	"product-app/persistence"
+	"time"
+)
+
+type FakeAuditRepository struct {
+	auditRecords []domain.AuditRecord
+}
+
+func NewFakeAuditRepository() *FakeAuditRepository {
+	return &FakeAuditRepository{}
+}
+
+// !AddAuditRecord
+func (fakeRepository *FakeAuditRepository) AddAuditRecord(ctx context.Context, auditRecord domain.AuditRecord) (domain.AuditRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.AuditRecord{}, err
+	}
+	auditRecord.Id = int64(len(fakeRepository.auditRecords) + 1)
+	auditRecord.CreatedAt = time.Now()
+	fakeRepository.auditRecords = append(fakeRepository.auditRecords, auditRecord)
+	return auditRecord, nil
+}
+
+// !AddAuditRecords
+func (fakeRepository *FakeAuditRepository) AddAuditRecords(ctx context.Context, auditRecords []domain.AuditRecord) error {
+	for _, auditRecord := range auditRecords {
+		if _, err := fakeRepository.AddAuditRecord(ctx, auditRecord); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// !GetAuditRecords
+func (fakeRepository *FakeAuditRepository) GetAuditRecords(ctx context.Context, query domain.AuditQuery) (domain.AuditPage, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.AuditPage{}, err
+	}
+	rows := []domain.AuditRecord{}
+	for index := len(fakeRepository.auditRecords) - 1; index >= 0 && len(rows) <= query.Limit; index-- {
+		auditRecord := fakeRepository.auditRecords[index]
+		if matchesAuditQuery(auditRecord, query) {
+			rows = append(rows, auditRecord)
+		}
+	}
+	return domain.NewAuditPage(rows, query), nil
+}
+
+// ?matchesAuditQuery mirrors the filters of the real repository
+func matchesAuditQuery(auditRecord domain.AuditRecord, query domain.AuditQuery) bool {
+	return (query.ProductId == 0 || auditRecord.ProductId == query.ProductId) &&
+		(len(query.Actor) == 0 || auditRecord.Actor == query.Actor) &&
+		(len(query.Operation) == 0 || auditRecord.Operation == query.Operation) &&
+		(query.From == nil || !auditRecord.CreatedAt.Before(*query.From)) &&
+		(query.To == nil || auditRecord.CreatedAt.Before(*query.To)) &&
+		(query.BeforeId == 0 || auditRecord.Id < query.BeforeId)
+}
+
+// FakeTransactor has nothing to roll back, fn runs directly
+type FakeTransactor struct{}
+
+func NewFakeTransactor() persistence.ITransactor {
+	return FakeTransactor{}
+}
+
+// !WithinTransaction
+func (FakeTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
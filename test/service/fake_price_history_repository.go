@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"product-app/domain"
+	"time"
+)
+
+type FakePriceHistoryRepository struct {
+	productIds  []int64
+	pricePoints []domain.PricePoint
+}
+
+func NewFakePriceHistoryRepository() *FakePriceHistoryRepository {
+	return &FakePriceHistoryRepository{}
+}
+
+// !AddPricePoint
+func (fakeRepository *FakePriceHistoryRepository) AddPricePoint(ctx context.Context, product domain.Product) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fakeRepository.productIds = append(fakeRepository.productIds, product.Id)
+	fakeRepository.pricePoints = append(fakeRepository.pricePoints, domain.PricePoint{
+		Price:      product.Price,
+		Discount:   product.Discount,
+		Currency:   product.Currency,
+		RecordedAt: time.Now(),
+	})
+	return nil
+}
+
+// !AddPricePoints
+func (fakeRepository *FakePriceHistoryRepository) AddPricePoints(ctx context.Context, products []domain.Product) error {
+	for _, product := range products {
+		if err := fakeRepository.AddPricePoint(ctx, product); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// !GetPricePoints
+func (fakeRepository *FakePriceHistoryRepository) GetPricePoints(ctx context.Context, query domain.PriceHistoryQuery) ([]domain.PricePoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	pricePoints := []domain.PricePoint{}
+	for index, pricePoint := range fakeRepository.pricePoints {
+		if fakeRepository.productIds[index] == query.ProductId &&
+			(query.From == nil || !pricePoint.RecordedAt.Before(*query.From)) &&
+			(query.To == nil || pricePoint.RecordedAt.Before(*query.To)) {
+			pricePoints = append(pricePoints, pricePoint)
+		}
+	}
+	return pricePoints, nil
+}
+
+// GetPriceBuckets mirrors the grouping of the real repository, by UTC day or by week starting on Monday
+func (fakeRepository *FakePriceHistoryRepository) GetPriceBuckets(ctx context.Context, query domain.PriceHistoryQuery) ([]domain.PriceBucket, error) {
+	pricePoints, err := fakeRepository.GetPricePoints(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	priceBuckets := []domain.PriceBucket{}
+	for _, pricePoint := range pricePoints {
+		start := bucketStart(pricePoint.RecordedAt, query.Bucket)
+		bucketIndex := -1
+		for index := len(priceBuckets) - 1; index >= 0 && priceBuckets[index].Start.Equal(start); index-- {
+			if priceBuckets[index].Currency == pricePoint.Currency {
+				bucketIndex = index
+			}
+		}
+		if bucketIndex < 0 {
+			priceBuckets = append(priceBuckets, domain.PriceBucket{
+				Start:       start,
+				MinPrice:    pricePoint.Price,
+				MaxPrice:    pricePoint.Price,
+				MinDiscount: pricePoint.Discount,
+				MaxDiscount: pricePoint.Discount,
+				Currency:    pricePoint.Currency,
+			})
+			bucketIndex = len(priceBuckets) - 1
+		}
+		priceBucket := &priceBuckets[bucketIndex]
+		if pricePoint.Price.Cmp(priceBucket.MinPrice) < 0 {
+			priceBucket.MinPrice = pricePoint.Price
+		}
+		if pricePoint.Price.Cmp(priceBucket.MaxPrice) > 0 {
+			priceBucket.MaxPrice = pricePoint.Price
+		}
+		if pricePoint.Discount.Cmp(priceBucket.MinDiscount) < 0 {
+			priceBucket.MinDiscount = pricePoint.Discount
+		}
+		if pricePoint.Discount.Cmp(priceBucket.MaxDiscount) > 0 {
+			priceBucket.MaxDiscount = pricePoint.Discount
+		}
+		priceBucket.LastPrice = pricePoint.Price
+		priceBucket.LastDiscount = pricePoint.Discount
+	}
+	return priceBuckets, nil
+}
+
+// ?bucketStart
+func bucketStart(recordedAt time.Time, bucket string) time.Time {
+	year, month, day := recordedAt.UTC().Date()
+	start := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if bucket == domain.PriceBucketWeek {
+		start = start.AddDate(0, 0, -(int(start.Weekday())+6)%7)
+	}
+	return start
+}
@@ -1,54 +1,334 @@
 package service
 
 import (
+	"cmp"
+	"context"
+	"fmt"
 	"product-app/domain"
 	"product-app/persistence"
+	"sort"
+	"strings"
+	"time"
 )
 
 type FakeProductRepository struct {
-	products []domain.Product
+	products        []domain.Product
+	deletedProducts []domain.Product
 }
 
-// !NewFakeProductRepository
+// NewFakeProductRepository stores products given without a version at version 1, like the column default does
 func NewFakeProductRepository(initialProducts []domain.Product) persistence.IProductRepository {
+	for index := range initialProducts {
+		initialProducts[index].Version = max(initialProducts[index].Version, 1)
+	}
 	return &FakeProductRepository{
 		products: initialProducts,
 	}
 }
 
 // !GetAllProducts
-func (fakeRepository *FakeProductRepository) GetAllProducts() []domain.Product {
-	return fakeRepository.products
+func (fakeRepository *FakeProductRepository) GetAllProducts(ctx context.Context) ([]domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return fakeRepository.products, nil
 }
 
 // !GetAllProductsByStore
-func (fakeRepository *FakeProductRepository) GetAllProductsByStore(storeName string) []domain.Product {
-	return []domain.Product{}
+func (fakeRepository *FakeProductRepository) GetAllProductsByStore(ctx context.Context, storeName string) ([]domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var products = []domain.Product{}
+	for _, product := range fakeRepository.products {
+		if sameStoreName(product.Store, storeName) {
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+// !GetProducts
+func (fakeRepository *FakeProductRepository) GetProducts(ctx context.Context, query domain.ProductQuery) (domain.ProductPage, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.ProductPage{}, err
+	}
+
+	var total int64
+	var rows = []domain.Product{}
+	for _, product := range fakeRepository.products {
+		if !matchesProductQuery(product, query) {
+			continue
+		}
+		total++
+		if product.Id > query.AfterId {
+			rows = append(rows, product)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return lessByProductSort(rows[i], rows[j], query.Sort)
+	})
+
+	if query.Offset >= len(rows) {
+		rows = []domain.Product{}
+	} else {
+		rows = rows[query.Offset:]
+	}
+	if len(rows) > query.Limit+1 {
+		rows = rows[:query.Limit+1]
+	}
+	return domain.NewProductPage(rows, query, total), nil
+}
+
+// !StreamProducts
+func (fakeRepository *FakeProductRepository) StreamProducts(ctx context.Context, query domain.ProductQuery, fn func(product domain.Product) error) error {
+	var rows []domain.Product
+	for _, product := range fakeRepository.products {
+		if matchesProductQuery(product, query) {
+			rows = append(rows, product)
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return lessByProductSort(rows[i], rows[j], query.Sort)
+	})
+	for _, product := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(product); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// ?matchesProductQuery mirrors the WHERE clause built by the real repository
+func matchesProductQuery(product domain.Product, query domain.ProductQuery) bool {
+	if query.StoreId > 0 && product.StoreId != query.StoreId {
+		return false
+	}
+	if len(query.Stores) > 0 {
+		storeMatches := false
+		for _, store := range query.Stores {
+			storeMatches = storeMatches || sameStoreName(product.Store, store)
+		}
+		if !storeMatches {
+			return false
+		}
+	}
+	if len(query.Name) > 0 && !strings.Contains(strings.ToLower(product.Name), strings.ToLower(query.Name)) {
+		return false
+	}
+	if query.MinPrice != nil && product.Price.Cmp(*query.MinPrice) < 0 {
+		return false
+	}
+	if query.MaxPrice != nil && product.Price.Cmp(*query.MaxPrice) > 0 {
+		return false
+	}
+	if query.MinDiscount != nil && product.Discount.Cmp(*query.MinDiscount) < 0 {
+		return false
+	}
+	return true
+}
+
+// ?sameStoreName compares store names the way the store table does
+func sameStoreName(left string, right string) bool {
+	return strings.EqualFold(domain.NormalizeStoreName(left), domain.NormalizeStoreName(right))
+}
+
+// ?lessByProductSort mirrors the ORDER BY clause built by the real repository, falling back to id
+func lessByProductSort(left domain.Product, right domain.Product, sortFields []domain.SortField) bool {
+	for _, sortField := range sortFields {
+		var comparison int
+		switch sortField.Field {
+		case "name":
+			comparison = cmp.Compare(left.Name, right.Name)
+		case "store":
+			comparison = cmp.Compare(left.Store, right.Store)
+		case "price":
+			comparison = left.Price.Cmp(right.Price)
+		case "discount":
+			comparison = left.Discount.Cmp(right.Discount)
+		case "id":
+			comparison = cmp.Compare(left.Id, right.Id)
+		}
+		if sortField.Descending {
+			comparison = -comparison
+		}
+		if comparison != 0 {
+			return comparison < 0
+		}
+	}
+	return left.Id < right.Id
 }
 
 // !AddProduct
-func (fakeRepository *FakeProductRepository) AddProduct(product domain.Product) error {
-	fakeRepository.products = append(fakeRepository.products, domain.Product{
-		Id:       int64(len(fakeRepository.products)) + 1,
+func (fakeRepository *FakeProductRepository) AddProduct(ctx context.Context, product domain.Product) (domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Product{}, err
+	}
+	var lastId int64
+	for _, existingProduct := range fakeRepository.products {
+		lastId = max(lastId, existingProduct.Id)
+	}
+	addedProduct := domain.Product{
+		Id:       lastId + 1,
 		Name:     product.Name,
 		Price:    product.Price,
 		Discount: product.Discount,
+		Currency: product.Currency,
+		StoreId:  product.StoreId,
 		Store:    product.Store,
-	})
-	return nil
+		Version:  1,
+	}
+	fakeRepository.products = append(fakeRepository.products, addedProduct)
+	return addedProduct, nil
+}
+
+// !AddProducts
+func (fakeRepository *FakeProductRepository) AddProducts(ctx context.Context, products []domain.Product) ([]domain.Product, error) {
+	addedProducts := []domain.Product{}
+	for _, product := range products {
+		addedProduct, err := fakeRepository.AddProduct(ctx, product)
+		if err != nil {
+			return nil, err
+		}
+		addedProducts = append(addedProducts, addedProduct)
+	}
+	return addedProducts, nil
 }
 
 // !GetProductById
-func (fakeRepository *FakeProductRepository) GetProductById(productId int64) (domain.Product, error) {
-	return domain.Product{}, nil
+func (fakeRepository *FakeProductRepository) GetProductById(ctx context.Context, productId int64) (domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Product{}, err
+	}
+	for _, product := range fakeRepository.products {
+		if product.Id == productId {
+			return product, nil
+		}
+	}
+	return domain.Product{}, domain.NewNotFoundError(fmt.Sprintf("Product not found with id %d", productId))
 }
 
 // !DeleteProductById
-func (fakeRepository *FakeProductRepository) DeleteProductById(productId int64) error {
-	return nil
+func (fakeRepository *FakeProductRepository) DeleteProductById(ctx context.Context, productId int64, expectedVersion int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for index, product := range fakeRepository.products {
+		if product.Id == productId {
+			if err := checkVersion(product, expectedVersion); err != nil {
+				return err
+			}
+			deletedAt := time.Now()
+			product.DeletedAt = &deletedAt
+			product.Version++
+			fakeRepository.products = append(fakeRepository.products[:index:index], fakeRepository.products[index+1:]...)
+			fakeRepository.deletedProducts = append(fakeRepository.deletedProducts, product)
+			return nil
+		}
+	}
+	return domain.NewNotFoundError(fmt.Sprintf("Product not found with id %d", productId))
 }
 
 // !UpdateProductPrice
-func (fakeRepository *FakeProductRepository) UpdateProductPrice(productId int64, newPrice float32) error {
-	return nil
+func (fakeRepository *FakeProductRepository) UpdateProductPrice(ctx context.Context, productId int64, newPrice domain.Decimal, expectedVersion int64) error {
+	product, err := fakeRepository.GetProductById(ctx, productId)
+	if err != nil {
+		return err
+	}
+	product.Price = newPrice
+	product.Version = expectedVersion
+	_, err = fakeRepository.UpdateProduct(ctx, product)
+	return err
+}
+
+// !UpdateProduct
+func (fakeRepository *FakeProductRepository) UpdateProduct(ctx context.Context, product domain.Product) (domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Product{}, err
+	}
+	for index := range fakeRepository.products {
+		if fakeRepository.products[index].Id == product.Id {
+			if err := checkVersion(fakeRepository.products[index], product.Version); err != nil {
+				return domain.Product{}, err
+			}
+			product.Version = fakeRepository.products[index].Version + 1
+			fakeRepository.products[index] = product
+			return product, nil
+		}
+	}
+	return domain.Product{}, domain.NewNotFoundError(fmt.Sprintf("Product not found with id %d", product.Id))
+}
+
+// !GetDeletedProducts
+func (fakeRepository *FakeProductRepository) GetDeletedProducts(ctx context.Context) ([]domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return append([]domain.Product{}, fakeRepository.deletedProducts...), nil
+}
+
+// !RestoreProductById
+func (fakeRepository *FakeProductRepository) RestoreProductById(ctx context.Context, productId int64, expectedVersion int64) (domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Product{}, err
+	}
+	for index, product := range fakeRepository.deletedProducts {
+		if product.Id == productId {
+			if err := checkVersion(product, expectedVersion); err != nil {
+				return domain.Product{}, err
+			}
+			product.DeletedAt = nil
+			product.Version++
+			fakeRepository.deletedProducts = append(fakeRepository.deletedProducts[:index:index], fakeRepository.deletedProducts[index+1:]...)
+			fakeRepository.products = append(fakeRepository.products, product)
+			return product, nil
+		}
+	}
+	return domain.Product{}, domain.NewNotFoundError(fmt.Sprintf("Deleted product not found with id %d", productId))
+}
+
+// !PurgeDeletedProducts
+func (fakeRepository *FakeProductRepository) PurgeDeletedProducts(ctx context.Context, deletedBefore time.Time) ([]domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var keptProducts, purgedProducts []domain.Product
+	for _, product := range fakeRepository.deletedProducts {
+		if product.DeletedAt.Before(deletedBefore) {
+			purgedProducts = append(purgedProducts, product)
+		} else {
+			keptProducts = append(keptProducts, product)
+		}
+	}
+	fakeRepository.deletedProducts = keptProducts
+	return purgedProducts, nil
+}
+
+// !BumpProductsOfStore
+func (fakeRepository *FakeProductRepository) BumpProductsOfStore(ctx context.Context, store domain.Store) ([]domain.Product, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	bumpedProducts := []domain.Product{}
+	for _, products := range [][]domain.Product{fakeRepository.products, fakeRepository.deletedProducts} {
+		for index := range products {
+			if products[index].StoreId == store.Id {
+				products[index].Store = store.Name
+				products[index].Version++
+				bumpedProducts = append(bumpedProducts, products[index])
+			}
+		}
+	}
+	return bumpedProducts, nil
+}
+
+// ?checkVersion mirrors the version condition of the real repository
+func checkVersion(product domain.Product, expectedVersion int64) error {
+	if expectedVersion == domain.AnyVersion || expectedVersion == product.Version {
+		return nil
+	}
+	return domain.NewVersionMismatchError(fmt.Sprintf("Product %d is at version %d, not %d", product.Id, product.Version, expectedVersion))
 }
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"product-app/domain"
+	"product-app/persistence"
+	"sort"
+	"strings"
+	"time"
+)
+
+type FakeStoreRepository struct {
+	stores []domain.Store
+	// storeInUse mirrors the foreign key of product, stores it reports as used can not be deleted
+	storeInUse func(storeId int64) bool
+}
+
+// NewFakeStoreRepository numbers initialStores given without an id after their position
+func NewFakeStoreRepository(initialStores []domain.Store) *FakeStoreRepository {
+	for index := range initialStores {
+		if initialStores[index].Id == 0 {
+			initialStores[index].Id = int64(index + 1)
+		}
+	}
+	return &FakeStoreRepository{
+		stores:     initialStores,
+		storeInUse: func(storeId int64) bool { return false },
+	}
+}
+
+// NewFakeCatalog adds a store for every store initialProducts name and links the products to it, like the store
+// migration does. Stores that still have products, also in the trash, can not be deleted.
+func NewFakeCatalog(initialProducts []domain.Product) (persistence.IProductRepository, *FakeStoreRepository) {
+	storeRepository := NewFakeStoreRepository(nil)
+	for index := range initialProducts {
+		store, _ := storeRepository.EnsureStore(context.Background(), domain.NormalizeStoreName(initialProducts[index].Store))
+		initialProducts[index].StoreId, initialProducts[index].Store = store.Id, store.Name
+	}
+	productRepository := NewFakeProductRepository(initialProducts).(*FakeProductRepository)
+	storeRepository.storeInUse = func(storeId int64) bool {
+		for _, products := range [][]domain.Product{productRepository.products, productRepository.deletedProducts} {
+			for _, product := range products {
+				if product.StoreId == storeId {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return productRepository, storeRepository
+}
+
+// !GetAllStores
+func (fakeRepository *FakeStoreRepository) GetAllStores(ctx context.Context) ([]domain.Store, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stores := append([]domain.Store{}, fakeRepository.stores...)
+	sort.SliceStable(stores, func(i, j int) bool {
+		return strings.ToLower(stores[i].Name) < strings.ToLower(stores[j].Name)
+	})
+	return stores, nil
+}
+
+// !GetStoreById
+func (fakeRepository *FakeStoreRepository) GetStoreById(ctx context.Context, storeId int64) (domain.Store, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Store{}, err
+	}
+	for _, store := range fakeRepository.stores {
+		if store.Id == storeId {
+			return store, nil
+		}
+	}
+	return domain.Store{}, domain.NewNotFoundError(fmt.Sprintf("Store not found with id %d", storeId))
+}
+
+// !AddStore
+func (fakeRepository *FakeStoreRepository) AddStore(ctx context.Context, name string) (domain.Store, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Store{}, err
+	}
+	if _, found := fakeRepository.storeByName(name, 0); found {
+		return domain.Store{}, domain.NewConflictError(fmt.Sprintf("Store %s already exists", name), nil)
+	}
+	var lastId int64
+	for _, store := range fakeRepository.stores {
+		lastId = max(lastId, store.Id)
+	}
+	addedStore := domain.Store{Id: lastId + 1, Name: name, CreatedAt: time.Now()}
+	fakeRepository.stores = append(fakeRepository.stores, addedStore)
+	return addedStore, nil
+}
+
+// !EnsureStore
+func (fakeRepository *FakeStoreRepository) EnsureStore(ctx context.Context, name string) (domain.Store, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Store{}, err
+	}
+	if store, found := fakeRepository.storeByName(name, 0); found {
+		return store, nil
+	}
+	return fakeRepository.AddStore(ctx, name)
+}
+
+// !RenameStore
+func (fakeRepository *FakeStoreRepository) RenameStore(ctx context.Context, storeId int64, name string) (domain.Store, error) {
+	if err := ctx.Err(); err != nil {
+		return domain.Store{}, err
+	}
+	if _, found := fakeRepository.storeByName(name, storeId); found {
+		return domain.Store{}, domain.NewConflictError(fmt.Sprintf("Store %s already exists", name), nil)
+	}
+	for index := range fakeRepository.stores {
+		if fakeRepository.stores[index].Id == storeId {
+			fakeRepository.stores[index].Name = name
+			return fakeRepository.stores[index], nil
+		}
+	}
+	return domain.Store{}, domain.NewNotFoundError(fmt.Sprintf("Store not found with id %d", storeId))
+}
+
+// !DeleteStoreById
+func (fakeRepository *FakeStoreRepository) DeleteStoreById(ctx context.Context, storeId int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if fakeRepository.storeInUse(storeId) {
+		return domain.NewConflictError(fmt.Sprintf("Store %d still has products", storeId), nil)
+	}
+	for index, store := range fakeRepository.stores {
+		if store.Id == storeId {
+			fakeRepository.stores = append(fakeRepository.stores[:index], fakeRepository.stores[index+1:]...)
+			return nil
+		}
+	}
+	return domain.NewNotFoundError(fmt.Sprintf("Store not found with id %d", storeId))
+}
+
+// *storeByName mirrors the unique index on lower(name), the store with exceptId is left out
+func (fakeRepository *FakeStoreRepository) storeByName(name string, exceptId int64) (domain.Store, bool) {
+	for _, store := range fakeRepository.stores {
+		if store.Id != exceptId && sameStoreName(store.Name, name) {
+			return store, true
+		}
+	}
+	return domain.Store{}, false
+}
@@ -1,77 +1,589 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"product-app/common/logging"
 	"product-app/domain"
+	"product-app/persistence"
 	"product-app/service"
 	"product-app/service/model"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
-var productService service.IProductService
+var ctx context.Context
 
 func TestMain(m *testing.M) {
+	ctx = context.Background()
+	exitCode := m.Run()
+	os.Exit(exitCode)
+}
+
+// *newProductService builds a service over a fresh fake repository so tests do not share state
+func newProductService() service.IProductService {
+	productService, _ := newAuditedProductService()
+	return productService
+}
+
+// *newAuditedProductService also returns the audit log the service writes to
+func newAuditedProductService() (service.IProductService, *FakeAuditRepository) {
 	initialProducts := []domain.Product{
 		{
-			Id:    1,
-			Name:  "AirFryer",
-			Price: 1000.0,
-			Store: "ABC TECH",
+			Id:       1,
+			Name:     "AirFryer",
+			Price:    domain.NewDecimal(1000),
+			Currency: "TRY",
+			Store:    "ABC TECH",
 		},
 		{
-			Id:    2,
-			Name:  "Ütü",
-			Price: 4000.0,
-			Store: "ABC TECH",
+			Id:       2,
+			Name:     "Ütü",
+			Price:    domain.NewDecimal(4000),
+			Currency: "TRY",
+			Store:    "ABC TECH",
 		},
 	}
 
-	fakeProductReporitory := NewFakeProductRepository(initialProducts)
-	productService = service.NewProductService(fakeProductReporitory)
-	exitCode := m.Run()
-	os.Exit(exitCode)
+	fakeProductReporitory, fakeStoreRepository := NewFakeCatalog(initialProducts)
+	fakeAuditRepository := NewFakeAuditRepository()
+	return service.NewProductService(fakeProductReporitory, fakeStoreRepository, fakeAuditRepository, NewFakePriceHistoryRepository(), NewFakeTransactor(), logging.Discard()),
+		fakeAuditRepository
 }
 
 func Test_ShouldGetAllProducts(t *testing.T) {
+	productService := newProductService()
 	t.Run("ShouldGetAllProducts", func(t *testing.T) {
-		actualProducts := productService.AllProducts()
+		actualProducts, err := productService.AllProducts(ctx)
+		assert.Nil(t, err)
 		assert.Equal(t, 2, len(actualProducts))
 	})
 }
 
 func Test_WhenNoValidationErrorOccurred_ShouldAddProduct(t *testing.T) {
+	productService := newProductService()
 	t.Run("WhenNoValidationErrorOccurred_ShouldAddProduct", func(t *testing.T) {
-		productService.Add(model.ProductCreate{
+		addedProduct, err := productService.Add(ctx, model.ProductCreate{
 			Name:     "Ütü",
-			Price:    2000.0,
-			Discount: 50,
+			Price:    domain.NewDecimal(2000),
+			Discount: domain.NewDecimal(50),
+			Currency: "TRY",
 			Store:    "ABC TECH",
 		})
-		actualProducts := productService.AllProducts()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(3), addedProduct.Id)
+		actualProducts, _ := productService.AllProducts(ctx)
 		assert.Equal(t, 3, len(actualProducts))
 		assert.Equal(t, domain.Product{
 			Id:       3,
 			Name:     "Ütü",
-			Price:    2000.0,
-			Discount: 50,
+			Price:    domain.NewDecimal(2000),
+			Discount: domain.NewDecimal(50),
+			Currency: "TRY",
+			StoreId:  1,
 			Store:    "ABC TECH",
+			Version:  1,
 		}, actualProducts[len(actualProducts)-1])
 	})
 }
 
 // Discount can not be greater than 70
 func Test_WhenDiscountIsHigherThan70_ShouldNotAddProduct(t *testing.T) {
+	productService := newProductService()
 	t.Run("WhenDiscountIsHigherThan70_ShouldNotAddProduct", func(t *testing.T) {
-		err := productService.Add(model.ProductCreate{
+		_, err := productService.Add(ctx, model.ProductCreate{
 			Name:     "Ütü",
-			Price:    2000.0,
-			Discount: 75,
+			Price:    domain.NewDecimal(2000),
+			Discount: domain.NewDecimal(75),
+			Currency: "TRY",
 			Store:    "ABC TECH",
 		})
-		actualProducts := productService.AllProducts()
+		actualProducts, _ := productService.AllProducts(ctx)
 		assert.Equal(t, 2, len(actualProducts))
 		assert.Equal(t, "Discount can not be greater than 70", err.Error())
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
+
+func Test_WhenContextIsCancelled_ShouldNotAddProduct(t *testing.T) {
+	productService := newProductService()
+	t.Run("WhenContextIsCancelled_ShouldNotAddProduct", func(t *testing.T) {
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := productService.Add(cancelledCtx, model.ProductCreate{
+			Name:     "Ütü",
+			Price:    domain.NewDecimal(2000),
+			Discount: domain.NewDecimal(50),
+			Currency: "TRY",
+			Store:    "ABC TECH",
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		actualProducts, _ := productService.AllProducts(ctx)
+		assert.Equal(t, 2, len(actualProducts))
+	})
+}
+
+func Test_WhenContextIsCancelled_ShouldReturnErrorInsteadOfProducts(t *testing.T) {
+	productService := newProductService()
+	t.Run("WhenContextIsCancelled_ShouldReturnErrorInsteadOfProducts", func(t *testing.T) {
+		cancelledCtx, cancel := context.WithCancel(ctx)
+		cancel()
+		actualProducts, err := productService.AllProducts(cancelledCtx)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, actualProducts)
+	})
+}
+
+func Test_WhenProductHasSeveralViolations_ShouldReportAllOfThem(t *testing.T) {
+	productService := newProductService()
+	t.Run("WhenProductHasSeveralViolations_ShouldReportAllOfThem", func(t *testing.T) {
+		_, err := productService.Add(ctx, model.ProductCreate{
+			Name:     "",
+			Price:    domain.MustParseDecimal("19.999"),
+			Discount: domain.NewDecimal(-1),
+			Store:    strings.Repeat("a", 256),
+		})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		assert.Equal(t, []domain.FieldError{
+			{Field: "name", Code: "required", Message: "Name can not be empty"},
+			{Field: "store", Code: "max_length", Message: "Store can not be longer than 255 characters"},
+			{Field: "price", Code: "precision", Message: "Price can not have more than 2 fractional digits in TRY"},
+			{Field: "discount", Code: "min", Message: "Discount can not be negative"},
+		}, domain.FieldErrorsOf(err))
+	})
+}
+
+func Test_ShouldKeepExactPricesAndCurrency(t *testing.T) {
+	productService := newProductService()
+	t.Run("ShouldKeepExactPricesAndCurrency", func(t *testing.T) {
+		addedProduct, err := productService.Add(ctx, model.ProductCreate{
+			Name:     "Kupa",
+			Price:    domain.MustParseDecimal("19.99"),
+			Discount: domain.MustParseDecimal("12.5"),
+			Currency: "USD",
+			Store:    "Kırtasiye Merkezi",
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, "19.99", addedProduct.Price.String())
+		assert.Equal(t, "12.5", addedProduct.Discount.String())
+		assert.Equal(t, "USD", addedProduct.Currency)
+	})
+	t.Run("WhenCurrencyIsUnknown_ShouldNotAddProduct", func(t *testing.T) {
+		_, err := productService.Add(ctx, model.ProductCreate{
+			Name:     "Kupa",
+			Price:    domain.NewDecimal(100),
+			Currency: "XYZ",
+			Store:    "Kırtasiye Merkezi",
+		})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		assert.Equal(t, "Currency XYZ is not a supported ISO 4217 code", err.Error())
+	})
+	t.Run("WhenCurrencyHasNoMinorUnit_ShouldRejectFractions", func(t *testing.T) {
+		_, err := productService.Add(ctx, model.ProductCreate{
+			Name:     "Kupa",
+			Price:    domain.MustParseDecimal("100.5"),
+			Currency: "JPY",
+			Store:    "Kırtasiye Merkezi",
+		})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
+
+func Test_WhenVersionIsStale_ShouldNotChangeProduct(t *testing.T) {
+	productService := newProductService()
+	t.Run("WhenVersionMatches_ShouldIncrementVersion", func(t *testing.T) {
+		updatedProduct, err := productService.Patch(ctx, 1, model.ProductPatch{Price: pointerTo(domain.NewDecimal(1100))}, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), updatedProduct.Version)
+	})
+	t.Run("WhenVersionIsStale_ShouldReportVersionMismatch", func(t *testing.T) {
+		_, patchErr := productService.Patch(ctx, 1, model.ProductPatch{Price: pointerTo(domain.NewDecimal(1200))}, 1)
+		assert.ErrorIs(t, patchErr, domain.ErrVersionMismatch)
+		assert.Equal(t, "Product 1 is at version 2, not 1", patchErr.Error())
+		assert.ErrorIs(t, productService.UpdateProductPrice(ctx, 1, domain.NewDecimal(1200), 1), domain.ErrVersionMismatch)
+		assert.ErrorIs(t, productService.DeleteById(ctx, 1, 1), domain.ErrVersionMismatch)
+
+		product, _ := productService.ProductById(ctx, 1)
+		assert.Equal(t, "1100", product.Price.String())
+	})
+	t.Run("WhenVersionIsNotGiven_ShouldWriteUnconditionally", func(t *testing.T) {
+		assert.Nil(t, productService.UpdateProductPrice(ctx, 1, domain.NewDecimal(1300), domain.AnyVersion))
+		assert.Nil(t, productService.DeleteById(ctx, 1, domain.AnyVersion))
+	})
+}
+
+// *pointerTo
+func pointerTo[T any](value T) *T {
+	return &value
+}
+
+func Test_WhenProductIsDeleted_ShouldKeepItInTrash(t *testing.T) {
+	productService := newProductService()
+	t.Run("WhenProductIsDeleted_ShouldHideIt", func(t *testing.T) {
+		assert.Nil(t, productService.DeleteById(ctx, 1, domain.AnyVersion))
+		_, getErr := productService.ProductById(ctx, 1)
+		assert.ErrorIs(t, getErr, domain.ErrNotFound)
+		actualProducts, _ := productService.AllProducts(ctx)
+		assert.Equal(t, 1, len(actualProducts))
+
+		deletedProducts, err := productService.DeletedProducts(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(deletedProducts))
+		assert.NotNil(t, deletedProducts[0].DeletedAt)
+	})
+	t.Run("WhenProductIsRestored_ShouldShowItAgain", func(t *testing.T) {
+		restoredProduct, err := productService.Restore(ctx, 1, domain.AnyVersion)
+		assert.Nil(t, err)
+		assert.Nil(t, restoredProduct.DeletedAt)
+		assert.Equal(t, int64(3), restoredProduct.Version)
+		_, getErr := productService.ProductById(ctx, 1)
+		assert.Nil(t, getErr)
+
+		_, restoreErr := productService.Restore(ctx, 1, domain.AnyVersion)
+		assert.ErrorIs(t, restoreErr, domain.ErrNotFound)
+	})
+	t.Run("WhenTrashIsPurged_ShouldOnlyRemoveProductsOlderThanRetention", func(t *testing.T) {
+		assert.Nil(t, productService.DeleteById(ctx, 2, domain.AnyVersion))
+		purged, err := productService.PurgeDeleted(ctx, time.Hour)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), purged)
+
+		purged, err = productService.PurgeDeleted(ctx, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), purged)
+		_, restoreErr := productService.Restore(ctx, 2, domain.AnyVersion)
+		assert.ErrorIs(t, restoreErr, domain.ErrNotFound)
+
+		_, negativeErr := productService.PurgeDeleted(ctx, -time.Hour)
+		assert.ErrorIs(t, negativeErr, domain.ErrValidation)
+	})
+}
+
+func Test_WhenProductChanges_ShouldWriteAuditRecord(t *testing.T) {
+	productService, fakeAuditRepository := newAuditedProductService()
+	actorCtx := logging.WithRequestId(logging.WithActor(ctx, "alice"), "req-1")
+	t.Run("WhenPriceChanges_ShouldRecordActorAndDiff", func(t *testing.T) {
+		assert.Nil(t, productService.UpdateProductPrice(actorCtx, 1, domain.NewDecimal(1100), domain.AnyVersion))
+
+		auditPage, err := productService.AuditRecords(ctx, domain.AuditQuery{ProductId: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(auditPage.Items))
+		auditRecord := auditPage.Items[0]
+		assert.Equal(t, domain.AuditPriceChange, auditRecord.Operation)
+		assert.Equal(t, "alice", auditRecord.Actor)
+		assert.Equal(t, "req-1", auditRecord.RequestId)
+		assert.Equal(t, int64(1), auditRecord.Before.Version)
+		assert.Equal(t, int64(2), auditRecord.After.Version)
+		assert.Equal(t, []domain.FieldChange{
+			{Field: "price", Before: pointerTo("1000"), After: pointerTo("1100")},
+		}, auditRecord.Changes)
 	})
+	t.Run("WhenProductIsCreatedAndDeleted_ShouldRecordOneSideOnly", func(t *testing.T) {
+		addedProduct, _ := productService.Add(ctx, model.ProductCreate{
+			Name:     "Kupa",
+			Price:    domain.NewDecimal(100),
+			Currency: "TRY",
+			Store:    "Kırtasiye Merkezi",
+		})
+		assert.Nil(t, productService.DeleteById(ctx, addedProduct.Id, domain.AnyVersion))
+
+		auditPage, _ := productService.AuditRecords(ctx, domain.AuditQuery{ProductId: addedProduct.Id})
+		assert.Equal(t, 2, len(auditPage.Items))
+		assert.Equal(t, domain.AuditDelete, auditPage.Items[0].Operation)
+		assert.Nil(t, auditPage.Items[0].After)
+		assert.Equal(t, domain.AuditCreate, auditPage.Items[1].Operation)
+		assert.Nil(t, auditPage.Items[1].Before)
+		assert.Equal(t, service.SystemActor, auditPage.Items[1].Actor)
+	})
+	t.Run("WhenWriteFails_ShouldNotRecordAnything", func(t *testing.T) {
+		_, err := productService.Patch(ctx, 2, model.ProductPatch{Discount: pointerTo(domain.NewDecimal(90))}, domain.AnyVersion)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		assert.ErrorIs(t, productService.UpdateProductPrice(ctx, 2, domain.NewDecimal(1), 5), domain.ErrVersionMismatch)
+
+		auditPage, _ := productService.AuditRecords(ctx, domain.AuditQuery{ProductId: 2})
+		assert.Empty(t, auditPage.Items)
+	})
+	t.Run("WhenQueryIsFiltered_ShouldOnlyReturnMatchingRecords", func(t *testing.T) {
+		auditPage, _ := productService.AuditRecords(ctx, domain.AuditQuery{Actor: "alice"})
+		assert.Equal(t, 1, len(auditPage.Items))
+
+		auditPage, _ = productService.AuditRecords(ctx, domain.AuditQuery{Limit: 2})
+		assert.Equal(t, 2, len(auditPage.Items))
+		assert.True(t, auditPage.HasMore)
+		nextPage, _ := productService.AuditRecords(ctx, domain.AuditQuery{Limit: 2, BeforeId: auditPage.NextCursor})
+		assert.Equal(t, 1, len(nextPage.Items))
+		assert.False(t, nextPage.HasMore)
+		assert.Equal(t, 3, len(fakeAuditRepository.auditRecords))
+	})
+	t.Run("WhenOperationIsUnknown_ShouldRejectQuery", func(t *testing.T) {
+		_, err := productService.AuditRecords(ctx, domain.AuditQuery{Operation: "drop"})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
+
+func Test_WhenPriceChanges_ShouldRecordPriceHistory(t *testing.T) {
+	productService := newProductService()
+	t.Run("WhenPriceOrDiscountChanges_ShouldAddPoint", func(t *testing.T) {
+		assert.Nil(t, productService.UpdateProductPrice(ctx, 1, domain.NewDecimal(1100), domain.AnyVersion))
+		_, err := productService.Patch(ctx, 1, model.ProductPatch{Discount: pointerTo(domain.NewDecimal(10))}, domain.AnyVersion)
+		assert.Nil(t, err)
+		_, err = productService.Patch(ctx, 1, model.ProductPatch{Name: pointerTo("AirFryer XL")}, domain.AnyVersion)
+		assert.Nil(t, err)
+
+		priceHistory, err := productService.PriceHistory(ctx, domain.PriceHistoryQuery{ProductId: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(priceHistory.Points))
+		assert.Equal(t, "1100", priceHistory.Points[0].Price.String())
+		assert.Equal(t, "10", priceHistory.Points[1].Discount.String())
+		assert.Nil(t, priceHistory.Buckets)
+	})
+	t.Run("WhenBucketIsGiven_ShouldSummarizePoints", func(t *testing.T) {
+		assert.Nil(t, productService.UpdateProductPrice(ctx, 1, domain.NewDecimal(900), domain.AnyVersion))
+
+		priceHistory, err := productService.PriceHistory(ctx, domain.PriceHistoryQuery{ProductId: 1, Bucket: domain.PriceBucketDay})
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(priceHistory.Buckets))
+		assert.Equal(t, "900", priceHistory.Buckets[0].MinPrice.String())
+		assert.Equal(t, "1100", priceHistory.Buckets[0].MaxPrice.String())
+		assert.Equal(t, "900", priceHistory.Buckets[0].LastPrice.String())
+		assert.Equal(t, "10", priceHistory.Buckets[0].LastDiscount.String())
+	})
+	t.Run("WhenCurrencyChangesWithinBucket_ShouldSplitBucket", func(t *testing.T) {
+		_, err := productService.Patch(ctx, 1, model.ProductPatch{Price: pointerTo(domain.NewDecimal(30)), Currency: pointerTo("USD")}, domain.AnyVersion)
+		assert.Nil(t, err)
+
+		priceHistory, err := productService.PriceHistory(ctx, domain.PriceHistoryQuery{ProductId: 1, Bucket: domain.PriceBucketDay})
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(priceHistory.Buckets))
+		assert.Equal(t, priceHistory.Buckets[0].Start, priceHistory.Buckets[1].Start)
+		assert.Equal(t, "900", priceHistory.Buckets[0].MinPrice.String())
+		assert.Equal(t, "USD", priceHistory.Buckets[1].Currency)
+		assert.Equal(t, "30", priceHistory.Buckets[1].MinPrice.String())
+		assert.Equal(t, "30", priceHistory.Buckets[1].MaxPrice.String())
+	})
+	t.Run("WhenProductIsAdded_ShouldStartSeries", func(t *testing.T) {
+		addedProduct, _ := productService.Add(ctx, model.ProductCreate{Name: "Kupa", Price: domain.NewDecimal(100), Store: "ABC TECH"})
+		priceHistory, _ := productService.PriceHistory(ctx, domain.PriceHistoryQuery{ProductId: addedProduct.Id})
+		assert.Equal(t, 1, len(priceHistory.Points))
+	})
+	t.Run("WhenQueryIsInvalid_ShouldRejectIt", func(t *testing.T) {
+		_, bucketErr := productService.PriceHistory(ctx, domain.PriceHistoryQuery{ProductId: 1, Bucket: "hour"})
+		assert.ErrorIs(t, bucketErr, domain.ErrValidation)
+		from, to := time.Now(), time.Now().Add(-time.Hour)
+		_, rangeErr := productService.PriceHistory(ctx, domain.PriceHistoryQuery{ProductId: 1, From: &from, To: &to})
+		assert.ErrorIs(t, rangeErr, domain.ErrValidation)
+	})
+}
+
+func Test_ShouldImportProductsInBulk(t *testing.T) {
+	validRow := func(line int) model.ImportRow {
+		return model.ImportRow{Line: line, Product: model.ProductCreate{Name: fmt.Sprintf("Kalem %d", line), Price: domain.NewDecimal(10), Store: "Kırtasiye Merkezi"}}
+	}
+	invalidRow := model.ImportRow{Line: 3, Product: model.ProductCreate{Name: "Kupa", Price: domain.NewDecimal(10), Discount: domain.NewDecimal(90), Store: "ABC TECH"}}
+	unparsedRow := model.ImportRow{Line: 4, Errors: []domain.FieldError{{Field: "price", Code: "type", Message: "Field price must be a decimal number"}}}
+	t.Run("WhenRowIsRejectedInAllOrNothingMode_ShouldWriteNothing", func(t *testing.T) {
+		productService := newProductService()
+		report, err := productService.Import(ctx, []model.ImportRow{validRow(2), invalidRow, unparsedRow}, domain.ImportAllOrNothing)
+		assert.Nil(t, err)
+		assert.False(t, report.Committed)
+		assert.Equal(t, 2, report.Rejected)
+		assert.Equal(t, []string{domain.ImportSkipped, domain.ImportRejected, domain.ImportRejected},
+			[]string{report.Rows[0].Status, report.Rows[1].Status, report.Rows[2].Status})
+		assert.Equal(t, "discount", report.Rows[1].Errors[0].Field)
+		actualProducts, _ := productService.AllProducts(ctx)
+		assert.Equal(t, 2, len(actualProducts))
+	})
+	t.Run("WhenRowIsRejectedInBestEffortMode_ShouldWriteValidRows", func(t *testing.T) {
+		productService, fakeAuditRepository := newAuditedProductService()
+		report, err := productService.Import(ctx, []model.ImportRow{validRow(2), invalidRow, validRow(5)}, domain.ImportBestEffort)
+		assert.Nil(t, err)
+		assert.True(t, report.Committed)
+		assert.Equal(t, 2, report.Accepted)
+		assert.Equal(t, 1, report.Rejected)
+		assert.Equal(t, int64(3), report.Rows[0].ProductId)
+		assert.Equal(t, int64(4), report.Rows[2].ProductId)
+		assert.Equal(t, domain.DefaultCurrency, fakeAuditRepository.auditRecords[0].After.Currency)
+		assert.Equal(t, 2, len(fakeAuditRepository.auditRecords))
+	})
+	t.Run("WhenImportIsLargerThanOneBatch_ShouldWriteEveryRow", func(t *testing.T) {
+		productService := newProductService()
+		var rows []model.ImportRow
+		for line := 2; line < domain.ImportBatchSize+500; line++ {
+			rows = append(rows, validRow(line))
+		}
+		report, err := productService.Import(ctx, rows, domain.ImportAllOrNothing)
+		assert.Nil(t, err)
+		assert.Equal(t, len(rows), report.Accepted)
+		actualProducts, _ := productService.AllProducts(ctx)
+		assert.Equal(t, len(rows)+2, len(actualProducts))
+	})
+	t.Run("WhenDatabaseGoesAwayAfterFirstBatch_ShouldReturnPartialReportWithError", func(t *testing.T) {
+		productRepository, storeRepository := NewFakeCatalog(nil)
+		failingRepository := &failingBatchProductRepository{IProductRepository: productRepository, failOnCall: 2,
+			err: domain.NewUnavailableError("Error while adding products", errors.New("connection refused"))}
+		productService := service.NewProductService(failingRepository, storeRepository, NewFakeAuditRepository(),
+			NewFakePriceHistoryRepository(), NewFakeTransactor(), logging.Discard())
+		var rows []model.ImportRow
+		for line := 2; line < 2*domain.ImportBatchSize+2+10; line++ {
+			rows = append(rows, validRow(line))
+		}
+		report, err := productService.Import(ctx, rows, domain.ImportBestEffort)
+		assert.ErrorIs(t, err, domain.ErrUnavailable)
+		assert.True(t, report.Committed)
+		assert.Equal(t, domain.ImportBatchSize, report.Accepted)
+		assert.Equal(t, len(rows), len(report.Rows))
+		assert.Equal(t, domain.ImportAccepted, report.Rows[domain.ImportBatchSize-1].Status)
+		assert.Equal(t, int64(domain.ImportBatchSize), report.Rows[domain.ImportBatchSize-1].ProductId)
+		for _, row := range report.Rows[domain.ImportBatchSize:] {
+			assert.Equal(t, domain.ImportNotAttempted, row.Status)
+			assert.Zero(t, row.ProductId)
+		}
+		actualProducts, _ := productService.AllProducts(ctx)
+		assert.Equal(t, domain.ImportBatchSize, len(actualProducts))
+	})
+	t.Run("WhenImportIsInvalid_ShouldRejectIt", func(t *testing.T) {
+		productService := newProductService()
+		_, modeErr := productService.Import(ctx, []model.ImportRow{validRow(2)}, "some")
+		assert.ErrorIs(t, modeErr, domain.ErrValidation)
+		_, emptyErr := productService.Import(ctx, nil, domain.ImportBestEffort)
+		assert.ErrorIs(t, emptyErr, domain.ErrValidation)
+	})
+}
+
+func Test_ShouldExportProductsMatchingQuery(t *testing.T) {
+	t.Run("ShouldIgnorePagingAndKeepSortOrder", func(t *testing.T) {
+		productService := newProductService()
+		var exportedIds []int64
+		err := productService.Export(ctx, domain.ProductQuery{
+			Sort:    []domain.SortField{{Field: "price", Descending: true}},
+			Limit:   1,
+			Offset:  1,
+			AfterId: 5,
+		}, func(product domain.Product) error {
+			exportedIds = append(exportedIds, product.Id)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []int64{2, 1}, exportedIds)
+	})
+	t.Run("WhenWriteFails_ShouldStopExport", func(t *testing.T) {
+		productService := newProductService()
+		writeErr := errors.New("connection reset")
+		exported := 0
+		err := productService.Export(ctx, domain.ProductQuery{}, func(product domain.Product) error {
+			exported++
+			return writeErr
+		})
+		assert.ErrorIs(t, err, writeErr)
+		assert.Equal(t, 1, exported)
+	})
+	t.Run("WhenFiltersAreInvalid_ShouldRejectExport", func(t *testing.T) {
+		productService := newProductService()
+		err := productService.Export(ctx, domain.ProductQuery{Sort: []domain.SortField{{Field: "colour"}}}, func(product domain.Product) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+}
+
+func Test_ShouldKeepOneStorePerName(t *testing.T) {
+	newAuditedStoreService := func() (service.IStoreService, service.IProductService, *FakeAuditRepository) {
+		productRepository, storeRepository := NewFakeCatalog([]domain.Product{
+			{Id: 1, Name: "AirFryer", Price: domain.NewDecimal(1000), Currency: "TRY", Store: "ABC TECH"},
+			{Id: 2, Name: "Lambader", Price: domain.NewDecimal(2000), Currency: "TRY", Store: "Dekorasyon Sarayı"},
+		})
+		auditRepository, transactor := NewFakeAuditRepository(), NewFakeTransactor()
+		productService := service.NewProductService(productRepository, storeRepository, auditRepository,
+			NewFakePriceHistoryRepository(), transactor, logging.Discard())
+		return service.NewStoreService(storeRepository, productRepository, auditRepository, transactor, productService, logging.Discard()),
+			productService, auditRepository
+	}
+	newStoreService := func() (service.IStoreService, service.IProductService) {
+		storeService, productService, _ := newAuditedStoreService()
+		return storeService, productService
+	}
+	t.Run("WhenProductNamesStoreInAnotherSpelling_ShouldUseExistingStore", func(t *testing.T) {
+		storeService, productService := newStoreService()
+		addedProduct, err := productService.Add(ctx, model.ProductCreate{Name: "Ütü", Price: domain.NewDecimal(1500), Store: "  abc   tech"})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), addedProduct.StoreId)
+		assert.Equal(t, "ABC TECH", addedProduct.Store)
+		stores, _ := storeService.AllStores(ctx)
+		assert.Equal(t, 2, len(stores))
+		storeProducts, _ := productService.ProductsByStore(ctx, "abc tech ")
+		assert.Equal(t, 2, len(storeProducts))
+	})
+	t.Run("WhenProductNamesUnknownStore_ShouldAddIt", func(t *testing.T) {
+		storeService, productService := newStoreService()
+		addedProduct, err := productService.Add(ctx, model.ProductCreate{Name: "Kupa", Price: domain.NewDecimal(100), Store: "Kırtasiye  Merkezi"})
+		assert.Nil(t, err)
+		store, getErr := storeService.StoreById(ctx, addedProduct.StoreId)
+		assert.Nil(t, getErr)
+		assert.Equal(t, "Kırtasiye Merkezi", store.Name)
+	})
+	t.Run("WhenNameIsTaken_ShouldReportConflict", func(t *testing.T) {
+		storeService, _ := newStoreService()
+		_, addErr := storeService.Add(ctx, "abc tech")
+		assert.ErrorIs(t, addErr, domain.ErrConflict)
+		_, renameErr := storeService.Rename(ctx, 2, " ABC  Tech ")
+		assert.ErrorIs(t, renameErr, domain.ErrConflict)
+		_, emptyErr := storeService.Add(ctx, " ")
+		assert.ErrorIs(t, emptyErr, domain.ErrValidation)
+	})
+	t.Run("WhenStoreIsRenamed_ShouldBumpAndAuditItsProducts", func(t *testing.T) {
+		storeService, productService, auditRepository := newAuditedStoreService()
+		renamedStore, err := storeService.Rename(ctx, 1, "ABC  Teknoloji")
+		assert.Nil(t, err)
+		assert.Equal(t, "ABC Teknoloji", renamedStore.Name)
+
+		renamedProduct, _ := productService.ProductById(ctx, 1)
+		assert.Equal(t, "ABC Teknoloji", renamedProduct.Store)
+		assert.Equal(t, int64(2), renamedProduct.Version)
+		untouchedProduct, _ := productService.ProductById(ctx, 2)
+		assert.Equal(t, int64(1), untouchedProduct.Version)
+
+		assert.Equal(t, 1, len(auditRepository.auditRecords))
+		auditRecord := auditRepository.auditRecords[0]
+		assert.Equal(t, domain.AuditUpdate, auditRecord.Operation)
+		assert.Equal(t, "ABC TECH", auditRecord.Before.Store)
+		assert.Equal(t, "ABC Teknoloji", auditRecord.After.Store)
+		assert.Equal(t, int64(2), auditRecord.After.Version)
+
+		_, sameNameErr := storeService.Rename(ctx, 1, "ABC Teknoloji")
+		assert.Nil(t, sameNameErr)
+		unchangedProduct, _ := productService.ProductById(ctx, 1)
+		assert.Equal(t, int64(2), unchangedProduct.Version)
+		assert.Equal(t, 1, len(auditRepository.auditRecords))
+	})
+	t.Run("ShouldPageThroughProductsOfStore", func(t *testing.T) {
+		storeService, productService := newStoreService()
+		productService.Add(ctx, model.ProductCreate{Name: "Abajur", Price: domain.NewDecimal(300), Store: "Dekorasyon Sarayı"})
+		productPage, err := storeService.Products(ctx, 2, domain.ProductQuery{})
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), productPage.Total)
+		_, notFoundErr := storeService.Products(ctx, 42, domain.ProductQuery{})
+		assert.ErrorIs(t, notFoundErr, domain.ErrNotFound)
+		assert.ErrorIs(t, storeService.DeleteById(ctx, 2), domain.ErrConflict)
+	})
+}
+
+// *failingBatchProductRepository fails the failOnCall-th AddProducts with err, like a database that went away mid import
+type failingBatchProductRepository struct {
+	persistence.IProductRepository
+	failOnCall int
+	calls      int
+	err        error
+}
+
+func (failingRepository *failingBatchProductRepository) AddProducts(ctx context.Context, products []domain.Product) ([]domain.Product, error) {
+	failingRepository.calls++
+	if failingRepository.calls == failingRepository.failOnCall {
+		return nil, failingRepository.err
+	}
+	return failingRepository.IProductRepository.AddProducts(ctx, products)
 }
@@ -0,0 +1,145 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"product-app/common/logging"
+	"product-app/common/tracing"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ShouldParseAndRenderTraceparent(t *testing.T) {
+	t.Run("ShouldRoundTripValidHeader", func(t *testing.T) {
+		header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+		spanContext, ok := tracing.ParseTraceparent(header)
+		assert.True(t, ok)
+		assert.True(t, spanContext.Sampled)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanContext.TraceId.String())
+		assert.Equal(t, "00f067aa0ba902b7", spanContext.SpanId.String())
+		assert.Equal(t, header, spanContext.Traceparent())
+	})
+	t.Run("ShouldRejectMalformedHeaders", func(t *testing.T) {
+		for _, header := range []string{
+			"",
+			"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		} {
+			_, ok := tracing.ParseTraceparent(header)
+			assert.False(t, ok, header)
+		}
+	})
+}
+
+func Test_ShouldBuildSpanTrees(t *testing.T) {
+	t.Run("ShouldContinueRemoteTraceAndNestChildren", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		tracer := tracing.NewTracer(tracing.NewWriterExporter(output))
+		remoteParent, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		ctx, server := tracer.Start(tracing.ContextWithRemoteParent(context.Background(), remoteParent), "GET /", tracing.SpanKindServer)
+		_, child := tracer.Start(ctx, "SELECT", tracing.SpanKindClient, tracing.String("db.statement", "SELECT 1"))
+		child.SetAttributes(tracing.Int64("db.rows", 1))
+		child.RecordError(errors.New("boom"))
+		child.End()
+		server.End()
+		server.End()
+
+		var spans []map[string]interface{}
+		decoder := json.NewDecoder(output)
+		for decoder.More() {
+			var span map[string]interface{}
+			assert.Nil(t, decoder.Decode(&span))
+			spans = append(spans, span)
+		}
+		assert.Equal(t, 2, len(spans))
+		assert.Equal(t, "SELECT", spans[0]["name"])
+		assert.Equal(t, "client", spans[0]["kind"])
+		assert.Equal(t, "boom", spans[0]["error"])
+		assert.Equal(t, map[string]interface{}{"db.statement": "SELECT 1", "db.rows": float64(1)}, spans[0]["attributes"])
+		assert.Equal(t, spans[1]["span_id"], spans[0]["parent_span_id"])
+		assert.Equal(t, "00f067aa0ba902b7", spans[1]["parent_span_id"])
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0]["trace_id"])
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1]["trace_id"])
+	})
+	t.Run("WhenCallerDidNotSample_ShouldNotExport", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		tracer := tracing.NewTracer(tracing.NewWriterExporter(output))
+		remoteParent, _ := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		_, span := tracer.Start(tracing.ContextWithRemoteParent(context.Background(), remoteParent), "GET /", tracing.SpanKindServer)
+		span.End()
+		assert.Empty(t, output.String())
+	})
+	t.Run("WhenTracerIsDisabled_ShouldHandOutNilSpans", func(t *testing.T) {
+		var tracer *tracing.Tracer
+		ctx, span := tracer.Start(context.Background(), "GET /", tracing.SpanKindServer)
+		span.SetAttributes(tracing.Bool("ignored", true))
+		span.RecordError(errors.New("ignored"))
+		span.End()
+		assert.Nil(t, span)
+		assert.Nil(t, tracing.SpanFromContext(ctx))
+		assert.Nil(t, tracer.Shutdown(context.Background()))
+	})
+}
+
+// *collector is a local stand-in for an OTLP/HTTP collector
+type collector struct {
+	mutex    sync.Mutex
+	paths    []string
+	requests []map[string]interface{}
+}
+
+func (collector *collector) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	body, _ := io.ReadAll(request.Body)
+	var decoded map[string]interface{}
+	json.Unmarshal(body, &decoded)
+	collector.mutex.Lock()
+	defer collector.mutex.Unlock()
+	collector.paths = append(collector.paths, request.URL.Path)
+	collector.requests = append(collector.requests, decoded)
+	writer.WriteHeader(http.StatusOK)
+}
+
+func Test_ShouldExportSpansToOTLPCollector(t *testing.T) {
+	t.Run("ShouldFlushQueuedSpansOnShutdown", func(t *testing.T) {
+		standIn := &collector{}
+		server := httptest.NewServer(standIn)
+		defer server.Close()
+
+		tracer := tracing.NewTracer(tracing.NewOTLPExporter(server.URL, "product-app", time.Hour, logging.Discard()))
+		ctx, parent := tracer.Start(context.Background(), "GET /api/v1/products/", tracing.SpanKindServer)
+		_, child := tracer.Start(ctx, "SELECT", tracing.SpanKindClient, tracing.Int64("db.rows", 3))
+		child.RecordError(errors.New("slow"))
+		child.End()
+		parent.End()
+		assert.Nil(t, tracer.Shutdown(context.Background()))
+
+		assert.Equal(t, []string{"/v1/traces"}, standIn.paths)
+		resourceSpans := standIn.requests[0]["resourceSpans"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, []interface{}{map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "product-app"}}},
+			resourceSpans["resource"].(map[string]interface{})["attributes"])
+		spans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+		assert.Equal(t, 2, len(spans))
+
+		childSpan := spans[0].(map[string]interface{})
+		parentSpan := spans[1].(map[string]interface{})
+		assert.Equal(t, "SELECT", childSpan["name"])
+		assert.Equal(t, float64(3), childSpan["kind"])
+		assert.Equal(t, parentSpan["spanId"], childSpan["parentSpanId"])
+		assert.Equal(t, parentSpan["traceId"], childSpan["traceId"])
+		assert.Equal(t, map[string]interface{}{"code": float64(2), "message": "slow"}, childSpan["status"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"key": "db.rows", "value": map[string]interface{}{"intValue": "3"}}}, childSpan["attributes"])
+		assert.Equal(t, float64(2), parentSpan["kind"])
+	})
+}